@@ -0,0 +1,325 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golistic/kolekto"
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/xstrings"
+)
+
+func cmdCollections(ctx context.Context, c *cli, args []string) error {
+	if _, err := parseArgs(c.commandFlags("collections"), args, 0, 0); err != nil {
+		return err
+	}
+
+	ses, err := c.session()
+	if err != nil {
+		return err
+	}
+
+	names, err := ses.CollectionNamesContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		_, _ = fmt.Fprintln(c.stdout, name)
+	}
+
+	return nil
+}
+
+func cmdIndexes(ctx context.Context, c *cli, args []string) error {
+	args, err := parseArgs(c.commandFlags("indexes"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	ses, _, err := c.openCollection(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	indexes, err := ses.IndexesContext(ctx, &document{collection: args[0]})
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tUNIQUE\tMANAGED\tVALID\tDEFINITION")
+	for _, idx := range indexes {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			idx.Name, yesNo(idx.Unique), yesNo(idx.Managed), yesNo(idx.Valid), idx.Definition)
+	}
+
+	return tw.Flush()
+}
+
+func cmdGet(ctx context.Context, c *cli, args []string) error {
+	args, err := parseArgs(c.commandFlags("get"), args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	_, coll, err := c.openCollection(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	var uid any = args[1]
+	if id, err := strconv.ParseInt(args[1], 10, 64); err == nil {
+		uid = id
+	}
+
+	doc := &document{collection: args[0]}
+	if err := coll.GetContext(ctx, doc, uid); err != nil {
+		return err
+	}
+
+	return writeIndented(c.stdout, doc.raw)
+}
+
+func cmdQuery(ctx context.Context, c *cli, args []string) error {
+	flags := c.commandFlags("query")
+	query := &kolektor.Query{}
+	flags.Func("where", "condition, for example, 'year>=1990' or 'title LIKE \"Go%\"' (repeatable)",
+		func(s string) error {
+			cond, err := parseCondition(s)
+			if err != nil {
+				return err
+			}
+			query.Filter = append(query.Filter, cond)
+			return nil
+		})
+	flags.Func("order", "field to sort by; prefix with - to sort descending (repeatable)",
+		func(s string) error {
+			order := kolektor.Order{Field: strings.TrimPrefix(s, "-"), Descending: strings.HasPrefix(s, "-")}
+			query.Order = append(query.Order, order)
+			return nil
+		})
+	flags.IntVar(&query.Limit, "limit", 100, "maximum number of objects; 0 retrieves all objects")
+	flags.IntVar(&query.Offset, "offset", 0, "number of objects to skip")
+
+	args, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	_, coll, err := c.openCollection(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(query.Order) == 0 {
+		query.Order = []kolektor.Order{{Field: "id"}}
+	}
+
+	var docs []*document
+	if err := coll.FindContext(ctx, &docs, query); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(c.stdout)
+	for _, doc := range docs {
+		_, _ = w.Write(append(doc.raw, '\n'))
+	}
+
+	return w.Flush()
+}
+
+func cmdExport(ctx context.Context, c *cli, args []string) (err error) {
+	args, err = parseArgs(c.commandFlags("export"), args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	_, coll, err := c.openCollection(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	var f *os.File
+	w := c.stdout
+	if len(args) == 2 {
+		if f, err = os.Create(args[1]); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				_ = f.Close()
+			}
+		}()
+		w = f
+	}
+
+	n, err := coll.ExportContext(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	if f != nil {
+		// closing reports errors writing the file
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	_, _ = fmt.Fprintf(c.stderr, "exported %d objects from %s\n", n, args[0])
+	return nil
+}
+
+func cmdImport(ctx context.Context, c *cli, args []string) error {
+	flags := c.commandFlags("import")
+	var opts kolekto.ImportOptions
+	flags.BoolVar(&opts.KeepIDs, "keep-ids", false, "keep the IDs of the objects")
+	flags.BoolVar(&opts.NewUIDs, "new-uids", false, "assign new UIDs to the objects")
+	flags.Func("on-conflict", "when an object with the same UID exists: fail (default), skip, or overwrite",
+		func(s string) error {
+			switch s {
+			case "fail":
+				opts.OnConflict = kolektor.ImportFail
+			case "skip":
+				opts.OnConflict = kolektor.ImportSkip
+			case "overwrite":
+				opts.OnConflict = kolektor.ImportOverwrite
+			default:
+				return fmt.Errorf("must be fail, skip, or overwrite")
+			}
+			return nil
+		})
+	create := flags.Bool("create", false, "create the collection, without indexes, when it does not exist")
+
+	args, err := parseArgs(flags, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	ses, err := c.session()
+	if err != nil {
+		return err
+	}
+
+	names, err := ses.CollectionNamesContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var coll *kolekto.Collection
+	doc := &document{collection: args[0]}
+	switch {
+	case xstrings.Search(names, args[0]) != -1:
+		coll, err = ses.OpenCollectionContext(ctx, doc)
+	case *create:
+		coll, err = ses.CollectionContext(ctx, doc)
+	default:
+		return fmt.Errorf("collection %s does not exist (use -create)", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	r := c.stdin
+	if len(args) == 2 {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	n, err := coll.ImportContext(ctx, r, opts)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(c.stderr, "imported %d objects into %s\n", n, args[0])
+	return nil
+}
+
+func cmdDrop(ctx context.Context, c *cli, args []string) error {
+	flags := c.commandFlags("drop")
+	yes := flags.Bool("yes", false, "do not ask for confirmation")
+
+	args, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	ses, coll, err := c.openCollection(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if !*yes {
+		n, err := coll.CountContext(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(c.stderr, "Removing collection %s with %d objects cannot be undone.\n"+
+			"Type the name of the collection to confirm: ", args[0], n)
+
+		answer, err := bufio.NewReader(c.stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if strings.TrimSpace(answer) != args[0] {
+			return fmt.Errorf("not confirmed; collection %s was kept", args[0])
+		}
+	}
+
+	if err := ses.RemoveCollectionContext(ctx, &document{collection: args[0]}); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(c.stderr, "removed collection %s\n", args[0])
+	return nil
+}
+
+// openCollection connects to the data store and opens the collection
+// called name, which must exist.
+func (c *cli) openCollection(ctx context.Context, name string) (*kolekto.Session, *kolekto.Collection, error) {
+	ses, err := c.session()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := ses.OpenCollectionContext(ctx, &document{collection: name})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ses, coll, nil
+}
+
+// writeIndented writes the JSON document data indented to w.
+func writeIndented(w io.Writer, data []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// document is the model of any collection, of which the name is only known
+// when running the command. It keeps the JSON document as retrieved,
+// including its metadata.
+type document struct {
+	kolektor.Model
+	collection string
+	raw        []byte
+}
+
+var _ kolektor.Modeler = &document{}
+
+func (d *document) CollectionName() string {
+	return d.collection
+}
+
+// UnmarshalJSON keeps a copy of data.
+func (d *document) UnmarshalJSON(data []byte) error {
+	d.raw = append(d.raw[:0], data...)
+	return nil
+}
+
+// reWordCondition matches conditions using an operator which is a word,
+// for example, "title LIKE "Go%"" or "year IS NULL".
+var reWordCondition = regexp.MustCompile(`(?i)^\s*([^\s=<>!]+)\s+(LIKE|IN|IS NULL|IS NOT NULL)\b\s*(.*)$`)
+
+// symbolOperators are the operators which are symbols; operators which
+// are a prefix of others come last.
+var symbolOperators = []kolektor.Operator{
+	kolektor.OpNotEqual, kolektor.OpLessEqual, kolektor.OpGreaterEqual,
+	kolektor.OpEqual, kolektor.OpLess, kolektor.OpGreater,
+}
+
+// parseCondition parses s as field, operator, and value, for example,
+// "year>=1990". The value is decoded as JSON when valid, and is used
+// as string otherwise. Values of IN must be a JSON array.
+func parseCondition(s string) (kolektor.Condition, error) {
+	var cond kolektor.Condition
+	var value string
+
+	if m := reWordCondition.FindStringSubmatch(s); m != nil {
+		cond.Field = m[1]
+		cond.Operator = kolektor.Operator(strings.ToUpper(m[2]))
+		value = m[3]
+	} else {
+		i := strings.IndexAny(s, "!=<>")
+		if i < 1 {
+			return cond, fmt.Errorf("invalid condition %s", s)
+		}
+		cond.Field = strings.TrimSpace(s[:i])
+		for _, op := range symbolOperators {
+			if strings.HasPrefix(s[i:], string(op)) {
+				cond.Operator = op
+				value = s[i+len(op):]
+				break
+			}
+		}
+	}
+
+	switch cond.Operator {
+	case "":
+		return cond, fmt.Errorf("invalid condition %s", s)
+	case kolektor.OpIsNull, kolektor.OpIsNotNull:
+		if strings.TrimSpace(value) != "" {
+			return cond, fmt.Errorf("invalid condition %s (%s has no value)", s, cond.Operator)
+		}
+		return cond, nil
+	}
+
+	cond.Value = parseValue(strings.TrimSpace(value))
+	if _, ok := cond.Value.([]any); cond.Operator == kolektor.OpIn && !ok {
+		return cond, fmt.Errorf("invalid condition %s (IN needs a JSON array)", s)
+	}
+
+	return cond, nil
+}
+
+// parseValue decodes s as JSON, using int64 for integers. When s is not
+// valid JSON, it is returned as is.
+func parseValue(s string) any {
+	dec := json.NewDecoder(bytes.NewBufferString(s))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return s
+	}
+
+	return numbers(v)
+}
+
+// numbers replaces the JSON numbers within v with int64 or float64.
+func numbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i := range v {
+			v[i] = numbers(v[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+// Command kolekto inspects and manages the collections of a data store
+// without the models of the application using them.
+//
+// Usage:
+//
+//	kolekto -store <kind> -dsn <dsn> <command> [arguments]
+//
+// The kind of store is, for example, MySQL, PostgreSQL, or SQLite. The
+// store and DSN default to the environment variables KOLEKTO_STORE and
+// KOLEKTO_DSN, which keeps passwords out of the list of processes.
+//
+// Commands:
+//
+//	collections                          list the collections
+//	indexes <collection>                 show the indexes of a collection
+//	get <collection> <id|uid>            show an object using its ID or UID
+//	query [flags] <collection>           show the objects matching conditions
+//	export <collection> [file]           export the objects as NDJSON
+//	import [flags] <collection> [file]   import objects from NDJSON
+//	drop [-yes] <collection>             remove a collection after confirming
+//
+// Use "kolekto <command> -h" to show the flags of a command.
+//
+// Collections are never initialized: indexes are left as they are, even
+// though the command does not know the models using them.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/golistic/kolekto"
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	_ "github.com/golistic/kolekto/stores/dbmemory"
+	_ "github.com/golistic/kolekto/stores/dbmysql"
+	_ "github.com/golistic/kolekto/stores/dbpgsql"
+	_ "github.com/golistic/kolekto/stores/dbsqlite"
+)
+
+// errUsage is returned when the command line is not valid. The usage is
+// already reported.
+var errUsage = errors.New("invalid usage")
+
+// cli holds the input and output of the command, so it can be run within
+// tests.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(key string) string
+	// store and dsn are set using the global flags.
+	store string
+	dsn   string
+}
+
+// command is a sub-command. The arguments passed to run exclude the name
+// of the command.
+type command struct {
+	usage string
+	about string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+// commands maps the name of each command to its definition. It is set
+// within init since commands use it to report their usage.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"collections": {
+			usage: "collections",
+			about: "list the collections",
+			run:   cmdCollections,
+		},
+		"indexes": {
+			usage: "indexes <collection>",
+			about: "show the indexes of a collection",
+			run:   cmdIndexes,
+		},
+		"get": {
+			usage: "get <collection> <id|uid>",
+			about: "show an object using its ID or UID",
+			run:   cmdGet,
+		},
+		"query": {
+			usage: "query [flags] <collection>",
+			about: "show the objects matching conditions",
+			run:   cmdQuery,
+		},
+		"export": {
+			usage: "export <collection> [file]",
+			about: "export the objects as NDJSON",
+			run:   cmdExport,
+		},
+		"import": {
+			usage: "import [flags] <collection> [file]",
+			about: "import objects from NDJSON",
+			run:   cmdImport,
+		},
+		"drop": {
+			usage: "drop [-yes] <collection>",
+			about: "remove a collection after confirming",
+			run:   cmdDrop,
+		},
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}
+	err := c.run(ctx, os.Args[1:])
+	stop()
+
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		_, _ = fmt.Fprintf(c.stderr, "kolekto: %s\n", strings.TrimPrefix(err.Error(), "kolekto: "))
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags, and runs the command found in args.
+func (c *cli) run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("kolekto", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.StringVar(&c.store, "store", c.getenv("KOLEKTO_STORE"), "kind of data store (env KOLEKTO_STORE)")
+	flags.StringVar(&c.dsn, "dsn", c.getenv("KOLEKTO_DSN"), "data source name (env KOLEKTO_DSN)")
+	flags.Usage = func() { c.usage(flags) }
+
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if flags.NArg() == 0 {
+		c.usage(flags)
+		return errUsage
+	}
+
+	cmd, have := commands[flags.Arg(0)]
+	if !have {
+		_, _ = fmt.Fprintf(c.stderr, "kolekto: unknown command %s\n", flags.Arg(0))
+		c.usage(flags)
+		return errUsage
+	}
+
+	return cmd.run(ctx, c, flags.Args()[1:])
+}
+
+// session connects to the data store set using the global flags.
+func (c *cli) session() (*kolekto.Session, error) {
+	kind, err := storeKind(c.store)
+	if err != nil {
+		return nil, err
+	}
+
+	return kolekto.NewSession(kind, c.dsn)
+}
+
+func (c *cli) usage(flags *flag.FlagSet) {
+	_, _ = fmt.Fprintf(c.stderr, "Usage: kolekto -store <kind> -dsn <dsn> <command> [arguments]\n\n")
+	flags.PrintDefaults()
+
+	_, _ = fmt.Fprintf(c.stderr, "\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(c.stderr, "  %-36s %s\n", commands[name].usage, commands[name].about)
+	}
+}
+
+// storeKind returns the kind of the registered data store called name,
+// ignoring case.
+func storeKind(name string) (kolektor.StoreKind, error) {
+	var names []string
+	for kind := range stores.Registered() {
+		if strings.EqualFold(kind.String(), name) {
+			return kind, nil
+		}
+		names = append(names, kind.String())
+	}
+	sort.Strings(names)
+
+	if name == "" {
+		return 0, fmt.Errorf("need kind of store (one of %s)", strings.Join(names, ", "))
+	}
+	return 0, fmt.Errorf("unknown kind of store %s (one of %s)", name, strings.Join(names, ", "))
+}
+
+// commandFlags returns the flags of the command called name, of which the
+// usage lists the flags.
+func (c *cli) commandFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(c.stderr, "Usage: kolekto %s\n", commands[name].usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseArgs parses the flags of the command, and checks that the number of
+// remaining arguments is between minArgs and maxArgs.
+func parseArgs(flags *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, errUsage
+	}
+
+	if flags.NArg() < minArgs || flags.NArg() > maxArgs {
+		flags.Usage()
+		return nil, errUsage
+	}
+
+	return flags.Args(), nil
+}
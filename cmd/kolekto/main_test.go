@@ -0,0 +1,217 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/geertjanvdk/xkit/xt"
+	"github.com/golistic/kolekto"
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores/dbmemory"
+)
+
+const testDSN = "kolekto-cli"
+
+type book struct {
+	kolektor.Model
+	ISBN13 string `json:"isbn13" kolekto:"index,unique"`
+	Title  string `json:"title"`
+	Year   int    `json:"year"`
+}
+
+func (b book) CollectionName() string {
+	return "books"
+}
+
+// runCLI runs the command using args with the memory store, and returns
+// what was written to stdout and stderr.
+func runCLI(stdin string, args ...string) (string, string, error) {
+	var stdout, stderr strings.Builder
+	c := &cli{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		getenv: func(key string) string {
+			return map[string]string{"KOLEKTO_STORE": "memory", "KOLEKTO_DSN": testDSN}[key]
+		},
+	}
+
+	err := c.run(context.Background(), args)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestCLI(t *testing.T) {
+	defer dbmemory.Drop(testDSN)
+
+	ctx := context.Background()
+	ses, err := kolekto.NewSession(kolektor.Memory, testDSN)
+	xt.OK(t, err)
+	books, err := ses.Collection(&book{})
+	xt.OK(t, err)
+	xt.OK(t, books.StoreManyContext(ctx, []*book{
+		{ISBN13: "978-0134190440", Title: "The Go Programming Language", Year: 2015},
+		{ISBN13: "978-1718500440", Title: "The Rust Programming Language", Year: 2019},
+		{ISBN13: "978-0262510875", Title: "SICP", Year: 1996},
+	}))
+
+	t.Run("list collections", func(t *testing.T) {
+		out, _, err := runCLI("", "collections")
+		xt.OK(t, err)
+		xt.Eq(t, "books\n", out)
+	})
+
+	t.Run("show indexes", func(t *testing.T) {
+		out, _, err := runCLI("", "indexes", "books")
+		xt.OK(t, err)
+		xt.Match(t, `uq_books_isbn13\s+yes\s+yes\s+yes\s+\(isbn13\)`, out)
+		xt.Match(t, `uq_books_uid\s+yes\s+no\s+yes\s+\(uid\)`, out)
+	})
+
+	t.Run("get object using ID or UID", func(t *testing.T) {
+		out, _, err := runCLI("", "get", "books", "3")
+		xt.OK(t, err)
+
+		got := &book{}
+		xt.OK(t, json.Unmarshal([]byte(out), got))
+		xt.Eq(t, "SICP", got.Title)
+
+		out, _, err = runCLI("", "get", "books", got.Meta.UID)
+		xt.OK(t, err)
+		xt.Assert(t, strings.Contains(out, `"title": "SICP"`))
+	})
+
+	t.Run("query objects", func(t *testing.T) {
+		out, _, err := runCLI("", "query", "-where", "year>=2000", "-order", "-year", "books")
+		xt.OK(t, err)
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		xt.Eq(t, 2, len(lines))
+		xt.Assert(t, strings.Contains(lines[0], "Rust"))
+		xt.Assert(t, strings.Contains(lines[1], "Go"))
+
+		out, _, err = runCLI("", "query", "-where", `title LIKE "%Go%"`, "books")
+		xt.OK(t, err)
+		xt.Eq(t, 1, strings.Count(out, "\n"))
+	})
+
+	t.Run("export and import", func(t *testing.T) {
+		exported, _, err := runCLI("", "export", "books")
+		xt.OK(t, err)
+		xt.Eq(t, 3, strings.Count(exported, "\n"))
+
+		_, _, err = runCLI(exported, "import", "copies")
+		xt.KO(t, err)
+		xt.Eq(t, "collection copies does not exist (use -create)", err.Error())
+
+		_, msg, err := runCLI(exported, "import", "-create", "-keep-ids", "copies")
+		xt.OK(t, err)
+		xt.Eq(t, "imported 3 objects into copies\n", msg)
+
+		_, msg, err = runCLI(exported, "import", "-on-conflict", "skip", "copies")
+		xt.OK(t, err)
+		xt.Eq(t, "imported 0 objects into copies\n", msg)
+	})
+
+	t.Run("export to file", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "books.ndjson")
+		out, msg, err := runCLI("", "export", "books", name)
+		xt.OK(t, err)
+		xt.Eq(t, "", out)
+		xt.Eq(t, "exported 3 objects from books\n", msg)
+
+		data, err := os.ReadFile(name)
+		xt.OK(t, err)
+		xt.Eq(t, 3, strings.Count(string(data), "\n"))
+	})
+
+	t.Run("export to file which cannot be written", func(t *testing.T) {
+		if _, err := os.Stat("/dev/full"); err != nil {
+			t.Skip("/dev/full not available")
+		}
+
+		_, msg, err := runCLI("", "export", "books", "/dev/full")
+		xt.KO(t, err)
+		xt.Eq(t, "", msg)
+	})
+
+	t.Run("import into collection with invalid name", func(t *testing.T) {
+		_, _, err := runCLI("", "import", "-create", "copies; DROP TABLE books")
+		xt.Assert(t, errors.Is(err, kolektor.ErrInvalidCollectionName))
+		xt.Eq(t, "kolekto: invalid collection name 'copies; DROP TABLE books'", err.Error())
+
+		out, _, err := runCLI("", "collections")
+		xt.OK(t, err)
+		xt.Eq(t, "books\ncopies\n", out)
+	})
+
+	t.Run("drop collection after confirmation", func(t *testing.T) {
+		_, _, err := runCLI("books\n", "drop", "copies")
+		xt.KO(t, err)
+		xt.Eq(t, "not confirmed; collection copies was kept", err.Error())
+
+		_, _, err = runCLI("copies\n", "drop", "copies")
+		xt.OK(t, err)
+
+		out, _, err := runCLI("", "collections")
+		xt.OK(t, err)
+		xt.Eq(t, "books\n", out)
+
+		_, _, err = runCLI("", "drop", "copies")
+		xt.KO(t, err)
+		xt.Eq(t, "kolekto: collection copies does not exist", err.Error())
+	})
+
+	t.Run("invalid usage", func(t *testing.T) {
+		_, msg, err := runCLI("", "nope")
+		xt.Assert(t, errors.Is(err, errUsage))
+		xt.Assert(t, strings.Contains(msg, "unknown command nope"))
+
+		_, _, err = runCLI("", "get", "books")
+		xt.Assert(t, errors.Is(err, errUsage))
+
+		_, _, err = runCLI("", "-store", "nope", "collections")
+		xt.KO(t, err)
+		xt.Match(t, `^unknown kind of store nope \(one of .*Memory.*\)$`, err.Error())
+	})
+}
+
+func TestParseCondition(t *testing.T) {
+	t.Run("valid conditions", func(t *testing.T) {
+		var cases = map[string]kolektor.Condition{
+			"year>=1990":            {Field: "year", Operator: kolektor.OpGreaterEqual, Value: int64(1990)},
+			"price < 9.5":           {Field: "price", Operator: kolektor.OpLess, Value: 9.5},
+			"title=SICP":            {Field: "title", Operator: kolektor.OpEqual, Value: "SICP"},
+			`title != "SICP"`:       {Field: "title", Operator: kolektor.OpNotEqual, Value: "SICP"},
+			`title like "Go%"`:      {Field: "title", Operator: kolektor.OpLike, Value: "Go%"},
+			`year IN [1996, 2015]`:  {Field: "year", Operator: kolektor.OpIn, Value: []any{int64(1996), int64(2015)}},
+			"address.city IS NULL":  {Field: "address.city", Operator: kolektor.OpIsNull},
+			"year is not null":      {Field: "year", Operator: kolektor.OpIsNotNull},
+			"inStock = true":        {Field: "inStock", Operator: kolektor.OpEqual, Value: true},
+			"deleted IS NOT NULL  ": {Field: "deleted", Operator: kolektor.OpIsNotNull},
+		}
+
+		for s, exp := range cases {
+			t.Run(s, func(t *testing.T) {
+				cond, err := parseCondition(s)
+				xt.OK(t, err)
+				xt.Eq(t, exp, cond)
+			})
+		}
+	})
+
+	t.Run("invalid conditions", func(t *testing.T) {
+		for _, s := range []string{"year", "=1990", "year IN 1990", "year IS NULL 1"} {
+			t.Run(s, func(t *testing.T) {
+				_, err := parseCondition(s)
+				xt.KO(t, err)
+			})
+		}
+	})
+}
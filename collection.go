@@ -3,23 +3,51 @@
 package kolekto
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
+// DefaultChunkSize is the default number of objects stored using one
+// statement by Collection.StoreMany.
+const DefaultChunkSize = 500
+
 // Collection manages a JSON collection.
 type Collection struct {
-	ses *Session
+	ses       *Session
+	model     kolektor.Modeler
+	chunkSize int
+	schema    *jsonschema.Schema
 }
 
-func newCollection(kol *Session) (*Collection, error) {
+func newCollection(kol *Session, model kolektor.Modeler) (*Collection, error) {
 	if kol == nil {
 		panic("ses must not be nil")
 	}
 
+	if err := kolektor.CheckCollectionName(model.CollectionName()); err != nil {
+		return nil, err
+	}
+
+	if err := checkMigrator(model); err != nil {
+		return nil, err
+	}
+
+	schema, err := compileSchema(model)
+	if err != nil {
+		return nil, err
+	}
+
 	coll := &Collection{
-		ses: kol,
+		ses:       kol,
+		model:     model,
+		chunkSize: DefaultChunkSize,
+		schema:    schema,
 	}
 
 	return coll, nil
@@ -29,6 +57,12 @@ func newCollection(kol *Session) (*Collection, error) {
 // The uid can be either an integer (int64, int) or a string. The former will
 // use the model ID field, the latter the UID field.
 func (coll *Collection) Get(obj kolektor.Modeler, uid any) error {
+	return coll.GetContext(context.Background(), obj, uid)
+}
+
+// GetContext retrieves an object from the collection like Get, using the
+// provided context.
+func (coll *Collection) GetContext(ctx context.Context, obj kolektor.Modeler, uid any) error {
 	rv := reflect.ValueOf(obj)
 	if rv.Kind() != reflect.Pointer || rv.IsNil() {
 		return &kolektor.InvalidObjectError{Type: reflect.TypeOf(obj)}
@@ -42,24 +76,625 @@ func (coll *Collection) Get(obj kolektor.Modeler, uid any) error {
 		field = "uid"
 	}
 
-	return coll.GetByFields(obj, map[string]any{field: uid})
+	return coll.GetByFieldsContext(ctx, obj, map[string]any{field: uid})
 }
 
+// GetByFields retrieves an object from the collection matching all fields.
 func (coll *Collection) GetByFields(obj kolektor.Modeler, fields map[string]any) error {
-	return coll.ses.store.GetObject(obj, fields)
+	return coll.GetByFieldsContext(context.Background(), obj, fields)
+}
+
+// GetByFieldsContext retrieves an object from the collection like GetByFields,
+// using the provided context.
+// When obj is a kolektor.AfterGetter, its AfterGet hook is called once the
+// object was retrieved. When obj is a kolektor.Migrator, documents stored
+// using an older schema version are upgraded.
+func (coll *Collection) GetByFieldsContext(ctx context.Context, obj kolektor.Modeler, fields map[string]any) (err error) {
+	defer coll.observe("Get", time.Now(), &err)
+
+	if coll.softDeletes() || coll.migrates() {
+		err = coll.getOne(ctx, obj, fields)
+	} else {
+		err = coll.ses.store.GetObject(ctx, obj, fields)
+	}
+	if err != nil {
+		return err
+	}
+
+	return afterGet(ctx, obj)
+}
+
+// getOne retrieves the first object matching all fields, and stores it in
+// obj. Soft deleted objects are excluded, unless fields has the deleted
+// field, and documents are upgraded when needed.
+func (coll *Collection) getOne(ctx context.Context, obj kolektor.Modeler, fields map[string]any) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("need at least one field to filter on")
+	}
+
+	docs, err := coll.documents(ctx, &kolektor.Query{
+		Filter: kolektor.FilterFromFields(fields),
+		Limit:  1,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(docs) == 0 {
+		return stores.ErrNoObject{Name: coll.model.CollectionName()}
+	}
+
+	return coll.decode(docs[0], obj)
 }
 
 // Store stores an object into the collection.
 func (coll *Collection) Store(obj kolektor.Modeler) error {
+	return coll.StoreContext(context.Background(), obj)
+}
+
+// StoreContext stores an object into the collection like Store, using
+// the provided context.
+// When obj is a kolektor.BeforeStorer, the object is not stored when its
+// BeforeStore hook returns an error. When obj is a kolektor.AfterStorer,
+// its AfterStore hook is called once the metadata was set.
+// Objects are validated after the BeforeStore hook when the model is
+// a kolektor.Validator or a kolektor.JSONSchemer; error stores.ErrInvalid
+// is returned when the object is not valid.
+func (coll *Collection) StoreContext(ctx context.Context, obj kolektor.Modeler) (err error) {
+	defer coll.observe("Store", time.Now(), &err)
+
+	if err := beforeStore(ctx, obj); err != nil {
+		return err
+	}
+
+	if err := coll.validate(obj); err != nil {
+		return err
+	}
+
 	var meta *kolektor.Meta
 
-	var err error
-	meta, err = coll.ses.store.StoreObject(obj)
+	meta, err = coll.ses.store.StoreObject(ctx, obj)
 	if err != nil {
 		return err
 	}
 
 	obj.SetMeta(meta)
 
+	return afterStore(ctx, obj)
+}
+
+// Upsert stores obj like StoreContext, but instead of inserting obj, the
+// object with the same values for keyFields is updated when it exists. The
+// keyFields are the paths of the fields of a unique index of the model, or
+// its name; the UID of obj is used when no keyFields are given. The
+// metadata of the stored object is returned, together with whether it was
+// inserted. The version of obj is not checked, and updated objects are
+// restored when they were soft deleted.
+func (coll *Collection) Upsert(obj kolektor.Modeler, keyFields ...string) (*kolektor.Meta, bool, error) {
+	return coll.UpsertContext(context.Background(), obj, keyFields...)
+}
+
+// UpsertContext inserts or updates an object like Upsert, using the provided
+// context.
+func (coll *Collection) UpsertContext(ctx context.Context, obj kolektor.Modeler, keyFields ...string) (_ *kolektor.Meta, _ bool, err error) {
+	defer coll.observe("Upsert", time.Now(), &err)
+
+	if err := beforeStore(ctx, obj); err != nil {
+		return nil, false, err
+	}
+
+	if err := coll.validate(obj); err != nil {
+		return nil, false, err
+	}
+
+	meta, inserted, err := coll.ses.store.UpsertObject(ctx, obj, keyFields)
+	if err != nil {
+		return nil, false, err
+	}
+
+	obj.SetMeta(meta)
+
+	if err := afterStore(ctx, obj); err != nil {
+		return nil, false, err
+	}
+
+	return meta, inserted, nil
+}
+
+// SetChunkSize sets the number of objects StoreMany stores using one
+// statement. When n is smaller than 1, DefaultChunkSize is used.
+func (coll *Collection) SetChunkSize(n int) {
+	if n < 1 {
+		n = DefaultChunkSize
+	}
+	coll.chunkSize = n
+}
+
+// StoreMany stores objs, which must be a slice of models, into the collection.
+// New objects are inserted in chunks using one statement per chunk (see
+// SetChunkSize). The metadata of each object is set afterwards.
+// Each chunk is stored within its own transaction, but not all of them
+// together unless StoreMany is used within a transaction (see Session.Tx).
+// Like StoreContext, lifecycle hooks are called and objects are validated:
+// the BeforeStore hook and validation of all objects before any is stored,
+// and the AfterStore hook of each object once all were stored.
+func (coll *Collection) StoreMany(objs any) error {
+	return coll.StoreManyContext(context.Background(), objs)
+}
+
+// StoreManyContext stores objects like StoreMany, using the provided context.
+func (coll *Collection) StoreManyContext(ctx context.Context, objs any) (err error) {
+	defer coll.observe("StoreMany", time.Now(), &err)
+
+	list, err := modelList(objs)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range list {
+		if err := beforeStore(ctx, obj); err != nil {
+			return err
+		}
+		if err := coll.validate(obj); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(list); start += coll.chunkSize {
+		end := start + coll.chunkSize
+		if end > len(list) {
+			end = len(list)
+		}
+
+		var metas []*kolektor.Meta
+		chunk := func(tx *Session) error {
+			var err error
+			metas, err = tx.store.StoreObjects(ctx, coll.model, list[start:end])
+			return err
+		}
+
+		var err error
+		if coll.ses.inTx {
+			err = chunk(coll.ses)
+		} else {
+			err = coll.ses.Tx(ctx, chunk)
+		}
+		if err != nil {
+			return err
+		}
+
+		for i, meta := range metas {
+			list[start+i].SetMeta(meta)
+		}
+	}
+
+	for _, obj := range list {
+		if err := afterStore(ctx, obj); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// Find retrieves all objects matching query and stores them in dest, which
+// must be a pointer to a slice of models, for example, *[]*Book or *[]Book.
+// When query is nil, all objects of the collection are retrieved.
+func (coll *Collection) Find(dest any, query *kolektor.Query) error {
+	return coll.FindContext(context.Background(), dest, query)
+}
+
+// FindContext retrieves objects like Find, using the provided context.
+func (coll *Collection) FindContext(ctx context.Context, dest any, query *kolektor.Query) (err error) {
+	defer coll.observe("Find", time.Now(), &err)
+
+	slice, elemType, err := modelSlice(dest)
+	if err != nil {
+		return err
+	}
+
+	docs, err := coll.documents(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return coll.decodeObjects(ctx, slice, elemType, docs)
+}
+
+// PageOptions defines which page of objects is retrieved using
+// Collection.Page.
+type PageOptions struct {
+	// Filter is applied to all pages.
+	Filter kolektor.Filter
+	// Size is the maximum number of objects per page. Defaults to DefaultPageSize.
+	Size int
+	// Cursor is the token returned when retrieving the previous page, and is
+	// empty when retrieving the first page.
+	Cursor string
+	// ByCreated sorts by the created timestamp and then ID, instead of ID only.
+	ByCreated bool
+}
+
+// DefaultPageSize is the number of objects per page when PageOptions.Size
+// is not set.
+const DefaultPageSize = 50
+
+// Page retrieves the page of objects defined by opts and stores them in dest,
+// which must be a pointer to a slice of models. It returns the cursor with
+// which the next page is retrieved, which is empty when there are no more
+// objects.
+// Objects are paged by keyset: unlike using offsets, retrieving pages deep
+// within a collection is as fast as retrieving the first page.
+func (coll *Collection) Page(dest any, opts PageOptions) (string, error) {
+	return coll.PageContext(context.Background(), dest, opts)
+}
+
+// PageContext retrieves a page of objects like Page, using the provided
+// context.
+func (coll *Collection) PageContext(ctx context.Context, dest any, opts PageOptions) (_ string, err error) {
+	defer coll.observe("Page", time.Now(), &err)
+
+	slice, elemType, err := modelSlice(dest)
+	if err != nil {
+		return "", err
+	}
+
+	size := opts.Size
+	if size < 1 {
+		size = DefaultPageSize
+	}
+
+	query := &kolektor.Query{
+		Filter: opts.Filter,
+		Order:  []kolektor.Order{{Field: "id"}},
+		Limit:  size + 1, // one more to know whether there is a next page
+	}
+	if opts.ByCreated {
+		query.Order = []kolektor.Order{{Field: "created"}, {Field: "id"}}
+	}
+
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return "", err
+		}
+		if query.After, err = c.keyset(opts.ByCreated); err != nil {
+			return "", err
+		}
+	}
+
+	docs, err := coll.documents(ctx, query)
+	if err != nil {
+		return "", err
+	}
+
+	var next string
+	if len(docs) > size {
+		docs = docs[:size]
+		if next, err = encodeCursor(docs[size-1], opts.ByCreated); err != nil {
+			return "", err
+		}
+	}
+
+	if err := coll.decodeObjects(ctx, slice, elemType, docs); err != nil {
+		return "", err
+	}
+
+	return next, nil
+}
+
+// Count returns the number of objects matching filter. When filter is
+// empty, all objects of the collection are counted.
+func (coll *Collection) Count(filter kolektor.Filter) (int64, error) {
+	return coll.CountContext(context.Background(), filter)
+}
+
+// CountContext counts objects like Count, using the provided context.
+func (coll *Collection) CountContext(ctx context.Context, filter kolektor.Filter) (_ int64, err error) {
+	defer coll.observe("Count", time.Now(), &err)
+
+	return coll.ses.store.CountObjects(ctx, coll.model, coll.visible(filter))
+}
+
+// Exists returns whether at least one object matches filter, without
+// retrieving any object.
+func (coll *Collection) Exists(filter kolektor.Filter) (bool, error) {
+	return coll.ExistsContext(context.Background(), filter)
+}
+
+// ExistsContext checks whether objects exist like Exists, using the provided
+// context.
+func (coll *Collection) ExistsContext(ctx context.Context, filter kolektor.Filter) (_ bool, err error) {
+	defer coll.observe("Exists", time.Now(), &err)
+
+	return coll.ses.store.ObjectsExist(ctx, coll.model, coll.visible(filter))
+}
+
+// Sum returns the sum of the numeric values found at field, a path within
+// the JSON documents, of objects matching filter. It returns 0 when
+// there are no values.
+func (coll *Collection) Sum(field string, filter kolektor.Filter) (float64, error) {
+	return coll.SumContext(context.Background(), field, filter)
+}
+
+// SumContext sums values like Sum, using the provided context.
+func (coll *Collection) SumContext(ctx context.Context, field string, filter kolektor.Filter) (_ float64, err error) {
+	defer coll.observe("Sum", time.Now(), &err)
+
+	v, err := coll.aggregateValue(ctx, kolektor.AggSum, field, filter)
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, nil
+	}
+	return *v, nil
+}
+
+// Min returns the minimum of the numeric values found at field, a path within
+// the JSON documents, of objects matching filter. Error stores.ErrNoObject
+// is returned when there are no values.
+func (coll *Collection) Min(field string, filter kolektor.Filter) (float64, error) {
+	return coll.MinContext(context.Background(), field, filter)
+}
+
+// MinContext returns the minimum value like Min, using the provided context.
+func (coll *Collection) MinContext(ctx context.Context, field string, filter kolektor.Filter) (_ float64, err error) {
+	defer coll.observe("Min", time.Now(), &err)
+
+	return coll.mustAggregateValue(ctx, kolektor.AggMin, field, filter)
+}
+
+// Max returns the maximum of the numeric values found at field, a path within
+// the JSON documents, of objects matching filter. Error stores.ErrNoObject
+// is returned when there are no values.
+func (coll *Collection) Max(field string, filter kolektor.Filter) (float64, error) {
+	return coll.MaxContext(context.Background(), field, filter)
+}
+
+// MaxContext returns the maximum value like Max, using the provided context.
+func (coll *Collection) MaxContext(ctx context.Context, field string, filter kolektor.Filter) (_ float64, err error) {
+	defer coll.observe("Max", time.Now(), &err)
+
+	return coll.mustAggregateValue(ctx, kolektor.AggMax, field, filter)
+}
+
+// Avg returns the average of the numeric values found at field, a path within
+// the JSON documents, of objects matching filter. Error stores.ErrNoObject
+// is returned when there are no values.
+func (coll *Collection) Avg(field string, filter kolektor.Filter) (float64, error) {
+	return coll.AvgContext(context.Background(), field, filter)
+}
+
+// AvgContext returns the average value like Avg, using the provided context.
+func (coll *Collection) AvgContext(ctx context.Context, field string, filter kolektor.Filter) (_ float64, err error) {
+	defer coll.observe("Avg", time.Now(), &err)
+
+	return coll.mustAggregateValue(ctx, kolektor.AggAvg, field, filter)
+}
+
+// Aggregate aggregates the values of objects as defined by agg. One result
+// is returned per group, sorted by group, or exactly one when agg does
+// not group.
+func (coll *Collection) Aggregate(agg kolektor.Aggregation) ([]kolektor.AggregateResult, error) {
+	return coll.AggregateContext(context.Background(), agg)
+}
+
+// AggregateContext aggregates values like Aggregate, using the provided
+// context.
+func (coll *Collection) AggregateContext(ctx context.Context, agg kolektor.Aggregation) (_ []kolektor.AggregateResult, err error) {
+	defer coll.observe("Aggregate", time.Now(), &err)
+
+	return coll.aggregate(ctx, agg)
+}
+
+func (coll *Collection) aggregate(ctx context.Context, agg kolektor.Aggregation) ([]kolektor.AggregateResult, error) {
+	agg.Filter = coll.visible(agg.Filter)
+	return coll.ses.store.AggregateObjects(ctx, coll.model, agg)
+}
+
+func (coll *Collection) aggregateValue(ctx context.Context, fn kolektor.AggregateFunc, field string, filter kolektor.Filter) (*float64, error) {
+	if field == "" {
+		return nil, fmt.Errorf("field must not be empty")
+	}
+
+	result, err := coll.aggregate(ctx, kolektor.Aggregation{Func: fn, Field: field, Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result[0].Value, nil
+}
+
+func (coll *Collection) mustAggregateValue(ctx context.Context, fn kolektor.AggregateFunc, field string, filter kolektor.Filter) (float64, error) {
+	v, err := coll.aggregateValue(ctx, fn, field, filter)
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, stores.ErrNoObject{Name: coll.model.CollectionName()}
+	}
+	return *v, nil
+}
+
+// Delete removes obj from the collection. The object is identified using
+// its ID or, when the ID is not available, its UID. When the model is a
+// kolektor.SoftDeleter, the object is soft deleted instead.
+// Error stores.ErrNoObject is returned when the object was not found.
+func (coll *Collection) Delete(obj kolektor.Modeler) error {
+	return coll.DeleteContext(context.Background(), obj)
+}
+
+// DeleteContext removes an object like Delete, using the provided context.
+func (coll *Collection) DeleteContext(ctx context.Context, obj kolektor.Modeler) (err error) {
+	defer coll.observe("Delete", time.Now(), &err)
+
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &kolektor.InvalidObjectError{Type: reflect.TypeOf(obj)}
+	}
+
+	filter, err := objectFilter(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := beforeDelete(ctx, obj); err != nil {
+		return err
+	}
+
+	if err := coll.deleteOne(ctx, filter); err != nil {
+		return err
+	}
+
+	return afterDelete(ctx, obj)
+}
+
+// DeleteByUID removes the object identified by uid from the collection.
+// Error stores.ErrNoObject is returned when the object was not found.
+func (coll *Collection) DeleteByUID(uid string) error {
+	return coll.DeleteByUIDContext(context.Background(), uid)
+}
+
+// DeleteByUIDContext removes an object like DeleteByUID, using the provided
+// context.
+func (coll *Collection) DeleteByUIDContext(ctx context.Context, uid string) (err error) {
+	defer coll.observe("DeleteByUID", time.Now(), &err)
+
+	return coll.deleteOne(ctx, kolektor.Filter{{Field: "uid", Operator: kolektor.OpEqual, Value: uid}})
+}
+
+// DeleteWhere removes all objects matching filter from the collection and
+// returns the number of removed objects. The filter must have at least one
+// condition. Like Delete, objects are soft deleted when the model is
+// a kolektor.SoftDeleter.
+func (coll *Collection) DeleteWhere(filter kolektor.Filter) (int64, error) {
+	return coll.DeleteWhereContext(context.Background(), filter)
+}
+
+// DeleteWhereContext removes objects like DeleteWhere, using the provided
+// context.
+func (coll *Collection) DeleteWhereContext(ctx context.Context, filter kolektor.Filter) (_ int64, err error) {
+	defer coll.observe("DeleteWhere", time.Now(), &err)
+
+	return coll.deleteWhere(ctx, filter)
+}
+
+func (coll *Collection) deleteWhere(ctx context.Context, filter kolektor.Filter) (int64, error) {
+	if coll.softDeletes() {
+		return coll.ses.store.SoftDeleteObjects(ctx, coll.model, filter)
+	}
+	return coll.ses.store.DeleteObjects(ctx, coll.model, filter)
+}
+
+func (coll *Collection) deleteOne(ctx context.Context, filter kolektor.Filter) error {
+	n, err := coll.deleteWhere(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return stores.ErrNoObject{Name: coll.model.CollectionName()}
+	}
+
+	return nil
+}
+
+// modelList returns the elements of objs, which must be a slice of models,
+// as kolektor.Modeler. When the elements are not pointers, their addresses
+// are used.
+func modelList(objs any) ([]kolektor.Modeler, error) {
+	rv := reflect.ValueOf(objs)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("objects must be a slice; got %T", objs)
+	}
+
+	list := make([]kolektor.Modeler, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() != reflect.Pointer {
+			elem = elem.Addr()
+		}
+
+		if elem.IsNil() {
+			return nil, &kolektor.InvalidObjectError{Type: elem.Type()}
+		}
+
+		obj, ok := elem.Interface().(kolektor.Modeler)
+		if !ok {
+			return nil, fmt.Errorf("objects must implement kolektor.Modeler; got %s", elem.Type())
+		}
+		list[i] = obj
+	}
+
+	return list, nil
+}
+
+// documents retrieves all objects matching query as JSON documents.
+func (coll *Collection) documents(ctx context.Context, query *kolektor.Query) ([][]byte, error) {
+	rows, err := coll.ses.store.QueryObjects(ctx, coll.model, coll.visibleQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var docs [][]byte
+	for rows.Next() {
+		docs = append(docs, rows.Document())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// decodeObjects decodes the JSON documents docs into new objects of type
+// elemType, and stores these in slice. The AfterGet hook of each object
+// is called, if any.
+func (coll *Collection) decodeObjects(ctx context.Context, slice reflect.Value, elemType reflect.Type, docs [][]byte) error {
+	result := reflect.MakeSlice(slice.Type(), 0, len(docs))
+	for _, data := range docs {
+		obj := reflect.New(elemType)
+		if err := coll.decode(data, obj.Interface()); err != nil {
+			return err
+		}
+		if err := afterGet(ctx, obj.Interface()); err != nil {
+			return err
+		}
+
+		if slice.Type().Elem().Kind() == reflect.Pointer {
+			result = reflect.Append(result, obj)
+		} else {
+			result = reflect.Append(result, obj.Elem())
+		}
+	}
+
+	slice.Set(result)
+
+	return nil
+}
+
+// modelSlice checks whether dest is a pointer to a slice of models and
+// returns the slice as well as the (non-pointer) type of the models.
+func modelSlice(dest any) (reflect.Value, reflect.Type, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, nil, fmt.Errorf("destination must be a non-nil pointer to a slice; got %T", dest)
+	}
+
+	elemType := rv.Elem().Type().Elem()
+	if elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+
+	if !reflect.PointerTo(elemType).Implements(reflect.TypeOf((*kolektor.Modeler)(nil)).Elem()) {
+		return reflect.Value{}, nil, fmt.Errorf("slice elements must implement kolektor.Modeler; got %s", elemType)
+	}
+
+	return rv.Elem(), elemType, nil
+}
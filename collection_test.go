@@ -3,7 +3,16 @@
 package kolekto
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/geertjanvdk/xkit/xt"
 	"github.com/golistic/kolekto/kolektor"
@@ -64,7 +73,1345 @@ func TestCollection_ByFields(t *testing.T) {
 					"uid": "book1.foo", "isbn13": expISNB13}))
 				xt.Eq(t, expISNB13, book.ISBN13)
 			})
+
+			t.Run("invalid field path", func(t *testing.T) {
+				err := books.GetByFields(&Book{}, kolektor.FieldMap{"isbn-13": "978-3-1194-1744-0"})
+				xt.KO(t, err)
+				xt.Match(t, `invalid field path 'isbn-13'`, err.Error())
+			})
+		})
+
+	}
+}
+
+func TestCollection_Find(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			publisher := "Find Press"
+			booksData := []*Book{
+				{ISBN13: "978-1-4028-9462-6", Title: "Find1", Publisher: publisher, Year: 1999},
+				{ISBN13: "978-1-4028-9463-3", Title: "Find2", Publisher: publisher, Year: 2005},
+				{ISBN13: "978-1-4028-9464-0", Title: "Find3", Publisher: publisher, Year: 2012},
+				{ISBN13: "978-1-4028-9465-7", Title: "Find4", Publisher: publisher},
+			}
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			for _, b := range booksData {
+				xt.OK(t, books.Store(b))
+			}
+
+			byPublisher := kolektor.Condition{Field: "publisher", Operator: kolektor.OpEqual, Value: publisher}
+
+			t.Run("all objects matching filter", func(t *testing.T) {
+				var result []*Book
+				xt.OK(t, books.Find(&result, &kolektor.Query{
+					Filter: kolektor.Filter{byPublisher},
+					Order:  []kolektor.Order{{Field: "id"}},
+				}))
+				xt.Eq(t, 4, len(result))
+				xt.Eq(t, "Find1", result[0].Title)
+				xt.Assert(t, result[0].Meta != nil && result[0].Meta.ID > 0, "expected meta")
+			})
+
+			t.Run("into slice of values", func(t *testing.T) {
+				var result []Book
+				xt.OK(t, books.Find(&result, &kolektor.Query{
+					Filter: kolektor.Filter{byPublisher},
+				}))
+				xt.Eq(t, 4, len(result))
+			})
+
+			t.Run("comparison operators", func(t *testing.T) {
+				cases := []struct {
+					cond kolektor.Condition
+					exp  int
+				}{
+					{kolektor.Condition{Field: "year", Operator: kolektor.OpGreater, Value: 2000}, 2},
+					{kolektor.Condition{Field: "year", Operator: kolektor.OpLessEqual, Value: 2005}, 2},
+					{kolektor.Condition{Field: "title", Operator: kolektor.OpNotEqual, Value: "Find1"}, 3},
+					{kolektor.Condition{Field: "title", Operator: kolektor.OpLike, Value: "Find%"}, 4},
+					{kolektor.Condition{Field: "year", Operator: kolektor.OpLike, Value: 2005}, 1},
+					{kolektor.Condition{Field: "title", Operator: kolektor.OpIn, Value: []string{"Find2", "Find3"}}, 2},
+					{kolektor.Condition{Field: "year", Operator: kolektor.OpIsNull}, 1},
+					{kolektor.Condition{Field: "year", Operator: kolektor.OpIsNotNull}, 3},
+					{kolektor.Condition{Field: "id", Operator: kolektor.OpGreater, Value: 0}, 4},
+				}
+
+				for _, c := range cases {
+					t.Run(string(c.cond.Operator), func(t *testing.T) {
+						var result []*Book
+						xt.OK(t, books.Find(&result, &kolektor.Query{
+							Filter: kolektor.Filter{byPublisher, c.cond},
+						}))
+						xt.Eq(t, c.exp, len(result))
+					})
+				}
+			})
+
+			t.Run("order, limit and offset", func(t *testing.T) {
+				var result []*Book
+				xt.OK(t, books.Find(&result, &kolektor.Query{
+					Filter: kolektor.Filter{
+						byPublisher,
+						{Field: "year", Operator: kolektor.OpIsNotNull},
+					},
+					Order:  []kolektor.Order{{Field: "year", Descending: true}},
+					Limit:  2,
+					Offset: 1,
+				}))
+				xt.Eq(t, 2, len(result))
+				xt.Eq(t, 2005, result[0].Year)
+				xt.Eq(t, 1999, result[1].Year)
+			})
+
+			t.Run("invalid destination", func(t *testing.T) {
+				var result []string
+				xt.KO(t, books.Find(&result, nil))
+				xt.KO(t, books.Find(result, nil))
+			})
+
+			t.Run("invalid operator", func(t *testing.T) {
+				var result []*Book
+				xt.KO(t, books.Find(&result, &kolektor.Query{
+					Filter: kolektor.Filter{{Field: "year", Operator: "~", Value: 1}},
+				}))
+			})
+		})
+	}
+}
+
+func TestCollection_Delete(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			publisher := "Delete Press"
+			booksData := []*Book{
+				{ISBN13: "978-0-3064-0615-7", Title: "Delete1", Publisher: publisher},
+				{ISBN13: "978-0-3064-0616-4", Title: "Delete2", Publisher: publisher},
+				{ISBN13: "978-0-3064-0617-1", Title: "Delete3", Publisher: publisher, Year: 2001},
+				{ISBN13: "978-0-3064-0618-8", Title: "Delete4", Publisher: publisher, Year: 2002},
+			}
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			for _, b := range booksData {
+				xt.OK(t, books.Store(b))
+			}
+
+			t.Run("delete object", func(t *testing.T) {
+				xt.OK(t, books.Delete(booksData[0]))
+				xt.Assert(t, errors.Is(books.Get(&Book{}, booksData[0].Meta.ID), stores.ErrNoObject{Name: "books"}))
+
+				err := books.Delete(booksData[0])
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+
+			t.Run("delete by UID", func(t *testing.T) {
+				xt.OK(t, books.DeleteByUID(booksData[1].Meta.UID))
+
+				err := books.DeleteByUID(booksData[1].Meta.UID)
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+
+			t.Run("delete where", func(t *testing.T) {
+				n, err := books.DeleteWhere(kolektor.Filter{
+					{Field: "publisher", Operator: kolektor.OpEqual, Value: publisher},
+					{Field: "year", Operator: kolektor.OpGreater, Value: 2000},
+				})
+				xt.OK(t, err)
+				xt.Eq(t, int64(2), n)
+
+				_, err = books.DeleteWhere(nil)
+				xt.KO(t, err, "expected error without conditions")
+			})
+		})
+	}
+}
+
+func TestCollection_Store_version(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			book := &Book{ISBN13: "978-0-5960-0712-6", Title: "Version1"}
+			xt.OK(t, books.Store(book))
+			xt.Eq(t, int64(1), book.Meta.Version)
+
+			t.Run("version is incremented", func(t *testing.T) {
+				book.Title = "Version2"
+				xt.OK(t, books.Store(book))
+				xt.Eq(t, int64(2), book.Meta.Version)
+
+				got := &Book{}
+				xt.OK(t, books.Get(got, book.Meta.ID))
+				xt.Eq(t, int64(2), got.Meta.Version)
+			})
+
+			t.Run("concurrent update conflicts", func(t *testing.T) {
+				first := &Book{}
+				xt.OK(t, books.Get(first, book.Meta.ID))
+				second := &Book{}
+				xt.OK(t, books.Get(second, book.Meta.ID))
+
+				first.Title = "First Writer"
+				xt.OK(t, books.Store(first))
+
+				second.Title = "Second Writer"
+				err := books.Store(second)
+				xt.Assert(t, errors.As(err, &stores.ErrConflict{}), "expected stores.ErrConflict")
+
+				got := &Book{}
+				xt.OK(t, books.Get(got, book.Meta.ID))
+				xt.Eq(t, "First Writer", got.Title)
+				xt.Eq(t, int64(3), got.Meta.Version)
+			})
+
+			t.Run("retry after conflict", func(t *testing.T) {
+				ctx := context.Background()
+
+				stale := &Book{}
+				xt.OK(t, books.Get(stale, book.Meta.ID))
+
+				latest := &Book{}
+				xt.OK(t, books.Get(latest, book.Meta.ID))
+				latest.Title = "Latest Writer"
+				xt.OK(t, books.Store(latest))
+
+				stale.Title = "Stale Writer"
+				for i := 0; i < 2; i++ {
+					err := books.Store(stale)
+					xt.Assert(t, errors.As(err, &stores.ErrConflict{}), "expected stores.ErrConflict")
+					xt.Assert(t, stale.Meta != nil, "expected metadata to be kept")
+					xt.Eq(t, book.Meta.ID, stale.Meta.ID)
+				}
+
+				n, err := books.CountContext(ctx, kolektor.Filter{{Field: "isbn13", Operator: kolektor.OpEqual, Value: book.ISBN13}})
+				xt.OK(t, err)
+				xt.Eq(t, int64(1), n)
+			})
+
+			t.Run("update of removed object", func(t *testing.T) {
+				got := &Book{}
+				xt.OK(t, books.Get(got, book.Meta.ID))
+				xt.OK(t, books.Delete(got))
+
+				err := books.Store(got)
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+		})
+	}
+}
+
+func TestCollection_SoftDelete(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Note{}))
+			notes, err := session.Collection(&Note{})
+			xt.OK(t, err)
+
+			notesData := []*Note{{Text: "first"}, {Text: "second"}, {Text: "third"}}
+			xt.OK(t, notes.StoreManyContext(ctx, notesData))
+
+			t.Run("soft deleted objects are hidden", func(t *testing.T) {
+				xt.OK(t, notes.DeleteContext(ctx, notesData[0]))
+
+				err := notes.Get(&Note{}, notesData[0].Meta.ID)
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+
+				n, err := notes.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, int64(2), n)
+
+				var found []*Note
+				xt.OK(t, notes.FindContext(ctx, &found, nil))
+				xt.Eq(t, 2, len(found))
+
+				xt.OK(t, notes.FindContext(ctx, &found, &kolektor.Query{IncludeDeleted: true}))
+				xt.Eq(t, 3, len(found))
+
+				xt.OK(t, notes.FindContext(ctx, &found, &kolektor.Query{
+					Filter: kolektor.Filter{{Field: "deleted", Operator: kolektor.OpIsNotNull}},
+				}))
+				xt.Eq(t, 1, len(found))
+				xt.Eq(t, "first", found[0].Text)
+				xt.Assert(t, found[0].Meta.Deleted != nil, "expected deleted timestamp")
+
+				err = notes.DeleteContext(ctx, notesData[0])
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+
+			t.Run("restore", func(t *testing.T) {
+				xt.OK(t, notes.RestoreContext(ctx, notesData[0]))
+
+				note := &Note{}
+				xt.OK(t, notes.Get(note, notesData[0].Meta.ID))
+				xt.Assert(t, note.Meta.Deleted == nil, "expected no deleted timestamp")
+
+				err := notes.RestoreContext(ctx, notesData[0])
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+
+			t.Run("purge", func(t *testing.T) {
+				n, err := notes.DeleteWhereContext(ctx, kolektor.Filter{
+					{Field: "text", Operator: kolektor.OpIn, Value: []string{"first", "second"}},
+				})
+				xt.OK(t, err)
+				xt.Eq(t, int64(2), n)
+
+				n, err = notes.PurgeContext(ctx, time.Hour)
+				xt.OK(t, err)
+				xt.Eq(t, int64(0), n)
+
+				n, err = notes.PurgeContext(ctx, 0)
+				xt.OK(t, err)
+				xt.Eq(t, int64(2), n)
+
+				n, err = notes.CountContext(ctx, kolektor.Filter{{Field: "deleted", Operator: kolektor.OpIsNotNull}})
+				xt.OK(t, err)
+				xt.Eq(t, int64(0), n)
+				n, err = notes.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, int64(1), n)
+			})
+
+			t.Run("purge only objects deleted long ago", func(t *testing.T) {
+				aged := []*Note{{Text: "old"}, {Text: "recent"}}
+				xt.OK(t, notes.StoreManyContext(ctx, aged))
+				xt.OK(t, notes.DeleteContext(ctx, aged[0]))
+				xt.OK(t, notes.DeleteContext(ctx, aged[1]))
+
+				// move the deletion of the old note into the past
+				var got []*Note
+				xt.OK(t, notes.FindContext(ctx, &got, &kolektor.Query{
+					Filter:         kolektor.Filter{{Field: "text", Operator: kolektor.OpEqual, Value: "old"}},
+					IncludeDeleted: true,
+				}))
+				xt.Eq(t, 1, len(got))
+				past := time.Now().UTC().Add(-2 * time.Hour)
+				got[0].Meta.Deleted = &past
+				doc, err := json.Marshal(got[0])
+				xt.OK(t, err)
+				_, err = notes.ImportContext(ctx, bytes.NewReader(doc),
+					ImportOptions{OnConflict: kolektor.ImportOverwrite})
+				xt.OK(t, err)
+
+				n, err := notes.PurgeContext(ctx, time.Hour)
+				xt.OK(t, err)
+				xt.Eq(t, int64(1), n)
+
+				var found []*Note
+				xt.OK(t, notes.FindContext(ctx, &found, &kolektor.Query{
+					Filter: kolektor.Filter{{Field: "deleted", Operator: kolektor.OpIsNotNull}},
+				}))
+				xt.Eq(t, 1, len(found))
+				xt.Eq(t, "recent", found[0].Text)
+			})
+
+			t.Run("not soft deleting", func(t *testing.T) {
+				books, err := session.Collection(&Book{})
+				xt.OK(t, err)
+				_, err = books.PurgeContext(ctx, 0)
+				xt.KO(t, err)
+			})
+		})
+	}
+}
+
+func TestCollection_Upsert(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Edition{}))
+			editions, err := session.Collection(&Edition{})
+			xt.OK(t, err)
+
+			t.Run("using fields of unique index", func(t *testing.T) {
+				first := &Edition{ISBN13: "9780099483526", Format: "paperback"}
+				meta, inserted, err := editions.UpsertContext(ctx, first, "isbn13")
+				xt.OK(t, err)
+				xt.Assert(t, inserted, "expected insert")
+				xt.Eq(t, int64(1), meta.Version)
+				xt.Eq(t, meta.ID, first.Meta.ID)
+
+				second := &Edition{ISBN13: "9780099483526", Format: "hardcover"}
+				meta, inserted, err = editions.UpsertContext(ctx, second, "isbn13")
+				xt.OK(t, err)
+				xt.Assert(t, !inserted, "expected update")
+				xt.Eq(t, first.Meta.ID, meta.ID)
+				xt.Eq(t, first.Meta.UID, meta.UID)
+				xt.Eq(t, int64(2), meta.Version)
+
+				got := &Edition{}
+				xt.OK(t, editions.Get(got, first.Meta.ID))
+				xt.Eq(t, "hardcover", got.Format)
+
+				n, err := editions.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, int64(1), n)
+			})
+
+			t.Run("using uid", func(t *testing.T) {
+				obj := &Edition{ISBN13: "9780140449136", Format: "paperback"}
+				obj.Meta = &kolektor.Meta{UID: "edition-1"}
+
+				if storeKind == kolektor.MySQL {
+					// would also update the object with the same ISBN
+					_, _, err := editions.UpsertContext(ctx, obj)
+					xt.KO(t, err)
+					xt.Match(t, `cannot upsert using uid; model has unique index uq_editions_isbn13`, err.Error())
+					return
+				}
+
+				_, inserted, err := editions.UpsertContext(ctx, obj)
+				xt.OK(t, err)
+				xt.Assert(t, inserted, "expected insert")
+
+				obj = &Edition{ISBN13: "9780140449136", Format: "ebook"}
+				obj.Meta = &kolektor.Meta{UID: "edition-1"}
+				meta, inserted, err := editions.UpsertContext(ctx, obj, "uid")
+				xt.OK(t, err)
+				xt.Assert(t, !inserted, "expected update")
+				xt.Eq(t, "edition-1", meta.UID)
+				xt.Eq(t, int64(2), meta.Version)
+
+				// UIDs are unique within a collection
+				obj = &Edition{ISBN13: "9780140449137"}
+				obj.Meta = &kolektor.Meta{UID: "edition-1"}
+				xt.KO(t, editions.Store(obj))
+			})
+
+			t.Run("using index name", func(t *testing.T) {
+				xt.OK(t, session.RemoveCollection(&Book{}))
+				books, err := session.Collection(&Book{})
+				xt.OK(t, err)
+
+				_, inserted, err := books.UpsertContext(ctx, &Book{ISBN13: "9780099483526", Title: "Old"}, "uq_books_isbn13")
+				xt.OK(t, err)
+				xt.Assert(t, inserted, "expected insert")
+
+				_, inserted, err = books.UpsertContext(ctx, &Book{ISBN13: "9780099483526", Title: "New"}, "uq_books_isbn13")
+				xt.OK(t, err)
+				xt.Assert(t, !inserted, "expected update")
+			})
+
+			t.Run("more than one unique index", func(t *testing.T) {
+				if storeKind != kolektor.MySQL {
+					t.Skip("only MySQL updates objects conflicting with any unique index")
+				}
+
+				xt.OK(t, session.RemoveCollection(&Account{}))
+				accounts, err := session.Collection(&Account{})
+				xt.OK(t, err)
+
+				_, _, err = accounts.UpsertContext(ctx, &Account{Email: "alice@example.com", Login: "alice"}, "email")
+				xt.KO(t, err)
+				xt.Match(t, `cannot upsert using unique index uq_accounts_email; model has unique index uq_accounts_login`, err.Error())
+			})
+
+			t.Run("no unique index", func(t *testing.T) {
+				_, _, err := editions.UpsertContext(ctx, &Edition{ISBN13: "9780140449136"}, "format")
+				xt.KO(t, err)
+				xt.Match(t, `no unique index on format`, err.Error())
+			})
+		})
+	}
+}
+
+func TestCollection_ExportImport(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Note{}))
+			notes, err := session.Collection(&Note{})
+			xt.OK(t, err)
+
+			notesData := []*Note{{Text: "first"}, {Text: "second"}, {Text: "third"}}
+			xt.OK(t, notes.StoreManyContext(ctx, notesData))
+			xt.OK(t, notes.DeleteContext(ctx, notesData[1]))
+
+			var buf bytes.Buffer
+			n, err := notes.ExportContext(ctx, &buf)
+			xt.OK(t, err)
+			xt.Eq(t, int64(3), n)
+
+			exported := buf.String()
+			lines := strings.Split(strings.TrimSpace(exported), "\n")
+			xt.Eq(t, 3, len(lines))
+			xt.Match(t, `"uid":"`+notesData[0].Meta.UID+`"`, lines[0])
+
+			t.Run("keeping IDs", func(t *testing.T) {
+				xt.OK(t, session.RemoveCollection(&Note{}))
+				notes, err := session.Collection(&Note{})
+				xt.OK(t, err)
+
+				n, err := notes.ImportContext(ctx, strings.NewReader(exported), ImportOptions{KeepIDs: true})
+				xt.OK(t, err)
+				xt.Eq(t, int64(3), n)
+
+				var found []*Note
+				xt.OK(t, notes.FindContext(ctx, &found, &kolektor.Query{
+					Order:          []kolektor.Order{{Field: "id"}},
+					IncludeDeleted: true,
+				}))
+				xt.Eq(t, 3, len(found))
+				for i, note := range found {
+					xt.Eq(t, notesData[i].Meta.ID, note.Meta.ID)
+					xt.Eq(t, notesData[i].Meta.UID, note.Meta.UID)
+					xt.Eq(t, notesData[i].Text, note.Text)
+					xt.Assert(t, notesData[i].Meta.Created.Equal(note.Meta.Created), "expected same creation time")
+				}
+				xt.Assert(t, found[1].Meta.Deleted != nil, "expected soft deleted object")
+
+				// new objects get IDs following the imported ones
+				note := &Note{Text: "fourth"}
+				xt.OK(t, notes.Store(note))
+				xt.Eq(t, int64(4), note.Meta.ID)
+			})
+
+			t.Run("existing UIDs", func(t *testing.T) {
+				_, err := notes.ImportContext(ctx, strings.NewReader(exported), ImportOptions{})
+				xt.KO(t, err)
+
+				n, err := notes.ImportContext(ctx, strings.NewReader(exported), ImportOptions{OnConflict: kolektor.ImportSkip})
+				xt.OK(t, err)
+				xt.Eq(t, int64(0), n)
+
+				xt.OK(t, notes.StoreContext(ctx, &Note{Model: kolektor.Model{Meta: notesData[0].Meta}, Text: "changed"}))
+				n, err = notes.ImportContext(ctx, strings.NewReader(exported), ImportOptions{OnConflict: kolektor.ImportOverwrite})
+				xt.OK(t, err)
+				xt.Eq(t, int64(3), n)
+
+				note := &Note{}
+				xt.OK(t, notes.Get(note, notesData[0].Meta.ID))
+				xt.Eq(t, "first", note.Text)
+			})
+
+			t.Run("new UIDs", func(t *testing.T) {
+				n, err := notes.ImportContext(ctx, strings.NewReader(exported), ImportOptions{NewUIDs: true})
+				xt.OK(t, err)
+				xt.Eq(t, int64(3), n)
+
+				n, err = notes.CountContext(ctx, kolektor.Filter{{Field: "text", Operator: kolektor.OpEqual, Value: "first"}})
+				xt.OK(t, err)
+				xt.Eq(t, int64(2), n)
+			})
+
+			t.Run("invalid document", func(t *testing.T) {
+				_, err := notes.ImportContext(ctx, strings.NewReader(`{"text": "ok"}`+"\n"+`["not", "an", "object"]`), ImportOptions{})
+				xt.KO(t, err)
+				xt.Match(t, `document 2`, err.Error())
+			})
+		})
+	}
+}
+
+func TestCollection_Patch(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Book{}))
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			book := &Book{ISBN13: "9780099483526", Title: "Old Title", Authors: []string{"Jane"}, Year: 2004}
+			xt.OK(t, books.Store(book))
+
+			t.Run("merge patch", func(t *testing.T) {
+				meta, err := books.PatchContext(ctx, book.Meta.UID, kolektor.MergePatch(`{"title": "New Title", "year": null}`))
+				xt.OK(t, err)
+				xt.Eq(t, book.Meta.ID, meta.ID)
+				xt.Eq(t, int64(2), meta.Version)
+
+				got := &Book{}
+				xt.OK(t, books.Get(got, book.Meta.ID))
+				xt.Eq(t, "New Title", got.Title)
+				xt.Eq(t, 0, got.Year)
+				xt.Eq(t, []string{"Jane"}, got.Authors)
+			})
+
+			t.Run("JSON patch", func(t *testing.T) {
+				meta, err := books.PatchContext(ctx, book.Meta.UID, kolektor.JSONPatch{
+					{Op: kolektor.PatchTest, Path: "/title", Value: "New Title"},
+					{Op: kolektor.PatchAdd, Path: "/authors/0", Value: "Alice"},
+					{Op: kolektor.PatchAdd, Path: "/authors/-", Value: "Bob"},
+					{Op: kolektor.PatchReplace, Path: "/publisher", Value: "Vintage"},
+					{Op: kolektor.PatchCopy, From: "/publisher", Path: "/title"},
+				})
+				xt.OK(t, err)
+				xt.Eq(t, int64(3), meta.Version)
+
+				got := &Book{}
+				xt.OK(t, books.Get(got, book.Meta.ID))
+				xt.Eq(t, []string{"Alice", "Jane", "Bob"}, got.Authors)
+				xt.Eq(t, "Vintage", got.Publisher)
+				xt.Eq(t, "Vintage", got.Title)
+
+				_, err = books.PatchContext(ctx, book.Meta.UID, kolektor.JSONPatch{
+					{Op: kolektor.PatchMove, From: "/title", Path: "/subtitle"},
+					{Op: kolektor.PatchRemove, Path: "/authors/1"},
+				})
+				xt.OK(t, err)
+
+				got = &Book{}
+				xt.OK(t, books.Get(got, book.Meta.ID))
+				xt.Eq(t, []string{"Alice", "Bob"}, got.Authors)
+				xt.Eq(t, "", got.Title)
+			})
+
+			t.Run("test fails", func(t *testing.T) {
+				_, err := books.PatchContext(ctx, book.Meta.UID, kolektor.JSONPatch{
+					{Op: kolektor.PatchReplace, Path: "/publisher", Value: "Penguin"},
+					{Op: kolektor.PatchTest, Path: "/year", Value: 1999},
+				})
+				xt.Assert(t, errors.As(err, &stores.ErrPatch{}), "expected stores.ErrPatch")
+
+				_, err = books.PatchContext(ctx, book.Meta.UID, kolektor.JSONPatch{
+					{Op: kolektor.PatchRemove, Path: "/year"},
+				})
+				xt.Assert(t, errors.As(err, &stores.ErrPatch{}), "expected stores.ErrPatch")
+
+				got := &Book{}
+				xt.OK(t, books.Get(got, book.Meta.ID))
+				xt.Eq(t, "Vintage", got.Publisher)
+				xt.Eq(t, int64(4), got.Meta.Version)
+			})
+
+			t.Run("invalid patch", func(t *testing.T) {
+				_, err := books.PatchContext(ctx, book.Meta.UID, kolektor.MergePatch(`["not", "an", "object"]`))
+				xt.KO(t, err)
+
+				_, err = books.PatchContext(ctx, book.Meta.UID, kolektor.JSONPatch{{Op: "upsert", Path: "/title"}})
+				xt.KO(t, err)
+
+				_, err = books.PatchContext(ctx, book.Meta.UID, kolektor.JSONPatch{{Op: kolektor.PatchRemove, Path: ""}})
+				xt.KO(t, err)
+			})
+
+			t.Run("object not available", func(t *testing.T) {
+				_, err := books.PatchContext(ctx, "no such uid", kolektor.MergePatch(`{"title": "Title"}`))
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+
+			t.Run("soft deleted", func(t *testing.T) {
+				xt.OK(t, session.RemoveCollection(&Note{}))
+				notes, err := session.Collection(&Note{})
+				xt.OK(t, err)
+
+				note := &Note{Text: "first"}
+				xt.OK(t, notes.Store(note))
+				xt.OK(t, notes.DeleteContext(ctx, note))
+
+				_, err = notes.PatchContext(ctx, note.Meta.UID, kolektor.MergePatch(`{"text": "second"}`))
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+		})
+	}
+}
+
+func TestCollection_hooks(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Task{}))
+			tasks, err := session.Collection(&Task{})
+			xt.OK(t, err)
+
+			t.Run("store", func(t *testing.T) {
+				task := &Task{Title: "  Write tests "}
+				xt.OK(t, tasks.StoreContext(ctx, task))
+				xt.Eq(t, []string{"BeforeStore", "AfterStore"}, task.calls)
+				xt.Eq(t, "Write tests", task.Title)
+
+				got := &Task{}
+				xt.OK(t, tasks.GetContext(ctx, got, task.Meta.ID))
+				xt.Eq(t, []string{"AfterGet"}, got.calls)
+				xt.Eq(t, "Write tests", got.Title)
+			})
+
+			t.Run("before store aborts", func(t *testing.T) {
+				err := tasks.StoreContext(ctx, &Task{Title: " "})
+				xt.KO(t, err)
+				xt.Eq(t, "title is required", err.Error())
+
+				list := []*Task{{Title: "Valid"}, {Title: ""}}
+				xt.KO(t, tasks.StoreManyContext(ctx, list))
+				xt.Assert(t, list[0].Meta == nil, "expected no object to be stored")
+
+				n, err := tasks.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, 1, n)
+			})
+
+			t.Run("store many", func(t *testing.T) {
+				list := []*Task{{Title: "Review"}, {Title: "Release", Locked: true}}
+				xt.OK(t, tasks.StoreManyContext(ctx, list))
+				for _, task := range list {
+					xt.Eq(t, []string{"BeforeStore", "AfterStore"}, task.calls)
+				}
+			})
+
+			t.Run("find and iterate", func(t *testing.T) {
+				var found []*Task
+				xt.OK(t, tasks.FindContext(ctx, &found, nil))
+				xt.Eq(t, 3, len(found))
+				for _, task := range found {
+					xt.Eq(t, []string{"AfterGet"}, task.calls)
+				}
+
+				it, err := tasks.IterateContext(ctx, nil)
+				xt.OK(t, err)
+				defer func() { _ = it.Close() }()
+				for it.Next() {
+					task := &Task{}
+					xt.OK(t, it.Scan(task))
+					xt.Eq(t, []string{"AfterGet"}, task.calls)
+				}
+				xt.OK(t, it.Err())
+			})
+
+			t.Run("delete", func(t *testing.T) {
+				locked := &Task{}
+				xt.OK(t, tasks.GetByFieldsContext(ctx, locked, map[string]any{"title": "Release"}))
+				err := tasks.DeleteContext(ctx, locked)
+				xt.KO(t, err)
+				xt.Eq(t, "task is locked", err.Error())
+
+				task := &Task{}
+				xt.OK(t, tasks.GetByFieldsContext(ctx, task, map[string]any{"title": "Review"}))
+				xt.OK(t, tasks.DeleteContext(ctx, task))
+				xt.Eq(t, []string{"AfterGet", "BeforeDelete", "AfterDelete"}, task.calls)
+
+				n, err := tasks.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, 2, n)
+			})
+		})
+	}
+}
+
+func TestCollection_validate(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Concert{}))
+			concerts, err := session.Collection(&Concert{})
+			xt.OK(t, err)
+
+			t.Run("valid", func(t *testing.T) {
+				concert := &Concert{Band: "Toto", Seats: 100, Sold: 100}
+				xt.OK(t, concerts.StoreContext(ctx, concert))
+				xt.Eq(t, int64(1), concert.Meta.Version)
+
+				// metadata is not part of the validated document
+				concert.Sold = 99
+				xt.OK(t, concerts.StoreContext(ctx, concert))
+			})
+
+			t.Run("Validate fails", func(t *testing.T) {
+				err := concerts.StoreContext(ctx, &Concert{Band: "Toto", Seats: 100, Sold: 101})
+				xt.Assert(t, errors.As(err, &stores.ErrInvalid{}), "expected stores.ErrInvalid")
+				xt.Match(t, `sold more than 100 seats`, err.Error())
+			})
+
+			t.Run("schema fails", func(t *testing.T) {
+				err := concerts.StoreContext(ctx, &Concert{Seats: 100})
+				xt.Assert(t, errors.As(err, &stores.ErrInvalid{}), "expected stores.ErrInvalid")
+
+				err = concerts.StoreManyContext(ctx, []*Concert{
+					{Band: "Toto", Seats: 10},
+					{Band: "Toto", Seats: 0},
+				})
+				xt.Assert(t, errors.As(err, &stores.ErrInvalid{}), "expected stores.ErrInvalid")
+
+				n, err := concerts.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, 1, n)
+			})
+		})
+	}
+}
+
+func TestCollection_schema(t *testing.T) {
+	t.Run("invalid schema", func(t *testing.T) {
+		_, err := compileSchema(&badSchema{})
+		xt.KO(t, err)
+	})
+
+	t.Run("remote references are not loaded", func(t *testing.T) {
+		_, err := compileSchema(&badSchema{schema: `{"$ref": "https://example.com/schema.json"}`})
+		xt.KO(t, err)
+	})
+}
+
+type badSchema struct {
+	Concert
+	schema string
+}
+
+func (b badSchema) JSONSchema() string {
+	if b.schema == "" {
+		return `{"type": 1}`
+	}
+	return b.schema
+}
+
+func TestCollection_upgrade(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Person{}))
+			oldPeople, err := session.Collection(&PersonV1{})
+			xt.OK(t, err)
+			oldPerson := &PersonV1{Name: "Ada Lovelace"}
+			xt.OK(t, oldPeople.Store(oldPerson))
+
+			people, err := session.Collection(&Person{})
+			xt.OK(t, err)
+
+			t.Run("document is upgraded when read", func(t *testing.T) {
+				person := &Person{}
+				xt.OK(t, people.Get(person, oldPerson.Meta.ID))
+				xt.Eq(t, "Ada", person.First)
+				xt.Eq(t, "Lovelace", person.Last)
+				xt.Eq(t, 1, person.Meta.SchemaVersion)
+
+				it, err := people.IterateContext(ctx, nil)
+				xt.OK(t, err)
+				defer func() { _ = it.Close() }()
+				xt.Assert(t, it.Next())
+				scanned := &Person{}
+				xt.OK(t, it.Scan(scanned))
+				xt.Eq(t, "Lovelace", scanned.Last)
+			})
+
+			t.Run("schema version is recorded when stored", func(t *testing.T) {
+				person := &Person{}
+				xt.OK(t, people.Get(person, oldPerson.Meta.ID))
+				xt.OK(t, people.Store(person))
+				xt.Eq(t, 3, person.Meta.SchemaVersion)
+
+				got := &Person{}
+				xt.OK(t, people.Get(got, oldPerson.Meta.ID))
+				xt.Eq(t, "Ada", got.First)
+				xt.Eq(t, 3, got.Meta.SchemaVersion)
+			})
+
+			t.Run("upgraders must match schema version", func(t *testing.T) {
+				_, err := session.Collection(&badMigrator{})
+				xt.KO(t, err)
+			})
+		})
+	}
+}
+
+type badMigrator struct {
+	Person
+}
+
+func (b badMigrator) Upgraders() []kolektor.Upgrader {
+	return b.Person.Upgraders()[:1]
+}
+
+func TestCollection_StoreMany(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+			books.SetChunkSize(2)
+
+			publisher := "StoreMany Press"
+			booksData := []*Book{
+				{ISBN13: "978-1-8602-3001-1", Title: "Many1", Publisher: publisher},
+				{ISBN13: "978-1-8602-3002-8", Title: "Many2", Publisher: publisher},
+				{ISBN13: "978-1-8602-3003-5", Title: "Many3", Publisher: publisher},
+			}
+			booksData[1].Meta = &kolektor.Meta{UID: "store-many-2"}
+
+			t.Run("insert objects in chunks", func(t *testing.T) {
+				xt.OK(t, books.StoreMany(booksData))
+
+				for _, b := range booksData {
+					xt.Assert(t, b.Meta != nil && b.Meta.ID > 0, "expected meta with ID")
+					stored := &Book{}
+					xt.OK(t, books.Get(stored, b.Meta.ID))
+					xt.Eq(t, b.Title, stored.Title)
+					xt.Eq(t, b.Meta.UID, stored.Meta.UID)
+				}
+				xt.Eq(t, "store-many-2", booksData[1].Meta.UID)
+			})
+
+			t.Run("update and insert objects", func(t *testing.T) {
+				booksData[0].Title = "Many1 Revised"
+				more := []Book{
+					*booksData[0],
+					{ISBN13: "978-1-8602-3004-2", Title: "Many4", Publisher: publisher},
+				}
+				xt.OK(t, books.StoreMany(more))
+				xt.Eq(t, booksData[0].Meta.ID, more[0].Meta.ID)
+				xt.Assert(t, more[1].Meta != nil && more[1].Meta.ID > 0, "expected meta with ID")
+
+				var result []*Book
+				xt.OK(t, books.Find(&result, &kolektor.Query{
+					Filter: kolektor.Filter{{Field: "publisher", Operator: kolektor.OpEqual, Value: publisher}},
+					Order:  []kolektor.Order{{Field: "id"}},
+				}))
+				xt.Eq(t, 4, len(result))
+				xt.Eq(t, "Many1 Revised", result[0].Title)
+			})
+
+			t.Run("chunks are stored atomically", func(t *testing.T) {
+				revised := *booksData[2]
+				revised.Title = "Many3 Revised"
+				failing := []Book{
+					revised,
+					{ISBN13: booksData[1].ISBN13, Title: "Many2 Duplicate", Publisher: publisher},
+				}
+				xt.KO(t, books.StoreMany(failing))
+
+				stored := &Book{}
+				xt.OK(t, books.Get(stored, booksData[2].Meta.ID))
+				xt.Eq(t, "Many3", stored.Title)
+				xt.Eq(t, booksData[2].Meta.Version, stored.Meta.Version)
+			})
+
+			t.Run("invalid objects", func(t *testing.T) {
+				xt.KO(t, books.StoreMany(&Book{}))
+				xt.KO(t, books.StoreMany([]*Book{nil}))
+			})
+		})
+	}
+}
+
+var benchISBN int64
+
+func benchmarkBooks(n int) []*Book {
+	books := make([]*Book, n)
+	for i := range books {
+		books[i] = &Book{
+			ISBN13: fmt.Sprintf("bench-%d", atomic.AddInt64(&benchISBN, 1)),
+			Title:  "Benchmark",
+		}
+	}
+	return books
+}
+
+func BenchmarkCollection_Store(b *testing.B) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		books, err := session.Collection(&Book{})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(storeKind.String(), func(b *testing.B) {
+			data := benchmarkBooks(b.N)
+			b.ResetTimer()
+			for _, book := range data {
+				if err := books.Store(book); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCollection_StoreMany(b *testing.B) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		books, err := session.Collection(&Book{})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(storeKind.String(), func(b *testing.B) {
+			data := benchmarkBooks(b.N)
+			b.ResetTimer()
+			if err := books.StoreMany(data); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCollection_Page(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			publisher := "Page Press"
+			var booksData []*Book
+			for i := 0; i < 5; i++ {
+				booksData = append(booksData, &Book{
+					ISBN13:    fmt.Sprintf("978-3-1614-8410-%d", i),
+					Title:     fmt.Sprintf("Page%d", i),
+					Publisher: publisher,
+				})
+			}
+			xt.OK(t, books.StoreMany(booksData))
+
+			filter := kolektor.Filter{{Field: "publisher", Operator: kolektor.OpEqual, Value: publisher}}
+
+			for _, byCreated := range []bool{false, true} {
+				t.Run(fmt.Sprintf("by created %v", byCreated), func(t *testing.T) {
+					var titles []string
+					var pages int
+					opts := PageOptions{Filter: filter, Size: 2, ByCreated: byCreated}
+
+					for {
+						var result []*Book
+						next, err := books.Page(&result, opts)
+						xt.OK(t, err)
+						pages++
+						for _, b := range result {
+							titles = append(titles, b.Title)
+						}
+						if next == "" {
+							break
+						}
+						opts.Cursor = next
+					}
+
+					xt.Eq(t, 3, pages)
+					xt.Eq(t, []string{"Page0", "Page1", "Page2", "Page3", "Page4"}, titles)
+				})
+			}
+
+			t.Run("cursor must match ordering", func(t *testing.T) {
+				var result []*Book
+				next, err := books.Page(&result, PageOptions{Filter: filter, Size: 1})
+				xt.OK(t, err)
+
+				_, err = books.Page(&result, PageOptions{
+					Filter: filter, Size: 1, Cursor: next, ByCreated: true})
+				xt.KO(t, err)
+			})
+
+			t.Run("invalid cursor", func(t *testing.T) {
+				var result []*Book
+				_, err := books.Page(&result, PageOptions{Cursor: "not-a-cursor"})
+				xt.KO(t, err)
+			})
+		})
+	}
+}
+
+func TestCollection_Iterate(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			publisher := "Iterate Press"
+			var booksData []*Book
+			for i := 0; i < 4; i++ {
+				booksData = append(booksData, &Book{
+					ISBN13:    fmt.Sprintf("978-0-5961-5203-%d", i),
+					Title:     fmt.Sprintf("Iterate%d", i),
+					Publisher: publisher,
+				})
+			}
+			xt.OK(t, books.StoreMany(booksData))
+
+			query := &kolektor.Query{
+				Filter: kolektor.Filter{{Field: "publisher", Operator: kolektor.OpEqual, Value: publisher}},
+				Order:  []kolektor.Order{{Field: "id"}},
+			}
+
+			t.Run("all objects", func(t *testing.T) {
+				it, err := books.Iterate(query)
+				xt.OK(t, err)
+				defer func() { xt.OK(t, it.Close()) }()
+
+				var titles []string
+				for it.Next() {
+					book := &Book{}
+					xt.OK(t, it.Scan(book))
+					xt.Assert(t, book.Meta != nil && book.Meta.ID > 0, "expected meta")
+					titles = append(titles, book.Title)
+				}
+				xt.OK(t, it.Err())
+				xt.Eq(t, []string{"Iterate0", "Iterate1", "Iterate2", "Iterate3"}, titles)
+				xt.KO(t, it.Scan(&Book{}), "expected error scanning closed iterator")
+			})
+
+			t.Run("close early", func(t *testing.T) {
+				it, err := books.Iterate(query)
+				xt.OK(t, err)
+				xt.Assert(t, it.Next())
+				xt.OK(t, it.Close())
+				xt.OK(t, it.Close())
+				xt.Assert(t, !it.Next())
+				xt.OK(t, it.Err())
+			})
+
+			t.Run("context cancelled", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				it, err := books.IterateContext(ctx, query)
+				xt.OK(t, err)
+				xt.Assert(t, it.Next())
+				cancel()
+
+				for it.Next() {
+				}
+				xt.Assert(t, errors.Is(it.Err(), context.Canceled), "expected context.Canceled")
+			})
+
+			t.Run("no goroutine per iterator", func(t *testing.T) {
+				before := runtime.NumGoroutine()
+
+				var its []*Iterator
+				for i := 0; i < 10; i++ {
+					it, err := books.Iterate(query)
+					xt.OK(t, err)
+					xt.Assert(t, it.Next())
+					its = append(its, it)
+				}
+				// goroutines of earlier tests might still be ending
+				xt.Assert(t, runtime.NumGoroutine() < before+len(its), "expected no goroutine per iterator")
+
+				for _, it := range its {
+					xt.OK(t, it.Close())
+				}
+			})
 		})
+	}
+}
+
+func TestCollection_Aggregate(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			booksData := []*Book{
+				{ISBN13: "978-0-1344-9416-1", Title: "Agg1", Publisher: "Agg Foo", Year: 2000},
+				{ISBN13: "978-0-1344-9416-2", Title: "Agg2", Publisher: "Agg Foo", Year: 2010},
+				{ISBN13: "978-0-1344-9416-3", Title: "Agg3", Publisher: "Agg Bar", Year: 2020},
+				{ISBN13: "978-0-1344-9416-4", Title: "Agg4", Publisher: "Agg Bar"},
+			}
+			xt.OK(t, books.StoreMany(booksData))
+
+			filter := kolektor.Filter{{Field: "publisher", Operator: kolektor.OpLike, Value: "Agg %"}}
+			none := kolektor.Filter{{Field: "publisher", Operator: kolektor.OpEqual, Value: "Agg None"}}
+
+			t.Run("count", func(t *testing.T) {
+				n, err := books.Count(filter)
+				xt.OK(t, err)
+				xt.Eq(t, int64(4), n)
 
+				n, err = books.Count(none)
+				xt.OK(t, err)
+				xt.Eq(t, int64(0), n)
+			})
+
+			t.Run("exists", func(t *testing.T) {
+				exists, err := books.Exists(filter)
+				xt.OK(t, err)
+				xt.Assert(t, exists)
+
+				exists, err = books.Exists(none)
+				xt.OK(t, err)
+				xt.Assert(t, !exists)
+			})
+
+			t.Run("sum, min, max, and avg", func(t *testing.T) {
+				v, err := books.Sum("year", filter)
+				xt.OK(t, err)
+				xt.Eq(t, float64(6030), v)
+
+				v, err = books.Min("year", filter)
+				xt.OK(t, err)
+				xt.Eq(t, float64(2000), v)
+
+				v, err = books.Max("year", filter)
+				xt.OK(t, err)
+				xt.Eq(t, float64(2020), v)
+
+				v, err = books.Avg("year", filter)
+				xt.OK(t, err)
+				xt.Eq(t, float64(2010), v)
+
+				v, err = books.Sum("year", none)
+				xt.OK(t, err)
+				xt.Eq(t, float64(0), v)
+
+				_, err = books.Max("year", none)
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+
+			t.Run("group by", func(t *testing.T) {
+				result, err := books.Aggregate(kolektor.Aggregation{
+					Func:    kolektor.AggCount,
+					Field:   "year",
+					GroupBy: "publisher",
+					Filter:  filter,
+				})
+				xt.OK(t, err)
+				xt.Eq(t, 2, len(result))
+				xt.Eq(t, "Agg Bar", *result[0].Group)
+				xt.Eq(t, int64(1), result[0].Count)
+				xt.Assert(t, result[0].Value == nil, "expected no value when counting")
+				xt.Eq(t, "Agg Foo", *result[1].Group)
+				xt.Eq(t, int64(2), result[1].Count)
+			})
+
+			t.Run("non-numeric values are ignored", func(t *testing.T) {
+				result, err := books.Aggregate(kolektor.Aggregation{
+					Func:   kolektor.AggCount,
+					Field:  "title",
+					Filter: filter,
+				})
+				xt.OK(t, err)
+				xt.Eq(t, 1, len(result))
+				xt.Eq(t, int64(0), result[0].Count)
+			})
+
+			t.Run("invalid aggregation", func(t *testing.T) {
+				_, err := books.Aggregate(kolektor.Aggregation{Func: "MEDIAN", Field: "year"})
+				xt.KO(t, err)
+				_, err = books.Aggregate(kolektor.Aggregation{Func: kolektor.AggSum})
+				xt.KO(t, err)
+			})
+		})
+	}
+}
+
+type badlyNamed struct {
+	kolektor.Model
+}
+
+func (badlyNamed) CollectionName() string {
+	return "books; DROP TABLE books"
+}
+
+func TestCollection_Errors(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Edition{}))
+			editions, err := session.Collection(&Edition{})
+			xt.OK(t, err)
+
+			edition := &Edition{ISBN13: "978-0-13-468599-1", Format: "paperback"}
+			xt.OK(t, editions.Store(edition))
+
+			t.Run("not found", func(t *testing.T) {
+				err := editions.Get(&Edition{}, "no-such-uid")
+				xt.Assert(t, errors.Is(err, kolektor.ErrNotFound))
+				xt.Assert(t, errors.As(err, &stores.ErrNoObject{}), "expected stores.ErrNoObject")
+			})
+
+			t.Run("collection not found", func(t *testing.T) {
+				coll, err := newCollection(session, &noSuchCollection{})
+				xt.OK(t, err)
+				_, err = coll.CountContext(ctx, nil)
+				xt.Assert(t, errors.Is(err, kolektor.ErrNotFound))
+
+				_, err = session.OpenCollectionContext(ctx, &noSuchCollection{})
+				xt.Assert(t, errors.Is(err, kolektor.ErrNotFound))
+			})
+
+			t.Run("duplicate key", func(t *testing.T) {
+				err := editions.Store(&Edition{ISBN13: edition.ISBN13, Format: "hardcover"})
+				xt.Assert(t, errors.Is(err, kolektor.ErrDuplicateKey))
+				xt.Assert(t, !errors.Is(err, kolektor.ErrConflict))
+
+				var storeErr *kolektor.StoreError
+				xt.Assert(t, errors.As(err, &storeErr))
+				xt.Eq(t, "uq_editions_isbn13", storeErr.Index)
+				if storeKind != kolektor.Memory {
+					xt.Assert(t, storeErr.Code != "")
+				}
+			})
+
+			t.Run("conflict", func(t *testing.T) {
+				stale := &Edition{}
+				xt.OK(t, editions.Get(stale, edition.Meta.UID))
+
+				edition.Format = "e-book"
+				xt.OK(t, editions.Store(edition))
+
+				stale.Format = "audiobook"
+				err := editions.Store(stale)
+				xt.Assert(t, errors.Is(err, kolektor.ErrConflict))
+				xt.Assert(t, errors.As(err, &stores.ErrConflict{}), "expected stores.ErrConflict")
+			})
+
+			t.Run("invalid collection name", func(t *testing.T) {
+				_, err := session.Collection(&badlyNamed{})
+				xt.Assert(t, errors.Is(err, kolektor.ErrInvalidCollectionName))
+				xt.Eq(t, "kolekto: invalid collection name 'books; DROP TABLE books'", err.Error())
+
+				err = session.RemoveCollection(&badlyNamed{})
+				xt.Assert(t, errors.Is(err, kolektor.ErrInvalidCollectionName))
+			})
+
+			t.Run("timeout", func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(ctx, -time.Second)
+				defer cancel()
+
+				_, err := editions.CountContext(ctx, nil)
+				if storeKind == kolektor.Memory {
+					// the in-memory data store does not wait
+					return
+				}
+				xt.Assert(t, errors.Is(err, kolektor.ErrTimeout))
+				xt.Assert(t, errors.Is(err, context.DeadlineExceeded))
+			})
+		})
 	}
 }
@@ -0,0 +1,69 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// cursor is the position within a collection after which the next page
+// of objects starts. It is passed around encoded as opaque token.
+type cursor struct {
+	ID      int64      `json:"i"`
+	Created *time.Time `json:"c,omitempty"`
+}
+
+// encodeCursor returns the token pointing after the object stored in the
+// JSON document data.
+func encodeCursor(data []byte, byCreated bool) (string, error) {
+	var doc struct {
+		Meta kolektor.Meta
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("failed encoding cursor (%w)", err)
+	}
+
+	c := cursor{ID: doc.Meta.ID}
+	if byCreated {
+		c.Created = &doc.Meta.Created
+	}
+
+	token, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed encoding cursor (%w)", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// decodeCursor decodes token as returned by encodeCursor.
+func decodeCursor(token string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	c := &cursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.ID == 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return c, nil
+}
+
+// keyset returns the values of the cursor used for keyset pagination.
+func (c *cursor) keyset(byCreated bool) ([]any, error) {
+	switch {
+	case byCreated && c.Created != nil:
+		return []any{*c.Created, c.ID}, nil
+	case !byCreated && c.Created == nil:
+		return []any{c.ID}, nil
+	default:
+		return nil, fmt.Errorf("cursor does not match page ordering")
+	}
+}
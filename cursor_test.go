@@ -0,0 +1,51 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/geertjanvdk/xkit/xt"
+)
+
+func TestCursor(t *testing.T) {
+	doc := []byte(`{"title": "Book1", "Meta": {"id": 42, "uid": "abc", "created": "2022-06-01T10:11:12.123456Z"}}`)
+
+	t.Run("by ID", func(t *testing.T) {
+		token, err := encodeCursor(doc, false)
+		xt.OK(t, err)
+
+		c, err := decodeCursor(token)
+		xt.OK(t, err)
+		keyset, err := c.keyset(false)
+		xt.OK(t, err)
+		xt.Eq(t, []any{int64(42)}, keyset)
+
+		_, err = c.keyset(true)
+		xt.KO(t, err)
+	})
+
+	t.Run("by created and ID", func(t *testing.T) {
+		token, err := encodeCursor(doc, true)
+		xt.OK(t, err)
+
+		c, err := decodeCursor(token)
+		xt.OK(t, err)
+		keyset, err := c.keyset(true)
+		xt.OK(t, err)
+		xt.Eq(t, 2, len(keyset))
+		xt.Eq(t, time.Date(2022, 6, 1, 10, 11, 12, 123456000, time.UTC), keyset[0].(time.Time))
+		xt.Eq(t, int64(42), keyset[1])
+
+		_, err = c.keyset(false)
+		xt.KO(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, token := range []string{"", "!!", "e30"} {
+			_, err := decodeCursor(token)
+			xt.KO(t, err)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package kolekto_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/golistic/kolekto/kolektor"
+	_ "github.com/golistic/kolekto/stores/dbsqlite" // register store
+)
+
+func Example_sqlite() {
+	// note: SQLite needs no server; the database file is created when it
+	// does not exist.
+	dsn := filepath.Join(os.TempDir(), "music.db")
+
+	// actual example is same for each store; please check example_common_test.go
+	exampleStoreRetrieveBand(kolektor.SQLite, dsn)
+
+	// Output:
+	// UID    : f5dea144-caac-4735-a521-34a82b12f20b
+	// Band   : A Tribe Called Quest
+	// Members:
+	//  - Q-Tip
+	//  - Phife Dwag
+	//  - Ali Shaheed Muhammad
+	//  - Jarobi White
+}
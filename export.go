@@ -0,0 +1,181 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// ImportOptions defines how Collection.Import stores documents.
+type ImportOptions struct {
+	// KeepIDs preserves the IDs of the documents; otherwise, new IDs are
+	// assigned.
+	KeepIDs bool
+	// NewUIDs assigns new UIDs to the documents instead of preserving them.
+	NewUIDs bool
+	// OnConflict defines how documents are imported of which an object
+	// with the same UID exists. By default, the import fails.
+	OnConflict kolektor.ImportConflict
+}
+
+// Export writes all objects of the collection, including those which were
+// soft deleted, to w as newline-delimited JSON (NDJSON) ordered by ID. Each
+// line is a stored JSON document including its metadata as "Meta". The
+// number of exported objects is returned.
+// Documents are exported as stored: they are not upgraded (see
+// kolektor.Migrator), nor passed to hooks.
+func (coll *Collection) Export(w io.Writer) (int64, error) {
+	return coll.ExportContext(context.Background(), w)
+}
+
+// ExportContext exports objects like Export, using the provided context.
+func (coll *Collection) ExportContext(ctx context.Context, w io.Writer) (_ int64, err error) {
+	defer coll.observe("Export", time.Now(), &err)
+
+	rows, err := coll.ses.store.QueryObjects(ctx, coll.model, &kolektor.Query{
+		Order:          []kolektor.Order{{Field: "id"}},
+		IncludeDeleted: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	buf := bufio.NewWriter(w)
+
+	var n int64
+	for rows.Next() {
+		if _, err := buf.Write(append(rows.Document(), '\n')); err != nil {
+			return n, fmt.Errorf("failed exporting %s (%w)", coll.model.CollectionName(), err)
+		}
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+
+	if err := buf.Flush(); err != nil {
+		return n, fmt.Errorf("failed exporting %s (%w)", coll.model.CollectionName(), err)
+	}
+
+	return n, nil
+}
+
+// Import reads JSON documents as written by Export from r, and stores them
+// into the collection including their metadata, for example, the time they
+// were created. The number of stored documents is returned, which excludes
+// those skipped because of opts.OnConflict.
+// Documents are stored in chunks (see SetChunkSize), each within a
+// transaction unless Import is used within a transaction (see Session.Tx).
+// Documents are neither validated nor passed to hooks.
+func (coll *Collection) Import(r io.Reader, opts ImportOptions) (int64, error) {
+	return coll.ImportContext(context.Background(), r, opts)
+}
+
+// ImportContext imports objects like Import, using the provided context.
+func (coll *Collection) ImportContext(ctx context.Context, r io.Reader, opts ImportOptions) (_ int64, err error) {
+	defer coll.observe("Import", time.Now(), &err)
+
+	dec := json.NewDecoder(r)
+
+	var total int64
+	var line int
+
+	for {
+		var metas []kolektor.Meta
+		var docs [][]byte
+
+		for len(docs) < coll.chunkSize {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return total, fmt.Errorf("failed importing %s document %d (%w)",
+					coll.model.CollectionName(), line+1, err)
+			}
+			line++
+
+			meta, data, err := splitMeta(raw)
+			if err != nil {
+				return total, fmt.Errorf("failed importing %s document %d (%w)",
+					coll.model.CollectionName(), line, err)
+			}
+
+			if !opts.KeepIDs {
+				meta.ID = 0
+			}
+			if opts.NewUIDs {
+				meta.UID = ""
+			}
+
+			metas = append(metas, meta)
+			docs = append(docs, data)
+		}
+
+		if len(docs) == 0 {
+			return total, nil
+		}
+
+		var n int64
+		chunk := func(tx *Session) error {
+			n = 0
+			for i, data := range docs {
+				stored, err := tx.store.ImportObject(ctx, coll.model, metas[i], data, opts.OnConflict)
+				if err != nil {
+					return fmt.Errorf("document %d: %w", line-len(docs)+i+1, err)
+				}
+				if stored {
+					n++
+				}
+			}
+			return nil
+		}
+
+		var err error
+		if coll.ses.inTx {
+			err = chunk(coll.ses)
+		} else {
+			err = coll.ses.Tx(ctx, chunk)
+		}
+		if err != nil {
+			return total, fmt.Errorf("failed importing %s (%w)", coll.model.CollectionName(), err)
+		}
+
+		total += n
+	}
+}
+
+// splitMeta returns the metadata stored as "Meta" within the JSON document
+// doc, and the document without it.
+func splitMeta(doc []byte) (kolektor.Meta, []byte, error) {
+	var meta kolektor.Meta
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return meta, nil, err
+	}
+
+	if rawMeta, have := fields["Meta"]; have {
+		if err := json.Unmarshal(rawMeta, &meta); err != nil {
+			return meta, nil, fmt.Errorf("invalid metadata (%w)", err)
+		}
+		delete(fields, "Meta")
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return meta, nil, err
+	}
+
+	return meta, data, nil
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"context"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// beforeStore calls the BeforeStore hook of obj, if any.
+func beforeStore(ctx context.Context, obj any) error {
+	if h, ok := obj.(kolektor.BeforeStorer); ok {
+		return h.BeforeStore(ctx)
+	}
+	return nil
+}
+
+// afterStore calls the AfterStore hook of obj, if any.
+func afterStore(ctx context.Context, obj any) error {
+	if h, ok := obj.(kolektor.AfterStorer); ok {
+		return h.AfterStore(ctx)
+	}
+	return nil
+}
+
+// afterGet calls the AfterGet hook of obj, if any.
+func afterGet(ctx context.Context, obj any) error {
+	if h, ok := obj.(kolektor.AfterGetter); ok {
+		return h.AfterGet(ctx)
+	}
+	return nil
+}
+
+// beforeDelete calls the BeforeDelete hook of obj, if any.
+func beforeDelete(ctx context.Context, obj any) error {
+	if h, ok := obj.(kolektor.BeforeDeleter); ok {
+		return h.BeforeDelete(ctx)
+	}
+	return nil
+}
+
+// afterDelete calls the AfterDelete hook of obj, if any.
+func afterDelete(ctx context.Context, obj any) error {
+	if h, ok := obj.(kolektor.AfterDeleter); ok {
+		return h.AfterDelete(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package ytest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// PrepareSQLite removes the database file of dsn, including its journal,
+// so tests start with an empty database. In-memory databases are left
+// untouched.
+func PrepareSQLite(dsn string) (string, error) {
+	if strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory") {
+		return dsn, nil
+	}
+
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.Index(path, "?"); i != -1 {
+		path = path[:i]
+	}
+
+	for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	return dsn, nil
+}
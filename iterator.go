@@ -0,0 +1,142 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// Iterator streams objects retrieved from a collection, without loading
+// all of them in memory. It is closed when Next returns false, otherwise it
+// must be closed using Close, which releases the underlying connection.
+// When the context with which the iterator was created is done, Next returns
+// false and Err returns the context's error.
+//
+// Usage:
+//
+//	it, err := books.IterateContext(ctx, query)
+//	if err != nil {
+//	    return err
+//	}
+//	defer it.Close()
+//
+//	for it.Next() {
+//	    book := &Book{}
+//	    if err := it.Scan(book); err != nil {
+//	        return err
+//	    }
+//	}
+//	return it.Err()
+type Iterator struct {
+	coll   *Collection
+	ctx    context.Context
+	mu     sync.Mutex
+	rows   kolektor.Rows
+	closed bool
+	err    error
+}
+
+// Iterate retrieves all objects matching query and returns an Iterator
+// to go through them. When query is nil, all objects of the collection
+// are retrieved.
+func (coll *Collection) Iterate(query *kolektor.Query) (*Iterator, error) {
+	return coll.IterateContext(context.Background(), query)
+}
+
+// IterateContext returns an Iterator like Iterate, using the provided
+// context.
+func (coll *Collection) IterateContext(ctx context.Context, query *kolektor.Query) (*Iterator, error) {
+	rows, err := coll.ses.store.QueryObjects(ctx, coll.model, coll.visibleQuery(query))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Iterator{
+		coll: coll,
+		ctx:  ctx,
+		rows: rows,
+	}, nil
+}
+
+// Next prepares the next object to be read using Scan. It returns false when
+// there are no more objects, or when an error occurred, in which case Err
+// returns it. The Iterator is closed when Next returns false.
+func (it *Iterator) Next() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.closed {
+		return false
+	}
+
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		_ = it.close()
+		return false
+	}
+
+	if !it.rows.Next() {
+		it.err = it.rows.Err()
+		_ = it.close()
+		return false
+	}
+
+	return true
+}
+
+// Scan decodes the current object into obj, which must be a non-nil pointer
+// to a new (zero) object. The object's metadata is set as well, and the
+// AfterGet hook of obj is called, if any.
+func (it *Iterator) Scan(obj kolektor.Modeler) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &kolektor.InvalidObjectError{Type: reflect.TypeOf(obj)}
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.closed {
+		return fmt.Errorf("kolekto: iterator is closed")
+	}
+
+	if err := it.coll.decode(it.rows.Document(), obj); err != nil {
+		return err
+	}
+
+	return afterGet(it.ctx, obj)
+}
+
+// Err returns the error, if any, that was encountered while iterating. When
+// the context was done before all objects were read, the context's error
+// is returned.
+func (it *Iterator) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	return it.err
+}
+
+// Close closes the iterator releasing the underlying connection. It is safe
+// to call Close multiple times.
+func (it *Iterator) Close() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	return it.close()
+}
+
+func (it *Iterator) close() error {
+	if it.closed {
+		return nil
+	}
+
+	it.closed = true
+
+	return it.rows.Close()
+}
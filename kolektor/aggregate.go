@@ -0,0 +1,48 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+// AggregateFunc defines the function used to aggregate values.
+type AggregateFunc string
+
+// Supported aggregate functions.
+const (
+	AggCount AggregateFunc = "COUNT"
+	AggSum   AggregateFunc = "SUM"
+	AggMin   AggregateFunc = "MIN"
+	AggMax   AggregateFunc = "MAX"
+	AggAvg   AggregateFunc = "AVG"
+)
+
+// Valid returns whether f is a supported aggregate function.
+func (f AggregateFunc) Valid() bool {
+	switch f {
+	case AggCount, AggSum, AggMin, AggMax, AggAvg:
+		return true
+	default:
+		return false
+	}
+}
+
+// Aggregation defines how the numeric values found at Field, a path within
+// the JSON documents, are aggregated for objects matching Filter.
+// When GroupBy is set, also a path within the JSON documents, values are
+// aggregated per distinct value found at that path.
+// For AggCount, Field is optional: without it all objects are counted.
+type Aggregation struct {
+	Func    AggregateFunc
+	Field   string
+	GroupBy string
+	Filter  Filter
+}
+
+// AggregateResult holds the result of an Aggregation. Group is the value
+// of the GroupBy path, and is nil when not grouping or when the path was
+// not available. For AggCount, the result is Count and Value is nil. For
+// the other functions, the result is Value, which is nil when there were
+// no values to aggregate.
+type AggregateResult struct {
+	Group *string
+	Count int64
+	Value *float64
+}
@@ -2,7 +2,85 @@
 
 package kolektor
 
-import "reflect"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// Errors classifying why operations failed. The errors returned by Session,
+// Collection, and the data stores are tested against them using errors.Is,
+// for example:
+//
+//	if errors.Is(err, kolektor.ErrDuplicateKey) { ... }
+var (
+	// ErrNotFound is the error when the object, or the collection, does
+	// not exist.
+	ErrNotFound = errors.New("kolekto: not found")
+	// ErrDuplicateKey is the error when storing an object violates a
+	// unique index. Use errors.As with *StoreError to get the index.
+	ErrDuplicateKey = errors.New("kolekto: duplicate key")
+	// ErrConflict is the error when an object was changed concurrently,
+	// including transactions which could not be serialized or deadlocked.
+	ErrConflict = errors.New("kolekto: conflict")
+	// ErrInvalidCollectionName is the error when the name of a collection
+	// cannot be used as the name of a table.
+	ErrInvalidCollectionName = errors.New("kolekto: invalid collection name")
+	// ErrTimeout is the error when an operation did not finish in time, for
+	// example, because a lock could not be acquired.
+	ErrTimeout = errors.New("kolekto: timeout")
+	// ErrStoreUnavailable is the error when the data store cannot be reached,
+	// for example, because the connection was lost or the server is shutting
+	// down.
+	ErrStoreUnavailable = errors.New("kolekto: store unavailable")
+)
+
+// StoreError is an error, usually returned by the driver of a data store,
+// classified as Kind, which is one of the errors above. It matches Kind using
+// errors.Is, while Err, the original error, remains available using errors.As.
+type StoreError struct {
+	// Kind is the classification of Err, for example, ErrDuplicateKey.
+	Kind error
+	// Code is the error code of the data store, for example, MySQL error
+	// number "1062" or PostgreSQL SQLSTATE "23505". It is empty when Err
+	// is not reported by the data store itself, for example, when the
+	// connection was lost.
+	Code string
+	// Index is the name of the index which was violated when Kind is
+	// ErrDuplicateKey, if known.
+	Index string
+	// Err is the error as returned by the driver, or describing why the
+	// operation failed.
+	Err error
+}
+
+func (e *StoreError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StoreError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// reCollectionName matches the names which can be used as names of tables
+// in all data stores without quoting; PostgreSQL limits them to 63 bytes.
+var reCollectionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// CheckCollectionName returns an error wrapping ErrInvalidCollectionName
+// when name cannot be used as the name of a collection. Names start with a
+// letter or underscore, followed by letters, digits, or underscores, and are
+// at most 63 characters long.
+func CheckCollectionName(name string) error {
+	if !reCollectionName.MatchString(name) {
+		return fmt.Errorf("%w '%s'", ErrInvalidCollectionName, name)
+	}
+	return nil
+}
 
 // InvalidObjectError describes an invalid argument passed to functions
 // that require a kolektor.Modeler that must be a non-nil pointer.
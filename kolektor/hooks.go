@@ -0,0 +1,36 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+import "context"
+
+// BeforeStorer is implemented by models which prepare objects before they
+// are stored, for example, to normalize or validate them. When BeforeStore
+// returns an error, the object is not stored.
+type BeforeStorer interface {
+	BeforeStore(ctx context.Context) error
+}
+
+// AfterStorer is implemented by models which act on objects after they were
+// stored. The metadata of the object is available.
+type AfterStorer interface {
+	AfterStore(ctx context.Context) error
+}
+
+// AfterGetter is implemented by models which act on objects after they were
+// retrieved, for example, to compute derived fields.
+type AfterGetter interface {
+	AfterGet(ctx context.Context) error
+}
+
+// BeforeDeleter is implemented by models which act on objects before they
+// are deleted. When BeforeDelete returns an error, the object is not deleted.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterDeleter is implemented by models which act on objects after they were
+// deleted.
+type AfterDeleter interface {
+	AfterDelete(ctx context.Context) error
+}
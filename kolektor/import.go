@@ -0,0 +1,16 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+// ImportConflict defines how a document is imported when an object with
+// the same UID already exists.
+type ImportConflict int
+
+const (
+	// ImportFail fails the import.
+	ImportFail ImportConflict = iota
+	// ImportSkip keeps the existing object, skipping the document.
+	ImportSkip
+	// ImportOverwrite replaces the existing object, keeping its ID.
+	ImportOverwrite
+)
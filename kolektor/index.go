@@ -0,0 +1,66 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+import "fmt"
+
+// IndexType defines as what the value of an indexed field is indexed.
+type IndexType int
+
+// Supported index types. Timestamps are indexed as text, which sorts
+// chronologically when they are encoded as done by encoding/json in UTC.
+const (
+	IndexString IndexType = iota
+	IndexInt
+	IndexFloat
+	IndexBool
+	IndexTimestamp
+)
+
+func (it IndexType) String() string {
+	switch it {
+	case IndexString:
+		return "string"
+	case IndexInt:
+		return "int"
+	case IndexFloat:
+		return "float"
+	case IndexBool:
+		return "bool"
+	case IndexTimestamp:
+		return "timestamp"
+	default:
+		return fmt.Sprintf("IndexTypeMissing{%d}", it)
+	}
+}
+
+// IndexField defines a field of a store-agnostic index.
+type IndexField struct {
+	// Path is the path of the field within the JSON document, for example,
+	// "isbn13" or "address.city".
+	Path string
+	// Type is the type of the indexed values; defaults to IndexString.
+	Type IndexType
+	// Length is the maximum length of indexed strings, which is needed by
+	// MySQL. When 0, data stores use their default.
+	Length int
+	// Descending sorts the index in descending order.
+	Descending bool
+}
+
+// IndexStatus describes an index of a collection as available within the
+// data store.
+type IndexStatus struct {
+	Name   string
+	Unique bool
+	// Definition is the definition of the index as reported by the data
+	// store, for example, its SQL.
+	Definition string
+	// Managed is true for indexes which are created, recreated, or dropped
+	// so that they match the indexes of the model. The index on the uid
+	// column, for example, is not managed.
+	Managed bool
+	// Valid is false when the data store can not use the index, for example,
+	// when creating it concurrently failed.
+	Valid bool
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IndexTag is the key of the struct tag with which fields of models are
+// indexed. The tag starts with "index", followed by options:
+//
+//	unique    the index is unique
+//	len=N     the maximum length of indexed strings (used by MySQL)
+//	name=N    the name of the index instead of a generated one
+//
+// For example:
+//
+//	ISBN13 string `json:"isbn13" kolekto:"index,unique,len=20"`
+const IndexTag = "kolekto"
+
+// TaggedIndex is an index declared using the IndexTag of a field.
+type TaggedIndex struct {
+	// Name is the name of the index. When not set using the tag, it is
+	// generated from the collection name and the path, and starts with
+	// "uq_" for unique indexes, "ix_" otherwise.
+	Name string
+	// Path is the path of the field within the JSON document, for
+	// example, "isbn13" or "address.city".
+	Path   string
+	Unique bool
+	// Type is derived from the type of the field.
+	Type IndexType
+	// Length is the maximum length of indexed strings; 0 when not set.
+	Length int
+}
+
+// Index returns the store-agnostic index.
+func (ti TaggedIndex) Index() Index {
+	return Index{
+		Name:   ti.Name,
+		Unique: ti.Unique,
+		Fields: []IndexField{{Path: ti.Path, Type: ti.Type, Length: ti.Length}},
+	}
+}
+
+// TaggedIndexes returns the indexes declared using the IndexTag of the
+// fields of model, including fields of nested structs. The path of fields
+// is taken from their json tag.
+func TaggedIndexes(model Modeler) ([]TaggedIndex, error) {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	indexes, err := taggedIndexes(t, "", map[reflect.Type]bool{})
+	if err != nil {
+		return nil, fmt.Errorf("failed getting indexes of %s (%w)", model.CollectionName(), err)
+	}
+
+	names := map[string]bool{}
+	for i := range indexes {
+		if indexes[i].Name == "" {
+			prefix := "ix_"
+			if indexes[i].Unique {
+				prefix = "uq_"
+			}
+			indexes[i].Name = prefix + model.CollectionName() + "_" + strings.ReplaceAll(indexes[i].Path, ".", "_")
+		}
+
+		if names[indexes[i].Name] {
+			return nil, fmt.Errorf("failed getting indexes of %s (duplicate index name %s)",
+				model.CollectionName(), indexes[i].Name)
+		}
+		names[indexes[i].Name] = true
+	}
+
+	return indexes, nil
+}
+
+var typeTime = reflect.TypeOf(time.Time{})
+
+func taggedIndexes(t reflect.Type, prefix string, seen map[reflect.Type]bool) ([]TaggedIndex, error) {
+	if seen[t] {
+		return nil, nil
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	var indexes []TaggedIndex
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		tag, have := field.Tag.Lookup(IndexTag)
+		if !have {
+			if ft.Kind() != reflect.Struct || ft == typeTime {
+				continue
+			}
+			// fields of embedded structs are part of the same JSON object
+			nestedPrefix := prefix
+			if !field.Anonymous || name != "" {
+				nestedPrefix = fieldPath(prefix, field, name)
+			}
+			nested, err := taggedIndexes(ft, nestedPrefix, seen)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, nested...)
+			continue
+		}
+
+		idx, err := parseIndexTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		idx.Path = fieldPath(prefix, field, name)
+		idx.Type = indexType(ft)
+		indexes = append(indexes, idx)
+	}
+
+	return indexes, nil
+}
+
+// fieldPath returns the path of field within the JSON document. The name
+// is taken from the json tag, and is the field name when empty.
+func fieldPath(prefix string, field reflect.StructField, name string) string {
+	if name == "" {
+		name = field.Name
+	}
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// indexType returns the type as which values of fields of type t are indexed.
+func indexType(t reflect.Type) IndexType {
+	if t == typeTime {
+		return IndexTimestamp
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return IndexInt
+	case reflect.Float32, reflect.Float64:
+		return IndexFloat
+	case reflect.Bool:
+		return IndexBool
+	default:
+		return IndexString
+	}
+}
+
+func parseIndexTag(tag string) (TaggedIndex, error) {
+	idx := TaggedIndex{}
+
+	options := strings.Split(tag, ",")
+	if options[0] != "index" {
+		return idx, fmt.Errorf("%s tag must start with 'index'; got '%s'", IndexTag, options[0])
+	}
+
+	for _, option := range options[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "unique":
+			idx.Unique = true
+		case "len":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return idx, fmt.Errorf("invalid index length '%s'", value)
+			}
+			idx.Length = n
+		case "name":
+			if value == "" {
+				return idx, fmt.Errorf("index name must not be empty")
+			}
+			idx.Name = value
+		default:
+			return idx, fmt.Errorf("unsupported index option '%s'", key)
+		}
+	}
+
+	return idx, nil
+}
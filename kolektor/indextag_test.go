@@ -0,0 +1,77 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/geertjanvdk/xkit/xt"
+)
+
+type address struct {
+	City string `json:"city" kolekto:"index"`
+}
+
+type Audit struct {
+	By string `json:"by" kolekto:"index"`
+}
+
+type taggedModel struct {
+	Model
+	Audit
+	ISBN13   string    `json:"isbn13" kolekto:"index,unique,len=20"`
+	Title    string    `kolekto:"index"`
+	Pages    int       `json:"pages" kolekto:"index"`
+	Price    float64   `json:"price" kolekto:"index"`
+	InStock  bool      `json:"inStock" kolekto:"index"`
+	Released time.Time `json:"released" kolekto:"index"`
+	Address  address   `json:"address"`
+	Shipping *address
+	Ignored  string `json:"-" kolekto:"index"`
+}
+
+type duplicateModel struct {
+	Model
+	Name  string `json:"name" kolekto:"index,name=ix_name"`
+	Alias string `json:"alias" kolekto:"index,name=ix_name"`
+}
+
+func (m duplicateModel) CollectionName() string {
+	return "duplicates"
+}
+
+func (m taggedModel) CollectionName() string {
+	return "things"
+}
+
+func TestTaggedIndexes(t *testing.T) {
+	t.Run("fields are indexed", func(t *testing.T) {
+		indexes, err := TaggedIndexes(&taggedModel{})
+		xt.OK(t, err)
+		xt.Eq(t, []TaggedIndex{
+			{Name: "ix_things_by", Path: "by"},
+			{Name: "uq_things_isbn13", Path: "isbn13", Unique: true, Length: 20},
+			{Name: "ix_things_Title", Path: "Title"},
+			{Name: "ix_things_pages", Path: "pages", Type: IndexInt},
+			{Name: "ix_things_price", Path: "price", Type: IndexFloat},
+			{Name: "ix_things_inStock", Path: "inStock", Type: IndexBool},
+			{Name: "ix_things_released", Path: "released", Type: IndexTimestamp},
+			{Name: "ix_things_address_city", Path: "address.city"},
+			{Name: "ix_things_Shipping_city", Path: "Shipping.city"},
+		}, indexes)
+	})
+
+	t.Run("names must be unique", func(t *testing.T) {
+		_, err := TaggedIndexes(&duplicateModel{})
+		xt.KO(t, err)
+		xt.Match(t, `duplicate index name ix_name`, err.Error())
+	})
+
+	t.Run("invalid tags", func(t *testing.T) {
+		for _, tag := range []string{"unique", "index,len=0", "index,name=", "index,sparse"} {
+			_, err := parseIndexTag(tag)
+			xt.KO(t, err, tag)
+		}
+	})
+}
@@ -10,6 +10,8 @@ type StoreKind int
 const (
 	MySQL StoreKind = iota + 1
 	PgSQL
+	SQLite
+	Memory
 )
 
 func (sk StoreKind) String() string {
@@ -18,6 +20,10 @@ func (sk StoreKind) String() string {
 		return "MySQL"
 	case PgSQL:
 		return "PostgreSQL"
+	case SQLite:
+		return "SQLite"
+	case Memory:
+		return "Memory"
 	default:
 		return fmt.Sprintf("StoreNameMissing{%d}", sk)
 	}
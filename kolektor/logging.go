@@ -0,0 +1,36 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+import (
+	"context"
+	"time"
+)
+
+// Statement describes a statement executed by a data store.
+type Statement struct {
+	// SQL is the statement as sent to the data store.
+	SQL string
+	// Args are the values of the placeholders within SQL.
+	Args []any
+	// Duration is the time it took to execute the statement.
+	Duration time.Duration
+	// Err is the error with which the statement failed, if any. Finding
+	// no rows is not an error.
+	Err error
+}
+
+// StatementLogger is implemented by types logging the statements executed
+// by data stores.
+type StatementLogger interface {
+	LogStatement(ctx context.Context, stmt Statement)
+}
+
+// StatementLogging is implemented by data stores which can log the
+// statements they execute.
+type StatementLogging interface {
+	// SetStatementLogger logs the statements executed by the data store,
+	// and by the transactions started from it afterwards, using logger.
+	// Logging is disabled when logger is nil.
+	SetStatementLogger(logger StatementLogger)
+}
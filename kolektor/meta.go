@@ -7,9 +7,17 @@ import "time"
 // Meta stores metadata which each object within a collection
 // must provide.
 // Note that metadata is not stored within the actual JSON document.
+// Version starts at 1 and is incremented each time the object is stored;
+// it is used to detect concurrent updates.
+// Deleted is set when the object was soft deleted (see SoftDeleter).
+// SchemaVersion is the version of the structure of the stored JSON
+// document (see Migrator).
 type Meta struct {
-	ID      int64      `json:"id"`
-	UID     string     `json:"uid"`
-	Created time.Time  `json:"created"`
-	Updated *time.Time `json:"updated"`
+	ID            int64      `json:"id"`
+	UID           string     `json:"uid"`
+	Created       time.Time  `json:"created"`
+	Updated       *time.Time `json:"updated"`
+	Version       int64      `json:"version"`
+	Deleted       *time.Time `json:"deleted,omitempty"`
+	SchemaVersion int        `json:"schema_version,omitempty"`
 }
@@ -0,0 +1,35 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+import "time"
+
+// Metrics is implemented by types recording metrics of collections, for
+// example, to expose them to Prometheus.
+type Metrics interface {
+	// ObserveOperation records that op, which is named after the method of
+	// the collection, for example, "Find", took d to finish. The err is the
+	// error with which the operation failed, if any.
+	ObserveOperation(op Operation, d time.Duration, err error)
+}
+
+// PoolStats are the statistics of the pool of connections of a data store.
+type PoolStats struct {
+	// MaxOpen is the maximum number of open connections; 0 is unlimited.
+	MaxOpen int
+	// Open is the number of open connections, both in use and idle.
+	Open int
+	// InUse is the number of connections in use.
+	InUse int
+	// Idle is the number of idle connections.
+	Idle int
+	// WaitCount is the total number of times a connection was waited for.
+	WaitCount int64
+	// WaitDuration is the total time spent waiting for connections.
+	WaitDuration time.Duration
+}
+
+// PoolStater is implemented by data stores using a pool of connections.
+type PoolStater interface {
+	PoolStats() PoolStats
+}
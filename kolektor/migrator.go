@@ -0,0 +1,18 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+// Upgrader upgrades a JSON document, which does not include the metadata of
+// the object, from one schema version to the next.
+type Upgrader func(doc []byte) ([]byte, error)
+
+// Migrator is implemented by models of which the structure of the JSON
+// documents changes over time, for example, when fields are renamed.
+// SchemaVersion returns the current version of the documents, starting at 1.
+// Upgraders returns the functions upgrading documents from each previous
+// version in order: the first upgrades from version 1 to 2, the second from
+// version 2 to 3, and so on.
+type Migrator interface {
+	SchemaVersion() int
+	Upgraders() []Upgrader
+}
@@ -10,6 +10,21 @@ type Modeler interface {
 	SetMeta(m *Meta)
 	GetID() int64
 	GetUID() string
+	GetVersion() int64
+}
+
+// MetaGetter is implemented by models giving access to their metadata,
+// which Model does. Data stores use it to leave the metadata of objects
+// untouched when they fail to store them.
+type MetaGetter interface {
+	GetMeta() *Meta
+}
+
+// SoftDeleter is implemented by models of which objects are soft deleted
+// when SoftDelete returns true: instead of removing objects, their deleted
+// timestamp is set, which hides them until they are restored or purged.
+type SoftDeleter interface {
+	SoftDelete() bool
 }
 
 // Model is to be embedded by structs. It implements all methods of the
@@ -30,6 +45,12 @@ func (m *Model) SetMeta(meta *Meta) {
 	m.Meta = meta
 }
 
+// GetMeta returns the metadata, which is nil when the object was neither
+// retrieved nor stored.
+func (m *Model) GetMeta() *Meta {
+	return m.Meta
+}
+
 // GetID returns the data stores primary key, which is always an int64.
 func (m *Model) GetID() int64 {
 	if m.Meta == nil {
@@ -45,3 +66,12 @@ func (m *Model) GetUID() string {
 	}
 	return m.Meta.UID
 }
+
+// GetVersion returns the version of the object as it was retrieved or
+// stored. It is 0 for objects which were not stored yet.
+func (m *Model) GetVersion() int64 {
+	if m.Meta == nil {
+		m.Meta = &Meta{}
+	}
+	return m.Meta.Version
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+// Patch is a partial update of a JSON document, which is either a MergePatch
+// or a JSONPatch. Data stores apply patches without retrieving the document.
+type Patch interface {
+	isPatch()
+}
+
+// MergePatch is a JSON merge patch as defined by RFC 7396. It is a JSON
+// object of which the members are set within the document, recursively,
+// while members which are null are removed.
+type MergePatch []byte
+
+func (MergePatch) isPatch() {}
+
+// JSONPatch is a sequence of operations as defined by RFC 6902. The
+// operations are applied in order, and none is applied when one fails.
+type JSONPatch []PatchOperation
+
+func (JSONPatch) isPatch() {}
+
+// PatchOp is the operation of a PatchOperation.
+type PatchOp string
+
+const (
+	PatchAdd     PatchOp = "add"
+	PatchRemove  PatchOp = "remove"
+	PatchReplace PatchOp = "replace"
+	PatchMove    PatchOp = "move"
+	PatchCopy    PatchOp = "copy"
+	PatchTest    PatchOp = "test"
+)
+
+// Valid returns whether op is a known operation.
+func (op PatchOp) Valid() bool {
+	switch op {
+	case PatchAdd, PatchRemove, PatchReplace, PatchMove, PatchCopy, PatchTest:
+		return true
+	default:
+		return false
+	}
+}
+
+// PatchOperation is an operation of a JSONPatch. Path and From are JSON
+// Pointers (RFC 6901) within the document, for example, "/address/city".
+// Value is encoded as JSON.
+type PatchOperation struct {
+	Op    PatchOp `json:"op"`
+	Path  string  `json:"path"`
+	From  string  `json:"from,omitempty"`
+	Value any     `json:"value"`
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+import "sort"
+
+// Operator defines how the value of a field is compared within a Condition.
+type Operator string
+
+// Supported comparison operators.
+const (
+	OpEqual        Operator = "="
+	OpNotEqual     Operator = "!="
+	OpLess         Operator = "<"
+	OpLessEqual    Operator = "<="
+	OpGreater      Operator = ">"
+	OpGreaterEqual Operator = ">="
+	OpIn           Operator = "IN"
+	OpLike         Operator = "LIKE"
+	OpIsNull       Operator = "IS NULL"
+	OpIsNotNull    Operator = "IS NOT NULL"
+)
+
+// Valid returns whether op is a supported operator.
+func (op Operator) Valid() bool {
+	switch op {
+	case OpEqual, OpNotEqual, OpLess, OpLessEqual, OpGreater, OpGreaterEqual,
+		OpIn, OpLike, OpIsNull, OpIsNotNull:
+		return true
+	default:
+		return false
+	}
+}
+
+// Condition compares the value of Field with Value using Operator.
+// Field is either one of the reserved fields (for example, id or uid),
+// a path within the JSON document using dots to separate the keys
+// (for example, "address.city"), or a raw SQL expression which must
+// start with an opening parenthesis.
+// Value is ignored for OpIsNull and OpIsNotNull, and must be a slice
+// for OpIn.
+type Condition struct {
+	Field    string
+	Operator Operator
+	Value    any
+}
+
+// Filter is a list of conditions which objects must all meet.
+type Filter []Condition
+
+// FilterFromFields returns a Filter which checks equality of each field
+// within fields. The conditions are sorted by field name.
+func FilterFromFields(fields FieldMap) Filter {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filter := make(Filter, 0, len(names))
+	for _, name := range names {
+		filter = append(filter, Condition{Field: name, Operator: OpEqual, Value: fields[name]})
+	}
+
+	return filter
+}
+
+// Order defines how objects are sorted using Field, which follows the
+// same rules as the field of a Condition.
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// Query defines which objects are retrieved from a collection and in
+// which order. When Limit is 0, all objects are returned.
+// After is used for keyset pagination: when set, only objects sorting
+// after these values are returned. It must have a value for each Order
+// field, which must be reserved fields sorted in the same direction.
+// Objects which were soft deleted are only retrieved when IncludeDeleted
+// is true, or when Filter has a condition on the deleted field.
+type Query struct {
+	Filter         Filter
+	Order          []Order
+	Limit          int
+	Offset         int
+	After          []any
+	IncludeDeleted bool
+}
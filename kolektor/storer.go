@@ -2,27 +2,77 @@
 
 package kolektor
 
-import "context"
+import (
+	"context"
+	"database/sql"
+)
 
 type FieldMap map[string]any
 
 // Storer defines methods which must be implemented by data
 // stores types.
+// The context passed to each method must be propagated to the driver,
+// so that cancellation and deadlines abort the underlying queries.
 type Storer interface {
 	Name() string
-	GetObject(obj Modeler, fields FieldMap) error
-	StoreObject(obj Modeler) (*Meta, error)
-	RemoveCollection(model Modeler) error
-	InitCollection(model Modeler) error
+	GetObject(ctx context.Context, obj Modeler, fields FieldMap) error
+	QueryObjects(ctx context.Context, model Modeler, query *Query) (Rows, error)
+	CountObjects(ctx context.Context, model Modeler, filter Filter) (int64, error)
+	ObjectsExist(ctx context.Context, model Modeler, filter Filter) (bool, error)
+	AggregateObjects(ctx context.Context, model Modeler, agg Aggregation) ([]AggregateResult, error)
+	StoreObject(ctx context.Context, obj Modeler) (*Meta, error)
+	StoreObjects(ctx context.Context, model Modeler, objs []Modeler) ([]*Meta, error)
+	UpgradeObject(ctx context.Context, model Modeler, id, version int64, data []byte) (bool, error)
+	UpsertObject(ctx context.Context, obj Modeler, keyFields []string) (*Meta, bool, error)
+	ImportObject(ctx context.Context, model Modeler, meta Meta, data []byte, onConflict ImportConflict) (bool, error)
+	PatchObject(ctx context.Context, model Modeler, filter Filter, patch Patch) (*Meta, error)
+	DeleteObjects(ctx context.Context, model Modeler, filter Filter) (int64, error)
+	SoftDeleteObjects(ctx context.Context, model Modeler, filter Filter) (int64, error)
+	RestoreObjects(ctx context.Context, model Modeler, filter Filter) (int64, error)
+	RemoveCollection(ctx context.Context, model Modeler) error
+	ListCollections(ctx context.Context) ([]string, error)
+	ListIndexes(ctx context.Context, model Modeler) ([]IndexStatus, error)
+	InitCollection(ctx context.Context, model Modeler) error
 	Connection(ctx context.Context) (any, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (TxStorer, error)
+}
+
+// Rows is a stream of objects retrieved from a collection, each as JSON
+// document including its metadata. Rows must be closed, which releases
+// the underlying connection.
+type Rows interface {
+	// Next prepares the next document, returning false when there are no
+	// more documents or an error occurred.
+	Next() bool
+	// Document returns the current document. The caller owns the returned
+	// slice.
+	Document() []byte
+	// Err returns the error, if any, that was encountered while iterating.
+	Err() error
+	Close() error
+}
+
+// TxStorer is a Storer of which all objects are retrieved and stored
+// within one transaction. Data stores do not support nested transactions.
+type TxStorer interface {
+	Storer
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
 }
 
 type Indexer interface {
 	Indexes(kind StoreKind) []Index
 }
 
+// Index defines an index of a collection. It is defined either using Fields,
+// which data stores translate to their own functional index, or using
+// Expression, which is the SQL used by a particular data store.
 type Index struct {
-	Name       string
-	Unique     bool
+	Name   string
+	Unique bool
+	// Expression is the raw SQL expression, including parenthesis, for
+	// example, "((data->>'isbn13'))". When set, Fields is ignored.
 	Expression string
+	// Fields are the indexed fields within the JSON documents.
+	Fields []IndexField
 }
@@ -0,0 +1,62 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+import "context"
+
+// Operation describes an operation of a data store which is traced.
+type Operation struct {
+	// Name is the name of the Storer method, for example, "GetObject", or
+	// "ReconcileIndexes" when indexes are created, recreated, or dropped
+	// while initializing a collection.
+	Name string
+	// Collection is the name of the collection; it is empty for operations
+	// which are not done on a collection, for example, "BeginTx".
+	Collection string
+	// Store is the name of the kind of data store, for example, "PostgreSQL"
+	// (see StoreKind.String).
+	Store string
+}
+
+// Tracer is implemented by types tracing the operations of data stores,
+// for example, using OpenTelemetry.
+type Tracer interface {
+	// Start starts tracing op, and returns the context within which op is
+	// executed, and the span which is ended when op finished.
+	Start(ctx context.Context, op Operation) (context.Context, Span)
+}
+
+// Span traces one operation.
+type Span interface {
+	// SetRows records the number of objects retrieved or changed by the
+	// operation. For "ReconcileIndexes", it is the number of indexes which
+	// were created plus the number which were dropped; recreated indexes
+	// count as both.
+	SetRows(n int64)
+	// End ends the span. The err is the error with which the operation
+	// failed, if any.
+	End(err error)
+}
+
+type tracerKey struct{}
+
+// ContextWithTracer returns a copy of ctx carrying tracer, which data stores
+// use to trace operations done as part of others (see StartSpan).
+func ContextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// StartSpan starts tracing op using the Tracer carried by ctx. When ctx
+// does not carry a Tracer, the returned Span does nothing.
+func StartSpan(ctx context.Context, op Operation) (context.Context, Span) {
+	if tracer, ok := ctx.Value(tracerKey{}).(Tracer); ok && tracer != nil {
+		return tracer.Start(ctx, op)
+	}
+	return ctx, noopSpan{}
+}
+
+// noopSpan is the Span used when operations are not traced.
+type noopSpan struct{}
+
+func (noopSpan) SetRows(int64) {}
+func (noopSpan) End(error)     {}
@@ -0,0 +1,18 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolektor
+
+// Validator is implemented by models which check their objects before they
+// are stored. When Validate returns an error, the object is not stored.
+type Validator interface {
+	Validate() error
+}
+
+// JSONSchemer is implemented by models of which the JSON documents must
+// be valid against the JSON Schema returned by JSONSchema. The document
+// does not include the metadata of the object.
+// Objects are validated before they are stored. Data stores which support
+// JSON Schema, such as MySQL, also enforce the schema within the database.
+type JSONSchemer interface {
+	JSONSchema() string
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// Redacted replaces the values of arguments which are redacted.
+const Redacted = "[redacted]"
+
+// LogOptions defines how statements are logged (see Session.SetLogger).
+type LogOptions struct {
+	// Level is the level at which statements are logged. Defaults to
+	// slog.LevelDebug.
+	Level slog.Leveler
+	// ErrorLevel is the level at which statements which failed are logged.
+	// Defaults to slog.LevelError.
+	ErrorLevel slog.Leveler
+	// SlowThreshold is the duration above which statements are slow. Slow
+	// statements are flagged using the attribute "slow". Statements are never
+	// slow when SlowThreshold is 0.
+	SlowThreshold time.Duration
+	// SlowLevel is the level at which slow statements are logged, unless
+	// they failed. Defaults to slog.LevelWarn.
+	SlowLevel slog.Leveler
+	// Redact returns the value which is logged for arg, which is the
+	// argument of a statement at position i, starting with 0. Arguments
+	// of type []byte are passed as string. Defaults to RedactAll.
+	Redact func(i int, arg any) any
+}
+
+// RedactAll redacts the values of all arguments.
+func RedactAll(int, any) any {
+	return Redacted
+}
+
+// RedactNone does not redact any value.
+func RedactNone(_ int, arg any) any {
+	return arg
+}
+
+// RedactDocuments redacts the values of arguments which are JSON objects,
+// which are the documents stored, or used to patch them. Other values, for
+// example, those of filters, are not redacted.
+func RedactDocuments(_ int, arg any) any {
+	if s, ok := arg.(string); ok && len(s) > 0 && s[0] == '{' && json.Valid([]byte(s)) {
+		return Redacted
+	}
+	return arg
+}
+
+// SetLogger logs the statements executed by the data store used by the
+// session, and by the transactions started from it afterwards, using logger.
+// The statement is logged with its arguments, how long executing it took,
+// and the error with which it failed, if any. When opts is nil, the defaults
+// of LogOptions are used. Logging is disabled when logger is nil.
+//
+// Only data stores executing statements, which are those backed by SQL
+// databases, log; the in-memory data store does not.
+func (ses *Session) SetLogger(logger *slog.Logger, opts *LogOptions) {
+	sl, ok := untraced(ses.store).(kolektor.StatementLogging)
+	if !ok {
+		return
+	}
+
+	if logger == nil {
+		sl.SetStatementLogger(nil)
+		return
+	}
+
+	sl.SetStatementLogger(newStatementLogger(logger, ses.store.Name(), opts))
+}
+
+// statementLogger implements kolektor.StatementLogger using slog.
+type statementLogger struct {
+	logger *slog.Logger
+	store  string
+	opts   LogOptions
+}
+
+var _ kolektor.StatementLogger = &statementLogger{}
+
+func newStatementLogger(logger *slog.Logger, store string, opts *LogOptions) *statementLogger {
+	l := &statementLogger{logger: logger, store: store}
+	if opts != nil {
+		l.opts = *opts
+	}
+
+	if l.opts.Level == nil {
+		l.opts.Level = slog.LevelDebug
+	}
+	if l.opts.ErrorLevel == nil {
+		l.opts.ErrorLevel = slog.LevelError
+	}
+	if l.opts.SlowLevel == nil {
+		l.opts.SlowLevel = slog.LevelWarn
+	}
+	if l.opts.Redact == nil {
+		l.opts.Redact = RedactAll
+	}
+
+	return l
+}
+
+// LogStatement logs stmt with the message "statement failed" when it
+// failed, "slow statement" when it took longer than the threshold, and
+// "statement" otherwise.
+func (l *statementLogger) LogStatement(ctx context.Context, stmt kolektor.Statement) {
+	slow := l.opts.SlowThreshold > 0 && stmt.Duration > l.opts.SlowThreshold
+
+	level := l.opts.Level.Level()
+	msg := "statement"
+	switch {
+	case stmt.Err != nil:
+		level = l.opts.ErrorLevel.Level()
+		msg = "statement failed"
+	case slow:
+		level = l.opts.SlowLevel.Level()
+		msg = "slow statement"
+	}
+
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("store", l.store),
+		slog.String("sql", stmt.SQL),
+		slog.Duration("duration", stmt.Duration),
+	}
+
+	if len(stmt.Args) > 0 {
+		args := make([]any, len(stmt.Args))
+		for i, arg := range stmt.Args {
+			if b, ok := arg.([]byte); ok {
+				arg = string(b)
+			}
+			args[i] = l.opts.Redact(i, arg)
+		}
+		attrs = append(attrs, slog.Any("args", args))
+	}
+
+	if slow {
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+
+	if stmt.Err != nil {
+		attrs = append(attrs, slog.Any("error", stmt.Err))
+	}
+
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,15 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores/dbmemory"
+)
+
+func init() {
+	prepareStore[kolektor.Memory] = func(dsn string) (string, error) {
+		dbmemory.Drop(dsn)
+		return dsn, nil
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package kolekto
+
+import (
+	"github.com/golistic/kolekto/internal/ytest"
+	"github.com/golistic/kolekto/kolektor"
+)
+
+func init() {
+	prepareStore[kolektor.SQLite] = ytest.PrepareSQLite
+}
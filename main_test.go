@@ -5,6 +5,7 @@ package kolekto
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/golistic/kolekto/kolektor"
@@ -46,6 +47,14 @@ func TestMain(m *testing.M) {
 		testAllDSN[kolektor.MySQL] = defaultMySQLDSN
 	}
 
+	if v, have := os.LookupEnv("TEST_SQLITE_DSN"); have {
+		testAllDSN[kolektor.SQLite] = v
+	} else {
+		testAllDSN[kolektor.SQLite] = filepath.Join(os.TempDir(), "kolekto_test.db")
+	}
+
+	testAllDSN[kolektor.Memory] = "kolekto_test"
+
 	for storeKind := range stores.Registered() {
 		if _, testErr = prepareStore[storeKind](testAllDSN[storeKind]); testErr != nil {
 			return
@@ -0,0 +1,41 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// SetMetrics records the duration and outcome of the operations of all
+// collections retrieved from the session, including those already retrieved
+// and those used within transactions, using metrics. Recording is disabled
+// when metrics is nil.
+func (ses *Session) SetMetrics(metrics kolektor.Metrics) {
+	ses.metrics = metrics
+}
+
+// PoolStats returns the statistics of the pool of connections of the data
+// store. False is returned when the data store does not use a pool, for
+// example, the in-memory data store.
+func (ses *Session) PoolStats() (kolektor.PoolStats, bool) {
+	if ps, ok := untraced(ses.store).(kolektor.PoolStater); ok {
+		return ps.PoolStats(), true
+	}
+	return kolektor.PoolStats{}, false
+}
+
+// observe records the operation called name, which started at start and
+// finished with error err, when the session has metrics (see SetMetrics).
+func (coll *Collection) observe(name string, start time.Time, err *error) {
+	if coll.ses.metrics == nil {
+		return
+	}
+
+	coll.ses.metrics.ObserveOperation(kolektor.Operation{
+		Name:       name,
+		Collection: coll.model.CollectionName(),
+		Store:      coll.ses.store.Name(),
+	}, time.Since(start), *err)
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// checkMigrator checks whether model, when it is a kolektor.Migrator,
+// provides an upgrader for each previous schema version.
+func checkMigrator(model kolektor.Modeler) error {
+	m, ok := model.(kolektor.Migrator)
+	if !ok {
+		return nil
+	}
+
+	if m.SchemaVersion() < 1 {
+		return fmt.Errorf("schema version of %s must be at least 1", model.CollectionName())
+	}
+
+	if n := len(m.Upgraders()); n != m.SchemaVersion()-1 {
+		return fmt.Errorf("schema version %d of %s needs %d upgraders; got %d",
+			m.SchemaVersion(), model.CollectionName(), m.SchemaVersion()-1, n)
+	}
+
+	return nil
+}
+
+// migrates returns whether documents of the collection can have an older
+// schema version.
+func (coll *Collection) migrates() bool {
+	_, ok := coll.model.(kolektor.Migrator)
+	return ok && stores.SchemaVersion(coll.model) > 1
+}
+
+// decode decodes the JSON document data, which includes the metadata, into
+// obj. Documents stored using an older schema version are upgraded first.
+// The document is not stored again: Meta.SchemaVersion keeps reporting the
+// stored version until the object is stored.
+func (coll *Collection) decode(data []byte, obj any) error {
+	if coll.migrates() {
+		var err error
+		if data, err = upgrade(coll.model.(kolektor.Migrator), data); err != nil {
+			return fmt.Errorf("failed upgrading %s object (%w)", coll.model.CollectionName(), err)
+		}
+	}
+
+	if err := json.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("failed decoding object (%w)", err)
+	}
+
+	return nil
+}
+
+// upgrade returns the JSON document data upgraded to the current schema
+// version of m. The metadata is kept, but is not passed to the upgraders.
+func upgrade(m kolektor.Migrator, data []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	rawMeta, have := doc["Meta"]
+	if !have {
+		return data, nil
+	}
+
+	var meta kolektor.Meta
+	if err := json.Unmarshal(rawMeta, &meta); err != nil {
+		return nil, err
+	}
+
+	version := meta.SchemaVersion
+	if version < 1 {
+		version = 1
+	}
+	if version >= m.SchemaVersion() {
+		return data, nil
+	}
+
+	delete(doc, "Meta")
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	upgraders := m.Upgraders()
+	for v := version; v < m.SchemaVersion(); v++ {
+		if data, err = upgraders[v-1](data); err != nil {
+			return nil, fmt.Errorf("schema version %d to %d: %w", v, v+1, err)
+		}
+	}
+
+	doc = nil
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("schema version %d: %w", m.SchemaVersion(), err)
+	}
+	doc["Meta"] = rawMeta
+
+	return json.Marshal(doc)
+}
+
+// MigrateCollection upgrades all objects of the collection of model, which
+// must be a kolektor.Migrator, stored using an older schema version, and
+// returns the number of upgraded objects. Soft deleted objects are upgraded
+// as well. Objects are rewritten in batches of DefaultChunkSize, each
+// within its own transaction, and get the current schema version recorded.
+// Their version is kept, so that the objects can still be stored by those
+// holding them. Objects changed while being upgraded are skipped.
+// Lifecycle hooks and validation are skipped.
+// When interrupted, or when objects were skipped, MigrateCollection can be
+// called again to upgrade the remaining objects.
+func (ses *Session) MigrateCollection(model kolektor.Modeler) (int64, error) {
+	return ses.MigrateCollectionContext(context.Background(), model)
+}
+
+// MigrateCollectionContext migrates the collection like MigrateCollection,
+// using the provided context.
+func (ses *Session) MigrateCollectionContext(ctx context.Context, model kolektor.Modeler) (int64, error) {
+	if _, ok := model.(kolektor.Migrator); !ok {
+		return 0, fmt.Errorf("%s is not a kolektor.Migrator", model.CollectionName())
+	}
+
+	if _, err := ses.CollectionContext(ctx, model); err != nil {
+		return 0, err
+	}
+
+	elemType := reflect.TypeOf(model)
+	if elemType.Kind() == reflect.Pointer {
+		elemType = elemType.Elem()
+	}
+
+	var total int64
+	var lastID int64
+
+	for {
+		var n, upgraded int
+		batch := func(tx *Session) error {
+			coll, err := newCollection(tx, model)
+			if err != nil {
+				return err
+			}
+
+			query := &kolektor.Query{
+				Filter: kolektor.Filter{
+					{Field: "schema_version", Operator: kolektor.OpLess, Value: stores.SchemaVersion(model)},
+				},
+				Order:          []kolektor.Order{{Field: "id"}},
+				Limit:          DefaultChunkSize,
+				IncludeDeleted: true,
+			}
+			if lastID > 0 {
+				query.After = []any{lastID}
+			}
+
+			docs, err := coll.documents(ctx, query)
+			if err != nil {
+				return err
+			}
+
+			for _, data := range docs {
+				obj := reflect.New(elemType).Interface().(kolektor.Modeler)
+				if err := coll.decode(data, obj); err != nil {
+					return err
+				}
+
+				id, version := obj.GetID(), obj.GetVersion()
+				data, err := stores.MarshalObject(obj)
+				if err != nil {
+					return err
+				}
+
+				ok, err := tx.store.UpgradeObject(ctx, model, id, version, data)
+				if err != nil {
+					return err
+				}
+				if ok {
+					upgraded++
+				}
+				lastID = id
+			}
+
+			n = len(docs)
+			return nil
+		}
+
+		var err error
+		if ses.inTx {
+			err = batch(ses)
+		} else {
+			err = ses.Tx(ctx, batch)
+		}
+		if err != nil {
+			return total, fmt.Errorf("failed migrating %s (%w)", model.CollectionName(), err)
+		}
+
+		total += int64(upgraded)
+		if n < DefaultChunkSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,23 @@
+module github.com/golistic/kolekto/otelkolekto
+
+go 1.21
+
+require (
+	github.com/geertjanvdk/xkit v0.9.0-beta.6
+	github.com/golistic/kolekto v0.0.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/golistic/xstrings v0.0.0-20220526163930-92a29fd1bf54 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+)
+
+replace github.com/golistic/kolekto => ../
@@ -0,0 +1,110 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+// Package otelkolekto traces the operations of kolekto data stores using
+// OpenTelemetry.
+//
+//	ses.SetTracer(otelkolekto.NewTracer(nil))
+package otelkolekto
+
+import (
+	"context"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer created by NewTracer.
+const InstrumentationName = "github.com/golistic/kolekto/otelkolekto"
+
+// Attributes of the spans.
+const (
+	// AttrDBSystem is the kind of data store following the semantic
+	// conventions of OpenTelemetry, for example, "postgresql".
+	AttrDBSystem = attribute.Key("db.system")
+	// AttrStore is the kind of data store as named by kolekto, for
+	// example, "PostgreSQL".
+	AttrStore = attribute.Key("kolekto.store")
+	// AttrCollection is the name of the collection.
+	AttrCollection = attribute.Key("kolekto.collection")
+	// AttrOperation is the name of the operation, for example, "GetObject".
+	AttrOperation = attribute.Key("kolekto.operation")
+	// AttrRows is the number of objects retrieved or changed.
+	AttrRows = attribute.Key("kolekto.rows")
+)
+
+// dbSystems maps kinds of data stores to the value of db.system.
+var dbSystems = map[string]string{
+	kolektor.MySQL.String():  "mysql",
+	kolektor.PgSQL.String():  "postgresql",
+	kolektor.SQLite.String(): "sqlite",
+}
+
+// Tracer implements kolektor.Tracer using OpenTelemetry.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ kolektor.Tracer = &Tracer{}
+
+// NewTracer returns a Tracer creating spans using a tracer of tp. When tp
+// is nil, the global tracer provider is used (see otel.GetTracerProvider).
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+
+	return &Tracer{tracer: tp.Tracer(InstrumentationName)}
+}
+
+// Start starts a client span for op, named after the operation and the
+// collection, for example, "GetObject books". The span is a child of the
+// span carried by ctx, if any.
+func (t *Tracer) Start(ctx context.Context, op kolektor.Operation) (context.Context, kolektor.Span) {
+	name := op.Name
+	if op.Collection != "" {
+		name += " " + op.Collection
+	}
+
+	dbSystem, ok := dbSystems[op.Store]
+	if !ok {
+		dbSystem = strings.ToLower(op.Store)
+	}
+
+	attrs := []attribute.KeyValue{
+		AttrDBSystem.String(dbSystem),
+		AttrStore.String(op.Store),
+		AttrOperation.String(op.Name),
+	}
+	if op.Collection != "" {
+		attrs = append(attrs, AttrCollection.String(op.Collection))
+	}
+
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+
+	return ctx, &otelSpan{span: span}
+}
+
+// otelSpan implements kolektor.Span using an OpenTelemetry span.
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) SetRows(n int64) {
+	s.span.SetAttributes(AttrRows.Int64(n))
+}
+
+// End ends the span. When err is not nil, it is recorded and the status of
+// the span is set to error.
+func (s *otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package otelkolekto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/geertjanvdk/xkit/xt"
+	"github.com/golistic/kolekto"
+	"github.com/golistic/kolekto/kolektor"
+	_ "github.com/golistic/kolekto/stores/dbmemory"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type book struct {
+	kolektor.Model
+	ISBN13 string `json:"isbn13" kolekto:"index,unique"`
+	Title  string `json:"title"`
+}
+
+func (b book) CollectionName() string {
+	return "books"
+}
+
+// attrs returns the attributes of span as map.
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestTracer(t *testing.T) {
+	ctx := context.Background()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func() { _ = tp.Shutdown(ctx) }()
+
+	ses, err := kolekto.NewSession(kolektor.Memory, "")
+	xt.OK(t, err)
+	ses.SetTracer(NewTracer(tp))
+
+	books, err := ses.Collection(&book{})
+	xt.OK(t, err)
+
+	t.Run("reconciling indexes is part of initializing", func(t *testing.T) {
+		spans := exporter.GetSpans()
+		xt.Eq(t, 2, len(spans))
+
+		// children end first
+		reconcile, init := spans[0], spans[1]
+		xt.Eq(t, "InitCollection books", init.Name)
+		xt.Eq(t, "ReconcileIndexes books", reconcile.Name)
+		xt.Eq(t, init.SpanContext.SpanID(), reconcile.Parent.SpanID())
+		xt.Eq(t, trace.SpanKindClient, init.SpanKind)
+
+		have := attrs(reconcile)
+		xt.Eq(t, "books", have[AttrCollection].AsString())
+		xt.Eq(t, "Memory", have[AttrStore].AsString())
+		xt.Eq(t, "memory", have[AttrDBSystem].AsString())
+		xt.Eq(t, "ReconcileIndexes", have[AttrOperation].AsString())
+		xt.Eq(t, int64(1), have[AttrRows].AsInt64())
+	})
+
+	t.Run("rows are recorded", func(t *testing.T) {
+		exporter.Reset()
+		xt.OK(t, books.StoreManyContext(ctx, []*book{{ISBN13: "1", Title: "One"}, {ISBN13: "2", Title: "Two"}}))
+
+		var found []*book
+		xt.OK(t, books.FindContext(ctx, &found, nil))
+
+		// each chunk is stored within its own transaction
+		spans := exporter.GetSpans()
+		xt.Eq(t, 4, len(spans))
+		xt.Eq(t, "BeginTx", spans[0].Name)
+		xt.Eq(t, "StoreObjects books", spans[1].Name)
+		xt.Eq(t, int64(2), attrs(spans[1])[AttrRows].AsInt64())
+		xt.Eq(t, "Commit", spans[2].Name)
+		xt.Eq(t, "QueryObjects books", spans[3].Name)
+		xt.Eq(t, int64(2), attrs(spans[3])[AttrRows].AsInt64())
+		xt.Eq(t, codes.Unset, spans[3].Status.Code)
+	})
+
+	t.Run("errors are recorded", func(t *testing.T) {
+		exporter.Reset()
+		xt.KO(t, books.Store(&book{ISBN13: "1", Title: "Duplicate"}))
+
+		spans := exporter.GetSpans()
+		xt.Eq(t, 1, len(spans))
+		xt.Eq(t, codes.Error, spans[0].Status.Code)
+		xt.Eq(t, 1, len(spans[0].Events))
+		xt.Eq(t, "exception", spans[0].Events[0].Name)
+	})
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"context"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// Patch applies patch to the object identified by uid, and returns the
+// metadata of the updated object. The patch is either a kolektor.MergePatch
+// (RFC 7396) or a kolektor.JSONPatch (RFC 6902), which the data store
+// applies without retrieving the object. Soft deleted objects are not
+// patched.
+//
+// Error stores.ErrNoObject is returned when the object does not exist, and
+// stores.ErrPatch when the patch could not be applied, for example, because
+// a test operation failed. Hooks and validation of the model are not used;
+// the patch applies to the document as stored, regardless its schema version.
+func (coll *Collection) Patch(uid string, patch kolektor.Patch) (*kolektor.Meta, error) {
+	return coll.PatchContext(context.Background(), uid, patch)
+}
+
+// PatchContext patches an object like Patch, using the provided context.
+func (coll *Collection) PatchContext(ctx context.Context, uid string, patch kolektor.Patch) (_ *kolektor.Meta, err error) {
+	defer coll.observe("Patch", time.Now(), &err)
+
+	filter := coll.visible(kolektor.Filter{{Field: "uid", Operator: kolektor.OpEqual, Value: uid}})
+	return coll.ses.store.PatchObject(ctx, coll.model, filter, patch)
+}
@@ -0,0 +1,15 @@
+module github.com/golistic/kolekto/promkolekto
+
+go 1.21
+
+require (
+	github.com/geertjanvdk/xkit v0.9.0-beta.6
+	github.com/golistic/kolekto v0.0.0
+)
+
+require (
+	github.com/golistic/xstrings v0.0.0-20220526163930-92a29fd1bf54 // indirect
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1 // indirect
+)
+
+replace github.com/golistic/kolekto => ../
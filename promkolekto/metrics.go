@@ -0,0 +1,285 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+// Package promkolekto records metrics of the operations of kolekto
+// collections, and exposes them together with the statistics of pools
+// of connections using the Prometheus text exposition format.
+//
+//	metrics := promkolekto.New()
+//	ses.SetMetrics(metrics)
+//	metrics.WatchPool("main", ses)
+//	http.Handle("/metrics", metrics)
+package promkolekto
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of the
+// histogram of the duration of operations.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// PoolSource is implemented by types reporting the statistics of a pool of
+// connections, for example, kolekto.Session. False is returned when there
+// is no pool.
+type PoolSource interface {
+	PoolStats() (kolektor.PoolStats, bool)
+}
+
+// Metrics implements kolektor.Metrics, and is a http.Handler exposing the
+// recorded metrics. It is safe for concurrent use.
+type Metrics struct {
+	buckets []float64
+
+	mu    sync.Mutex
+	ops   map[operation]*opMetrics
+	pools map[string]PoolSource
+}
+
+var _ kolektor.Metrics = &Metrics{}
+var _ http.Handler = &Metrics{}
+
+// operation identifies the operations of which metrics are recorded.
+type operation struct {
+	store      string
+	collection string
+	name       string
+}
+
+type opMetrics struct {
+	count  uint64
+	errors uint64
+	// buckets counts per bucket, not cumulative, the operations which took
+	// at most its upper bound.
+	buckets []uint64
+	sum     float64
+}
+
+// New returns Metrics using buckets as upper bounds, in seconds, of the
+// buckets of the histogram of the duration of operations. DefaultBuckets
+// is used when no buckets are given.
+func New(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets: buckets,
+		ops:     map[operation]*opMetrics{},
+		pools:   map[string]PoolSource{},
+	}
+}
+
+// ObserveOperation records the duration and outcome of op.
+func (m *Metrics) ObserveOperation(op kolektor.Operation, d time.Duration, err error) {
+	key := operation{store: op.Store, collection: op.Collection, name: op.Name}
+	seconds := d.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	om, have := m.ops[key]
+	if !have {
+		om = &opMetrics{buckets: make([]uint64, len(m.buckets))}
+		m.ops[key] = om
+	}
+
+	om.count++
+	if err != nil {
+		om.errors++
+	}
+	om.sum += seconds
+	if i := sort.SearchFloat64s(m.buckets, seconds); i < len(m.buckets) {
+		om.buckets[i]++
+	}
+}
+
+// WatchPool exposes the statistics of the pool of connections of source
+// labeled with name, which replaces the source watched using the same name.
+// The statistics are retrieved each time metrics are written.
+func (m *Metrics) WatchPool(name string, source PoolSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools[name] = source
+}
+
+// ServeHTTP writes the metrics using the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if err := m.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Write writes the metrics to w using the Prometheus text exposition format.
+func (m *Metrics) Write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+
+	keys := make([]operation, 0, len(m.ops))
+	for key := range m.ops {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.store != b.store {
+			return a.store < b.store
+		}
+		if a.collection != b.collection {
+			return a.collection < b.collection
+		}
+		return a.name < b.name
+	})
+
+	opLabels := func(key operation) string {
+		return labels("store", key.store, "collection", key.collection, "operation", key.name)
+	}
+
+	header(buf, "kolekto_operations_total", "counter", "Number of operations of collections.")
+	for _, key := range keys {
+		sample(buf, "kolekto_operations_total", opLabels(key), float64(m.ops[key].count))
+	}
+
+	header(buf, "kolekto_operation_errors_total", "counter", "Number of operations of collections which failed.")
+	for _, key := range keys {
+		sample(buf, "kolekto_operation_errors_total", opLabels(key), float64(m.ops[key].errors))
+	}
+
+	header(buf, "kolekto_operation_duration_seconds", "histogram", "Duration of operations of collections.")
+	for _, key := range keys {
+		om := m.ops[key]
+		l := opLabels(key)
+
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += om.buckets[i]
+			sample(buf, "kolekto_operation_duration_seconds_bucket",
+				l[:len(l)-1]+`,le="`+formatFloat(bound)+`"}`, float64(cumulative))
+		}
+		sample(buf, "kolekto_operation_duration_seconds_bucket", l[:len(l)-1]+`,le="+Inf"}`, float64(om.count))
+		sample(buf, "kolekto_operation_duration_seconds_sum", l, om.sum)
+		sample(buf, "kolekto_operation_duration_seconds_count", l, float64(om.count))
+	}
+
+	m.writePools(buf)
+
+	return buf.Flush()
+}
+
+// poolMetrics defines the metrics of pools of connections.
+var poolMetrics = []struct {
+	name  string
+	kind  string
+	help  string
+	value func(stats kolektor.PoolStats) float64
+}{
+	{
+		name:  "kolekto_pool_max_open_connections",
+		kind:  "gauge",
+		help:  "Maximum number of open connections; 0 is unlimited.",
+		value: func(stats kolektor.PoolStats) float64 { return float64(stats.MaxOpen) },
+	},
+	{
+		name:  "kolekto_pool_open_connections",
+		kind:  "gauge",
+		help:  "Number of open connections, both in use and idle.",
+		value: func(stats kolektor.PoolStats) float64 { return float64(stats.Open) },
+	},
+	{
+		name:  "kolekto_pool_in_use_connections",
+		kind:  "gauge",
+		help:  "Number of connections in use.",
+		value: func(stats kolektor.PoolStats) float64 { return float64(stats.InUse) },
+	},
+	{
+		name:  "kolekto_pool_idle_connections",
+		kind:  "gauge",
+		help:  "Number of idle connections.",
+		value: func(stats kolektor.PoolStats) float64 { return float64(stats.Idle) },
+	},
+	{
+		name:  "kolekto_pool_wait_count_total",
+		kind:  "counter",
+		help:  "Number of times a connection was waited for.",
+		value: func(stats kolektor.PoolStats) float64 { return float64(stats.WaitCount) },
+	},
+	{
+		name:  "kolekto_pool_wait_duration_seconds_total",
+		kind:  "counter",
+		help:  "Time spent waiting for connections.",
+		value: func(stats kolektor.PoolStats) float64 { return stats.WaitDuration.Seconds() },
+	},
+}
+
+// writePools writes the statistics of the watched pools of connections.
+// Sources without pool are skipped.
+func (m *Metrics) writePools(w io.Writer) {
+	names := make([]string, 0, len(m.pools))
+	stats := map[string]kolektor.PoolStats{}
+	for name, source := range m.pools {
+		if s, ok := source.PoolStats(); ok {
+			names = append(names, name)
+			stats[name] = s
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return
+	}
+
+	for _, pm := range poolMetrics {
+		header(w, pm.name, pm.kind, pm.help)
+		for _, name := range names {
+			sample(w, pm.name, labels("pool", name), pm.value(stats[name]))
+		}
+	}
+}
+
+func header(w io.Writer, name, kind, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sample(w io.Writer, name, labels string, value float64) {
+	_, _ = fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+// labels returns the label set of the pairs of names and values, for
+// example, `{pool="main"}`.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package promkolekto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geertjanvdk/xkit/xt"
+	"github.com/golistic/kolekto"
+	"github.com/golistic/kolekto/kolektor"
+	_ "github.com/golistic/kolekto/stores/dbmemory"
+)
+
+type book struct {
+	kolektor.Model
+	Title string `json:"title"`
+}
+
+func (b book) CollectionName() string {
+	return "books"
+}
+
+type testPool kolektor.PoolStats
+
+func (p testPool) PoolStats() (kolektor.PoolStats, bool) {
+	return kolektor.PoolStats(p), true
+}
+
+type noPool struct{}
+
+func (noPool) PoolStats() (kolektor.PoolStats, bool) {
+	return kolektor.PoolStats{}, false
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("operations of collections", func(t *testing.T) {
+		ctx := context.Background()
+		metrics := New()
+
+		ses, err := kolekto.NewSession(kolektor.Memory, "")
+		xt.OK(t, err)
+		ses.SetMetrics(metrics)
+
+		books, err := ses.Collection(&book{})
+		xt.OK(t, err)
+		xt.OK(t, books.Store(&book{Title: "Go"}))
+		xt.OK(t, books.Store(&book{Title: "Rust"}))
+		xt.KO(t, books.DeleteByUIDContext(ctx, "no-such-uid"))
+
+		var buf strings.Builder
+		xt.OK(t, metrics.Write(&buf))
+		out := buf.String()
+
+		xt.Assert(t, strings.Contains(out, "# TYPE kolekto_operations_total counter\n"))
+		xt.Assert(t, strings.Contains(out,
+			`kolekto_operations_total{store="Memory",collection="books",operation="Store"} 2`+"\n"))
+		xt.Assert(t, strings.Contains(out,
+			`kolekto_operation_errors_total{store="Memory",collection="books",operation="Store"} 0`+"\n"))
+		xt.Assert(t, strings.Contains(out,
+			`kolekto_operation_errors_total{store="Memory",collection="books",operation="DeleteByUID"} 1`+"\n"))
+		xt.Assert(t, strings.Contains(out, "# TYPE kolekto_operation_duration_seconds histogram\n"))
+		xt.Assert(t, strings.Contains(out,
+			`kolekto_operation_duration_seconds_bucket{store="Memory",collection="books",operation="Store",le="+Inf"} 2`+"\n"))
+		xt.Assert(t, strings.Contains(out,
+			`kolekto_operation_duration_seconds_count{store="Memory",collection="books",operation="Store"} 2`+"\n"))
+		xt.Assert(t, !strings.Contains(out, "kolekto_pool_"))
+	})
+
+	t.Run("buckets are cumulative", func(t *testing.T) {
+		metrics := New(0.1, 1)
+		op := kolektor.Operation{Name: "Find", Collection: "books", Store: "SQLite"}
+		metrics.ObserveOperation(op, 50*time.Millisecond, nil)
+		metrics.ObserveOperation(op, 500*time.Millisecond, nil)
+		metrics.ObserveOperation(op, 2*time.Second, errors.New("timeout"))
+
+		var buf strings.Builder
+		xt.OK(t, metrics.Write(&buf))
+
+		exp := `kolekto_operation_duration_seconds_bucket{store="SQLite",collection="books",operation="Find",le="0.1"} 1
+kolekto_operation_duration_seconds_bucket{store="SQLite",collection="books",operation="Find",le="1"} 2
+kolekto_operation_duration_seconds_bucket{store="SQLite",collection="books",operation="Find",le="+Inf"} 3
+kolekto_operation_duration_seconds_sum{store="SQLite",collection="books",operation="Find"} 2.55
+kolekto_operation_duration_seconds_count{store="SQLite",collection="books",operation="Find"} 3
+`
+		xt.Assert(t, strings.Contains(buf.String(), exp))
+	})
+
+	t.Run("pools of connections", func(t *testing.T) {
+		metrics := New()
+		metrics.WatchPool("main", testPool{MaxOpen: 10, Open: 4, InUse: 3, Idle: 1, WaitCount: 7,
+			WaitDuration: 1500 * time.Millisecond})
+		metrics.WatchPool("memory", noPool{})
+
+		var buf strings.Builder
+		xt.OK(t, metrics.Write(&buf))
+		out := buf.String()
+
+		for _, exp := range []string{
+			"# TYPE kolekto_pool_open_connections gauge\n",
+			`kolekto_pool_max_open_connections{pool="main"} 10` + "\n",
+			`kolekto_pool_open_connections{pool="main"} 4` + "\n",
+			`kolekto_pool_in_use_connections{pool="main"} 3` + "\n",
+			`kolekto_pool_idle_connections{pool="main"} 1` + "\n",
+			"# TYPE kolekto_pool_wait_count_total counter\n",
+			`kolekto_pool_wait_count_total{pool="main"} 7` + "\n",
+			`kolekto_pool_wait_duration_seconds_total{pool="main"} 1.5` + "\n",
+		} {
+			xt.Assert(t, strings.Contains(out, exp), exp)
+		}
+		xt.Assert(t, !strings.Contains(out, `pool="memory"`))
+	})
+
+	t.Run("label values are escaped", func(t *testing.T) {
+		xt.Eq(t, `{a="x\"y\\z\n"}`, labels("a", "x\"y\\z\n"))
+	})
+
+	t.Run("scraped using HTTP", func(t *testing.T) {
+		metrics := New()
+		metrics.ObserveOperation(kolektor.Operation{Name: "Get", Collection: "books", Store: "MySQL"}, time.Millisecond, nil)
+
+		srv := httptest.NewServer(metrics)
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL)
+		xt.OK(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		body, err := io.ReadAll(resp.Body)
+		xt.OK(t, err)
+		xt.Eq(t, http.StatusOK, resp.StatusCode)
+		xt.Eq(t, ContentType, resp.Header.Get("Content-Type"))
+		xt.Assert(t, strings.Contains(string(body),
+			`kolekto_operations_total{store="MySQL",collection="books",operation="Get"} 1`))
+	})
+}
@@ -4,14 +4,19 @@ package kolekto
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 
 	"github.com/golistic/kolekto/kolektor"
 	"github.com/golistic/kolekto/stores"
+	"github.com/golistic/xstrings"
 )
 
 // Session wraps around a store object to manage JSON collections.
 type Session struct {
-	store kolektor.Storer
+	store   kolektor.Storer
+	inTx    bool
+	metrics kolektor.Metrics
 }
 
 // NewSession instantiates a new Session using a certain kind of
@@ -47,18 +52,101 @@ func newSession(dsn string, fn func(dsn string) (kolektor.Storer, error)) (*Sess
 // objects which are based on the provided model.
 // If the collection is not yet available in the data store, it is created.
 func (ses *Session) Collection(model kolektor.Modeler) (*Collection, error) {
-	if err := ses.store.InitCollection(model); err != nil {
+	return ses.CollectionContext(context.Background(), model)
+}
+
+// CollectionContext returns a Collection like Collection, using the provided
+// context when the collection is initialized within the data store.
+// Within a transaction, the collection is not initialized and must
+// already be available.
+func (ses *Session) CollectionContext(ctx context.Context, model kolektor.Modeler) (*Collection, error) {
+	coll, err := newCollection(ses, model)
+	if err != nil {
 		return nil, err
 	}
 
-	return newCollection(ses)
+	if !ses.inTx {
+		if err := ses.store.InitCollection(ctx, model); err != nil {
+			return nil, err
+		}
+	}
+
+	return coll, nil
 }
 
 // RemoveCollection will destroy the collection baed on the provided model.
 // Without warning, without remorse. If you had no backups, and you did this
 // by mistake, you can consider yourself screwed.
 func (ses *Session) RemoveCollection(model kolektor.Modeler) error {
-	return ses.store.RemoveCollection(model)
+	return ses.RemoveCollectionContext(context.Background(), model)
+}
+
+// RemoveCollectionContext destroys the collection like RemoveCollection,
+// using the provided context.
+func (ses *Session) RemoveCollectionContext(ctx context.Context, model kolektor.Modeler) error {
+	if ses.inTx {
+		return fmt.Errorf("kolekto: cannot remove collection within transaction")
+	}
+
+	if err := kolektor.CheckCollectionName(model.CollectionName()); err != nil {
+		return err
+	}
+
+	return ses.store.RemoveCollection(ctx, model)
+}
+
+// OpenCollection returns a Collection like CollectionContext for a collection
+// which must already be available in the data store. The collection is not
+// initialized: its table and indexes are left as they are, even when they
+// do not match the model.
+func (ses *Session) OpenCollection(model kolektor.Modeler) (*Collection, error) {
+	return ses.OpenCollectionContext(context.Background(), model)
+}
+
+// OpenCollectionContext opens a collection like OpenCollection, using the
+// provided context.
+func (ses *Session) OpenCollectionContext(ctx context.Context, model kolektor.Modeler) (*Collection, error) {
+	if err := kolektor.CheckCollectionName(model.CollectionName()); err != nil {
+		return nil, err
+	}
+
+	names, err := ses.store.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if xstrings.Search(names, model.CollectionName()) == -1 {
+		return nil, &kolektor.StoreError{
+			Kind: kolektor.ErrNotFound,
+			Err:  fmt.Errorf("kolekto: collection %s does not exist", model.CollectionName()),
+		}
+	}
+
+	return newCollection(ses, model)
+}
+
+// CollectionNames returns the names of all collections available in the
+// data store, sorted by name.
+func (ses *Session) CollectionNames() ([]string, error) {
+	return ses.CollectionNamesContext(context.Background())
+}
+
+// CollectionNamesContext returns the names of the collections like
+// CollectionNames, using the provided context.
+func (ses *Session) CollectionNamesContext(ctx context.Context) ([]string, error) {
+	return ses.store.ListCollections(ctx)
+}
+
+// Indexes returns the indexes of the model's collection as available in
+// the data store.
+func (ses *Session) Indexes(model kolektor.Modeler) ([]kolektor.IndexStatus, error) {
+	return ses.IndexesContext(context.Background(), model)
+}
+
+// IndexesContext returns the status of the indexes like Indexes, using the
+// provided context.
+func (ses *Session) IndexesContext(ctx context.Context, model kolektor.Modeler) ([]kolektor.IndexStatus, error) {
+	return ses.store.ListIndexes(ctx, model)
 }
 
 // Connection returns a connection to the store in use by this session.
@@ -67,3 +155,40 @@ func (ses *Session) RemoveCollection(model kolektor.Modeler) error {
 func (ses *Session) Connection(ctx context.Context) (any, error) {
 	return ses.store.Connection(ctx)
 }
+
+// Tx executes fn within a transaction. All collections retrieved using
+// the Session passed to fn store and retrieve their objects within this
+// transaction. The transaction is committed when fn returns without
+// error, and rolled back when fn returns an error or panics.
+func (ses *Session) Tx(ctx context.Context, fn func(tx *Session) error) error {
+	return ses.TxWithOptions(ctx, nil, fn)
+}
+
+// TxWithOptions executes fn within a transaction like Tx. The opts can be
+// used to set the isolation level and whether the transaction is read-only.
+func (ses *Session) TxWithOptions(ctx context.Context, opts *sql.TxOptions, fn func(tx *Session) error) error {
+	if ses.inTx {
+		return fmt.Errorf("kolekto: nested transactions are not supported")
+	}
+
+	txStore, err := ses.store.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = txStore.Rollback(ctx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(&Session{store: txStore, inTx: true, metrics: ses.metrics}); err != nil {
+		if rbErr := txStore.Rollback(ctx); rbErr != nil {
+			return fmt.Errorf("%w (%s)", err, rbErr)
+		}
+		return err
+	}
+
+	return txStore.Commit(ctx)
+}
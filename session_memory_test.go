@@ -0,0 +1,38 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/geertjanvdk/xkit/xt"
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores/dbmemory"
+)
+
+func TestNew_memory(t *testing.T) {
+	t.Run("test Memory", func(t *testing.T) {
+		session, err := NewSession(kolektor.Memory, testAllDSN[kolektor.Memory])
+		xt.OK(t, err)
+		_, ok := session.store.(*dbmemory.Store)
+		xt.Assert(t, ok, "expected *dbmemory.Store")
+	})
+}
+
+func TestCollection_Store_memory(t *testing.T) {
+	session, err := NewSession(kolektor.Memory, testAllDSN[kolektor.Memory])
+	xt.OK(t, err)
+
+	testCollectionStore(t, session)
+	testCollectionContext(t, session)
+}
+
+func TestSession_Connection_memory(t *testing.T) {
+	session, err := NewSession(kolektor.Memory, testAllDSN[kolektor.Memory])
+	xt.OK(t, err)
+	c, err := session.Connection(context.Background())
+	xt.OK(t, err)
+	_, ok := c.(*dbmemory.Store)
+	xt.Assert(t, ok, "expected *dbmemory.Store")
+}
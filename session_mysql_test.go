@@ -29,6 +29,7 @@ func TestCollection_Store_mysql(t *testing.T) {
 	xt.OK(t, err)
 
 	testCollectionStore(t, session)
+	testCollectionContext(t, session)
 }
 
 func TestSession_Connection_mysql(t *testing.T) {
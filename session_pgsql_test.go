@@ -30,6 +30,7 @@ func TestCollection_Store_pgsql(t *testing.T) {
 	xt.OK(t, err)
 
 	testCollectionStore(t, session)
+	testCollectionContext(t, session)
 }
 
 func TestSession_Connection_pgsql(t *testing.T) {
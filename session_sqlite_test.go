@@ -0,0 +1,45 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package kolekto
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/geertjanvdk/xkit/xt"
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores/dbsqlite"
+)
+
+func TestNew_sqlite(t *testing.T) {
+	t.Run("test SQLite", func(t *testing.T) {
+		session, err := NewSession(kolektor.SQLite, testAllDSN[kolektor.SQLite])
+		xt.OK(t, err)
+		_, ok := session.store.(*dbsqlite.Store)
+		xt.Assert(t, ok, "expected *dbsqlite.Store")
+	})
+}
+
+func TestCollection_Store_sqlite(t *testing.T) {
+	session, err := NewSession(kolektor.SQLite, testAllDSN[kolektor.SQLite])
+	xt.OK(t, err)
+
+	testCollectionStore(t, session)
+	testCollectionContext(t, session)
+}
+
+func TestSession_Connection_sqlite(t *testing.T) {
+	session, err := NewSession(kolektor.SQLite, testAllDSN[kolektor.SQLite])
+	xt.OK(t, err)
+	c, err := session.Connection(context.Background())
+	xt.OK(t, err)
+	conn, ok := c.(*sql.Conn)
+	xt.Assert(t, ok, "expected *sql.Conn")
+
+	var version string
+	xt.OK(t, conn.QueryRowContext(context.Background(), "SELECT sqlite_version()").Scan(&version))
+	xt.Assert(t, version != "", "expected SQLite version")
+}
@@ -3,10 +3,21 @@
 package kolekto
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/geertjanvdk/xkit/xt"
 	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	"github.com/golistic/xstrings"
 )
 
 type Book struct {
@@ -15,6 +26,7 @@ type Book struct {
 	Title     string   `json:"title"`
 	Authors   []string `json:"authors,omitempty"`
 	Publisher string   `json:"publisher"`
+	Year      int      `json:"year,omitempty"`
 }
 
 var _ kolektor.Modeler = &Book{}
@@ -55,6 +67,36 @@ func (b Book) Indexes(kind kolektor.StoreKind) []kolektor.Index {
 				Expression: "((data->>'publisher'))",
 			},
 		},
+		kolektor.SQLite: {
+			{
+				Name:       "uq_books_isbn13",
+				Unique:     true,
+				Expression: "((data->>'$.isbn13'))",
+			},
+			{
+				Name:       "ix_books_title",
+				Expression: "((data->>'$.title'))",
+			},
+			{
+				Name:       "ix_books_publisher",
+				Expression: "((data->>'$.publisher'))",
+			},
+		},
+		kolektor.Memory: {
+			{
+				Name:       "uq_books_isbn13",
+				Unique:     true,
+				Expression: "isbn13",
+			},
+			{
+				Name:       "ix_books_title",
+				Expression: "title",
+			},
+			{
+				Name:       "ix_books_publisher",
+				Expression: "publisher",
+			},
+		},
 	}
 
 	if res, have := m[kind]; have {
@@ -101,3 +143,776 @@ func testCollectionStore(t *testing.T, session *Session) {
 		})
 	})
 }
+
+func testCollectionContext(t *testing.T, session *Session) {
+	t.Run(testDBPrefix(session)+" cancelled context", func(t *testing.T) {
+		books, err := session.Collection(&Book{})
+		xt.OK(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		book := &Book{
+			ISBN13: "978-0735611313",
+			Title:  "Code Complete",
+		}
+		xt.Assert(t, errors.Is(books.StoreContext(ctx, book), context.Canceled))
+		xt.Assert(t, errors.Is(books.GetContext(ctx, &Book{}, 1), context.Canceled))
+	})
+}
+
+type Review struct {
+	kolektor.Model
+	ISBN13 string `json:"isbn13"`
+	Rating int    `json:"rating"`
+}
+
+var _ kolektor.Modeler = &Review{}
+
+func (r Review) CollectionName() string {
+	return "reviews"
+}
+
+// Edition is a model of which the unique index is declared using a struct tag.
+type Edition struct {
+	kolektor.Model
+	ISBN13 string `json:"isbn13" kolekto:"index,unique,len=20"`
+	Format string `json:"format"`
+}
+
+func (e Edition) CollectionName() string {
+	return "editions"
+}
+
+// Account is a model with more than one unique index.
+type Account struct {
+	kolektor.Model
+	Email string `json:"email" kolekto:"index,unique,len=100"`
+	Login string `json:"login" kolekto:"index,unique,len=40"`
+}
+
+func (a Account) CollectionName() string {
+	return "accounts"
+}
+
+type Note struct {
+	kolektor.Model
+	Text string `json:"text"`
+}
+
+var _ kolektor.SoftDeleter = &Note{}
+
+func (n Note) CollectionName() string {
+	return "notes"
+}
+
+func (n Note) SoftDelete() bool {
+	return true
+}
+
+// Task is a model implementing all lifecycle hooks; the called hooks are
+// recorded in calls.
+type Task struct {
+	kolektor.Model
+	Title  string `json:"title"`
+	Locked bool   `json:"locked"`
+	calls  []string
+}
+
+var (
+	_ kolektor.BeforeStorer  = &Task{}
+	_ kolektor.AfterStorer   = &Task{}
+	_ kolektor.AfterGetter   = &Task{}
+	_ kolektor.BeforeDeleter = &Task{}
+	_ kolektor.AfterDeleter  = &Task{}
+)
+
+func (t Task) CollectionName() string {
+	return "tasks"
+}
+
+func (t *Task) BeforeStore(context.Context) error {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	t.calls = append(t.calls, "BeforeStore")
+	return nil
+}
+
+func (t *Task) AfterStore(context.Context) error {
+	t.calls = append(t.calls, "AfterStore")
+	return nil
+}
+
+func (t *Task) AfterGet(context.Context) error {
+	t.calls = append(t.calls, "AfterGet")
+	return nil
+}
+
+func (t *Task) BeforeDelete(context.Context) error {
+	if t.Locked {
+		return fmt.Errorf("task is locked")
+	}
+	t.calls = append(t.calls, "BeforeDelete")
+	return nil
+}
+
+func (t *Task) AfterDelete(context.Context) error {
+	t.calls = append(t.calls, "AfterDelete")
+	return nil
+}
+
+// Concert is a model which is validated using both Validate and a JSON
+// Schema.
+type Concert struct {
+	kolektor.Model
+	Band  string `json:"band"`
+	Seats int    `json:"seats"`
+	Sold  int    `json:"sold"`
+}
+
+var (
+	_ kolektor.Validator   = &Concert{}
+	_ kolektor.JSONSchemer = &Concert{}
+)
+
+func (c Concert) CollectionName() string {
+	return "concerts"
+}
+
+func (c Concert) Validate() error {
+	if c.Sold > c.Seats {
+		return fmt.Errorf("sold more than %d seats", c.Seats)
+	}
+	return nil
+}
+
+func (c Concert) JSONSchema() string {
+	return `{
+  "type": "object",
+  "required": ["band", "seats"],
+  "properties": {
+    "band": {"type": "string", "minLength": 1},
+    "seats": {"type": "integer", "minimum": 1}
+  }
+}`
+}
+
+// PersonV1 is the first version of Person.
+type PersonV1 struct {
+	kolektor.Model
+	Name string `json:"name"`
+}
+
+func (p PersonV1) CollectionName() string {
+	return "people"
+}
+
+// Person is a model of which the document structure changed twice: name was
+// renamed to fullName, which was then split into first and last.
+type Person struct {
+	kolektor.Model
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+var _ kolektor.Migrator = &Person{}
+
+func (p Person) CollectionName() string {
+	return "people"
+}
+
+func (p Person) SchemaVersion() int {
+	return 3
+}
+
+func (p Person) Upgraders() []kolektor.Upgrader {
+	return []kolektor.Upgrader{
+		func(doc []byte) ([]byte, error) {
+			var v struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(doc, &v); err != nil {
+				return nil, err
+			}
+			return json.Marshal(map[string]string{"fullName": v.Name})
+		},
+		func(doc []byte) ([]byte, error) {
+			var v struct {
+				FullName string `json:"fullName"`
+			}
+			if err := json.Unmarshal(doc, &v); err != nil {
+				return nil, err
+			}
+			first, last, _ := strings.Cut(v.FullName, " ")
+			return json.Marshal(map[string]string{"first": first, "last": last})
+		},
+	}
+}
+
+func TestSession_Tx(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+			reviews, err := session.Collection(&Review{})
+			xt.OK(t, err)
+
+			t.Run("commit", func(t *testing.T) {
+				book := &Book{ISBN13: "978-0-2620-3384-8", Title: "SICP"}
+				review := &Review{ISBN13: book.ISBN13, Rating: 5}
+
+				xt.OK(t, session.Tx(context.Background(), func(tx *Session) error {
+					txBooks, err := tx.Collection(&Book{})
+					if err != nil {
+						return err
+					}
+					txReviews, err := tx.Collection(&Review{})
+					if err != nil {
+						return err
+					}
+					if err := txBooks.Store(book); err != nil {
+						return err
+					}
+					return txReviews.Store(review)
+				}))
+
+				xt.OK(t, books.Get(&Book{}, book.Meta.UID))
+				xt.OK(t, reviews.Get(&Review{}, review.Meta.UID))
+			})
+
+			t.Run("rollback on error", func(t *testing.T) {
+				book := &Book{ISBN13: "978-0-2625-1087-5", Title: "SICP 2nd"}
+				book.Meta = &kolektor.Meta{UID: "tx-rollback-error"}
+				expErr := errors.New("rollback please")
+
+				err := session.Tx(context.Background(), func(tx *Session) error {
+					txBooks, err := tx.Collection(&Book{})
+					if err != nil {
+						return err
+					}
+					if err := txBooks.Store(book); err != nil {
+						return err
+					}
+					return expErr
+				})
+				xt.Assert(t, errors.Is(err, expErr))
+				xt.Assert(t, errors.As(books.Get(&Book{}, "tx-rollback-error"), &stores.ErrNoObject{}))
+			})
+
+			t.Run("rollback on panic", func(t *testing.T) {
+				book := &Book{ISBN13: "978-0-2625-1087-6", Title: "SICP 3rd"}
+				book.Meta = &kolektor.Meta{UID: "tx-rollback-panic"}
+
+				xt.Panics(t, func() {
+					_ = session.Tx(context.Background(), func(tx *Session) error {
+						txBooks, err := tx.Collection(&Book{})
+						if err != nil {
+							return err
+						}
+						if err := txBooks.Store(book); err != nil {
+							return err
+						}
+						panic("rollback please")
+					})
+				})
+				xt.Assert(t, errors.As(books.Get(&Book{}, "tx-rollback-panic"), &stores.ErrNoObject{}))
+			})
+
+			t.Run("read-only", func(t *testing.T) {
+				err := session.TxWithOptions(context.Background(), &sql.TxOptions{ReadOnly: true},
+					func(tx *Session) error {
+						txBooks, err := tx.Collection(&Book{})
+						if err != nil {
+							return err
+						}
+						return txBooks.Store(&Book{ISBN13: "978-0-2625-1087-7", Title: "Read Only"})
+					})
+				xt.KO(t, err)
+			})
+
+			t.Run("nested transactions not supported", func(t *testing.T) {
+				err := session.Tx(context.Background(), func(tx *Session) error {
+					return tx.Tx(context.Background(), func(tx *Session) error {
+						return nil
+					})
+				})
+				xt.KO(t, err)
+			})
+		})
+	}
+}
+
+func TestSession_MigrateCollection(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Person{}))
+			oldPeople, err := session.Collection(&PersonV1{})
+			xt.OK(t, err)
+
+			old := []*PersonV1{{Name: "Ada Lovelace"}, {Name: "Alan Turing"}, {Name: "Grace Hopper"}}
+			xt.OK(t, oldPeople.StoreManyContext(ctx, old))
+			xt.Eq(t, 1, old[0].Meta.SchemaVersion)
+			xt.OK(t, oldPeople.DeleteContext(ctx, old[2]))
+
+			// loaded before migrating, and stored afterwards
+			people, err := session.Collection(&Person{})
+			xt.OK(t, err)
+			held := &Person{}
+			xt.OK(t, people.GetContext(ctx, held, old[0].Meta.UID))
+
+			t.Run("all old documents are upgraded", func(t *testing.T) {
+				n, err := session.MigrateCollectionContext(ctx, &Person{})
+				xt.OK(t, err)
+				xt.Eq(t, 2, n)
+
+				people, err := session.Collection(&Person{})
+				xt.OK(t, err)
+				n, err = people.CountContext(ctx, kolektor.Filter{
+					{Field: "schema_version", Operator: kolektor.OpEqual, Value: 3},
+				})
+				xt.OK(t, err)
+				xt.Eq(t, 2, n)
+
+				var found []*Person
+				xt.OK(t, people.FindContext(ctx, &found, &kolektor.Query{Order: []kolektor.Order{{Field: "id"}}}))
+				xt.Eq(t, 2, len(found))
+				xt.Eq(t, "Ada", found[0].First)
+				xt.Eq(t, "Turing", found[1].Last)
+				xt.Eq(t, 3, found[0].Meta.SchemaVersion)
+				xt.Eq(t, int64(1), found[0].Meta.Version)
+			})
+
+			t.Run("objects loaded before can be stored", func(t *testing.T) {
+				held.Last = "King"
+				xt.OK(t, people.StoreContext(ctx, held))
+				xt.Eq(t, int64(2), held.Meta.Version)
+				xt.Eq(t, 3, held.Meta.SchemaVersion)
+			})
+
+			t.Run("nothing left to upgrade", func(t *testing.T) {
+				n, err := session.MigrateCollectionContext(ctx, &Person{})
+				xt.OK(t, err)
+				xt.Eq(t, 0, n)
+			})
+
+			t.Run("model must be migrator", func(t *testing.T) {
+				_, err := session.MigrateCollectionContext(ctx, &PersonV1{})
+				xt.KO(t, err)
+			})
+		})
+	}
+}
+
+func TestSession_OpenCollection(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+			xt.OK(t, books.Store(&Book{ISBN13: "978-0-2620-4630-5", Title: "The Little Schemer"}))
+			xt.OK(t, session.RemoveCollection(&Edition{}))
+
+			t.Run("collection names", func(t *testing.T) {
+				names, err := session.CollectionNamesContext(ctx)
+				xt.OK(t, err)
+				xt.Assert(t, xstrings.Search(names, "books") != -1)
+				xt.Eq(t, -1, xstrings.Search(names, "editions"))
+			})
+
+			t.Run("indexes", func(t *testing.T) {
+				indexes, err := session.IndexesContext(ctx, &Book{})
+				xt.OK(t, err)
+
+				have := map[string]kolektor.IndexStatus{}
+				for _, idx := range indexes {
+					have[idx.Name] = idx
+				}
+
+				xt.Assert(t, have["uq_books_isbn13"].Unique)
+				xt.Assert(t, have["uq_books_isbn13"].Managed)
+				xt.Assert(t, have["uq_books_isbn13"].Valid)
+				xt.Assert(t, have["ix_books_title"].Managed)
+				xt.Assert(t, !have["ix_books_title"].Unique)
+				xt.Assert(t, have["uq_books_uid"].Unique)
+				xt.Assert(t, !have["uq_books_uid"].Managed)
+				if storeKind != kolektor.Memory {
+					xt.Assert(t, !have["ix_books_created"].Unique)
+					xt.Assert(t, have["ix_books_created"].Valid)
+				}
+
+				indexes, err = session.IndexesContext(ctx, &Edition{})
+				xt.OK(t, err)
+				xt.Eq(t, 0, len(indexes))
+			})
+
+			t.Run("open existing collection", func(t *testing.T) {
+				opened, err := session.OpenCollectionContext(ctx, &Book{})
+				xt.OK(t, err)
+				n, err := opened.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Assert(t, n > 0)
+			})
+
+			t.Run("collection must exist", func(t *testing.T) {
+				_, err := session.OpenCollectionContext(ctx, &Edition{})
+				xt.KO(t, err)
+				xt.Eq(t, "kolekto: collection editions does not exist", err.Error())
+			})
+		})
+	}
+}
+
+type testSpan struct {
+	op    kolektor.Operation
+	rows  int64
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetRows(n int64) { s.rows = n }
+func (s *testSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+// testTracer records the spans of all traced operations.
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (tr *testTracer) Start(ctx context.Context, op kolektor.Operation) (context.Context, kolektor.Span) {
+	span := &testSpan{op: op, rows: -1}
+	tr.spans = append(tr.spans, span)
+	return ctx, span
+}
+
+// names returns the names of the operations traced since the first n spans.
+func (tr *testTracer) names(n int) []string {
+	var names []string
+	for _, span := range tr.spans[n:] {
+		names = append(names, span.op.Name)
+	}
+	return names
+}
+
+func TestSession_SetTracer(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			_, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			tracer := &testTracer{}
+			session.SetTracer(tracer)
+
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			t.Run("initializing collection reconciles indexes", func(t *testing.T) {
+				xt.Eq(t, []string{"InitCollection", "ReconcileIndexes"}, tracer.names(0))
+				for _, span := range tracer.spans {
+					xt.Eq(t, "books", span.op.Collection)
+					xt.Eq(t, storeKind.String(), span.op.Store)
+					xt.Assert(t, span.ended)
+					xt.OK(t, span.err)
+				}
+				// indexes were created before tracing
+				xt.Eq(t, int64(0), tracer.spans[1].rows)
+			})
+
+			t.Run("created indexes are counted", func(t *testing.T) {
+				xt.OK(t, session.RemoveCollection(&Book{}))
+
+				n := len(tracer.spans)
+				_, err := session.Collection(&Book{})
+				xt.OK(t, err)
+
+				xt.Eq(t, []string{"InitCollection", "ReconcileIndexes"}, tracer.names(n))
+				indexes, err := stores.ModelIndexes(&Book{}, storeKind)
+				xt.OK(t, err)
+				xt.Assert(t, len(indexes) > 0, "expected indexes")
+				xt.Eq(t, int64(len(indexes)), tracer.spans[n+1].rows)
+			})
+
+			t.Run("rows are counted", func(t *testing.T) {
+				xt.OK(t, session.RemoveCollection(&Review{}))
+				reviews, err := session.Collection(&Review{})
+				xt.OK(t, err)
+
+				n := len(tracer.spans)
+				xt.OK(t, reviews.StoreManyContext(ctx, []*Review{{ISBN13: "1", Rating: 3}, {ISBN13: "2", Rating: 4}}))
+				var found []*Review
+				xt.OK(t, reviews.FindContext(ctx, &found, nil))
+
+				xt.Eq(t, []string{"BeginTx", "StoreObjects", "Commit", "QueryObjects"}, tracer.names(n))
+				xt.Eq(t, int64(2), tracer.spans[n+1].rows)
+				xt.Eq(t, int64(2), tracer.spans[n+3].rows)
+				xt.Assert(t, tracer.spans[n+3].ended)
+			})
+
+			t.Run("errors are recorded", func(t *testing.T) {
+				n := len(tracer.spans)
+				xt.KO(t, books.Get(&Book{}, "no-such-uid"))
+
+				xt.Eq(t, []string{"GetObject"}, tracer.names(n))
+				xt.Assert(t, errors.As(tracer.spans[n].err, &stores.ErrNoObject{}))
+				xt.Eq(t, int64(-1), tracer.spans[n].rows)
+			})
+
+			t.Run("transactions", func(t *testing.T) {
+				n := len(tracer.spans)
+				xt.OK(t, session.Tx(ctx, func(tx *Session) error {
+					txBooks, err := tx.Collection(&Book{})
+					if err != nil {
+						return err
+					}
+					return txBooks.Store(&Book{ISBN13: "978-1-59327-584-6", Title: "The Linux Command Line"})
+				}))
+
+				xt.Eq(t, []string{"BeginTx", "StoreObject", "Commit"}, tracer.names(n))
+				xt.Eq(t, "", tracer.spans[n].op.Collection)
+				xt.Eq(t, "books", tracer.spans[n+1].op.Collection)
+			})
+
+			t.Run("tracing is disabled", func(t *testing.T) {
+				session.SetTracer(nil)
+				n := len(tracer.spans)
+				_, err := books.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, n, len(tracer.spans))
+			})
+		})
+	}
+}
+
+type testObservation struct {
+	op  kolektor.Operation
+	err error
+}
+
+// testMetrics records the observed operations.
+type testMetrics struct {
+	observed []testObservation
+}
+
+func (m *testMetrics) ObserveOperation(op kolektor.Operation, _ time.Duration, err error) {
+	m.observed = append(m.observed, testObservation{op: op, err: err})
+}
+
+func TestSession_SetMetrics(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			metrics := &testMetrics{}
+			session.SetMetrics(metrics)
+			defer session.SetMetrics(nil)
+
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			t.Run("each operation is observed once", func(t *testing.T) {
+				book := &Book{ISBN13: "978-0-13-110362-7", Title: "The C Programming Language"}
+				xt.OK(t, books.Store(book))
+				xt.OK(t, books.Get(&Book{}, book.Meta.UID))
+				_, err := books.SumContext(ctx, "year", nil)
+				xt.OK(t, err)
+				xt.OK(t, books.DeleteContext(ctx, book))
+
+				var names []string
+				for _, o := range metrics.observed {
+					names = append(names, o.op.Name)
+					xt.Eq(t, "books", o.op.Collection)
+					xt.Eq(t, storeKind.String(), o.op.Store)
+					xt.OK(t, o.err)
+				}
+				xt.Eq(t, []string{"Store", "Get", "Sum", "Delete"}, names)
+			})
+
+			t.Run("errors are observed", func(t *testing.T) {
+				metrics.observed = nil
+				xt.KO(t, books.DeleteByUIDContext(ctx, "no-such-uid"))
+				xt.Eq(t, 1, len(metrics.observed))
+				xt.Assert(t, errors.As(metrics.observed[0].err, &stores.ErrNoObject{}))
+			})
+
+			t.Run("operations within transactions", func(t *testing.T) {
+				metrics.observed = nil
+				xt.OK(t, session.Tx(ctx, func(tx *Session) error {
+					txBooks, err := tx.Collection(&Book{})
+					if err != nil {
+						return err
+					}
+					_, err = txBooks.CountContext(ctx, nil)
+					return err
+				}))
+				xt.Eq(t, 1, len(metrics.observed))
+				xt.Eq(t, "Count", metrics.observed[0].op.Name)
+			})
+
+			t.Run("pool statistics", func(t *testing.T) {
+				stats, ok := session.PoolStats()
+				xt.Eq(t, storeKind != kolektor.Memory, ok)
+				if ok {
+					xt.Assert(t, stats.Open >= stats.Idle)
+				}
+			})
+		})
+	}
+}
+
+// testLogRecords returns the records logged as JSON to buf, and resets buf.
+func testLogRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record map[string]any
+		xt.OK(t, dec.Decode(&record))
+		records = append(records, record)
+	}
+	buf.Reset()
+
+	return records
+}
+
+type noSuchCollection struct {
+	kolektor.Model
+}
+
+func (noSuchCollection) CollectionName() string {
+	return "no_such_collection"
+}
+
+func TestSession_SetLogger(t *testing.T) {
+	for storeKind, storeFn := range stores.Registered() {
+		session, err := newSession(testAllDSN[storeKind], storeFn)
+		xt.OK(t, err)
+
+		t.Run(storeKind.String(), func(t *testing.T) {
+			ctx := context.Background()
+			xt.OK(t, session.RemoveCollection(&Book{}))
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			session.SetLogger(logger, nil)
+			defer session.SetLogger(nil, nil)
+
+			books, err := session.Collection(&Book{})
+			xt.OK(t, err)
+
+			if storeKind == kolektor.Memory {
+				xt.OK(t, books.Store(&Book{ISBN13: "978-0-13-110362-7", Title: "The C Programming Language"}))
+				xt.Eq(t, 0, len(testLogRecords(t, &buf)))
+				return
+			}
+
+			t.Run("initializing collection is logged", func(t *testing.T) {
+				records := testLogRecords(t, &buf)
+				xt.Assert(t, len(records) > 0)
+				xt.Assert(t, strings.HasPrefix(records[0]["sql"].(string), "CREATE TABLE IF NOT EXISTS books"))
+			})
+
+			t.Run("arguments are redacted", func(t *testing.T) {
+				xt.OK(t, books.Store(&Book{ISBN13: "978-0-13-110362-7", Title: "The C Programming Language"}))
+
+				// MySQL retrieves the metadata using a second statement
+				records := testLogRecords(t, &buf)
+				xt.Assert(t, len(records) > 0)
+				xt.Eq(t, "DEBUG", records[0]["level"])
+				xt.Eq(t, "statement", records[0]["msg"])
+				xt.Eq(t, storeKind.String(), records[0]["store"])
+				xt.Assert(t, strings.HasPrefix(records[0]["sql"].(string), "INSERT INTO books"))
+				xt.Assert(t, records[0]["duration"] != nil)
+				for _, arg := range records[0]["args"].([]any) {
+					xt.Eq(t, Redacted, arg)
+				}
+			})
+
+			t.Run("documents are redacted", func(t *testing.T) {
+				session.SetLogger(logger, &LogOptions{Redact: RedactDocuments})
+				xt.OK(t, books.Store(&Book{ISBN13: "978-1-59327-584-6", Title: "The Linux Command Line"}))
+
+				records := testLogRecords(t, &buf)
+				xt.Assert(t, len(records) > 0)
+				args := records[0]["args"].([]any)
+				xt.Eq(t, Redacted, args[0])
+				xt.Assert(t, args[1] != Redacted)
+			})
+
+			t.Run("failed statements", func(t *testing.T) {
+				session.SetLogger(logger, &LogOptions{Level: slog.LevelInfo})
+				coll, err := newCollection(session, &noSuchCollection{})
+				xt.OK(t, err)
+				_, err = coll.CountContext(ctx, nil)
+				xt.KO(t, err)
+
+				records := testLogRecords(t, &buf)
+				xt.Eq(t, 1, len(records))
+				xt.Eq(t, "ERROR", records[0]["level"])
+				xt.Eq(t, "statement failed", records[0]["msg"])
+				xt.Assert(t, records[0]["error"] != "")
+			})
+
+			t.Run("slow statements", func(t *testing.T) {
+				session.SetLogger(logger, &LogOptions{SlowThreshold: time.Nanosecond, Redact: RedactNone})
+				_, err := books.CountContext(ctx, nil)
+				xt.OK(t, err)
+
+				records := testLogRecords(t, &buf)
+				xt.Eq(t, 1, len(records))
+				xt.Eq(t, "WARN", records[0]["level"])
+				xt.Eq(t, "slow statement", records[0]["msg"])
+				xt.Eq(t, true, records[0]["slow"])
+			})
+
+			t.Run("levels below the level of the logger", func(t *testing.T) {
+				session.SetLogger(logger, &LogOptions{Level: slog.Level(-8)})
+				_, err := books.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, 0, len(testLogRecords(t, &buf)))
+			})
+
+			t.Run("statements within transactions", func(t *testing.T) {
+				session.SetLogger(logger, nil)
+				xt.OK(t, session.Tx(ctx, func(tx *Session) error {
+					txBooks, err := tx.Collection(&Book{})
+					if err != nil {
+						return err
+					}
+					_, err = txBooks.CountContext(ctx, nil)
+					return err
+				}))
+
+				records := testLogRecords(t, &buf)
+				xt.Assert(t, len(records) > 0)
+				xt.Assert(t, strings.HasPrefix(records[len(records)-1]["sql"].(string), "SELECT COUNT(*) FROM books"))
+			})
+
+			t.Run("logging is disabled", func(t *testing.T) {
+				session.SetLogger(nil, nil)
+				_, err := books.CountContext(ctx, nil)
+				xt.OK(t, err)
+				xt.Eq(t, 0, len(testLogRecords(t, &buf)))
+			})
+		})
+	}
+}
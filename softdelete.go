@@ -0,0 +1,127 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// softDeletes returns whether objects of the collection are soft deleted.
+func (coll *Collection) softDeletes() bool {
+	sd, ok := coll.model.(kolektor.SoftDeleter)
+	return ok && sd.SoftDelete()
+}
+
+// visible returns filter with a condition excluding soft deleted objects,
+// unless filter already has a condition on the deleted field.
+func (coll *Collection) visible(filter kolektor.Filter) kolektor.Filter {
+	if !coll.softDeletes() {
+		return filter
+	}
+
+	for _, cond := range filter {
+		if cond.Field == "deleted" {
+			return filter
+		}
+	}
+
+	return append(filter[:len(filter):len(filter)],
+		kolektor.Condition{Field: "deleted", Operator: kolektor.OpIsNull})
+}
+
+// visibleQuery returns a copy of query of which the filter excludes soft
+// deleted objects, unless query includes them.
+func (coll *Collection) visibleQuery(query *kolektor.Query) *kolektor.Query {
+	if !coll.softDeletes() || (query != nil && query.IncludeDeleted) {
+		return query
+	}
+
+	q := kolektor.Query{}
+	if query != nil {
+		q = *query
+	}
+	q.Filter = coll.visible(q.Filter)
+
+	return &q
+}
+
+// Restore restores obj which was soft deleted. The object is identified
+// using its ID or, when the ID is not available, its UID.
+// Error stores.ErrNoObject is returned when no soft deleted object was found.
+func (coll *Collection) Restore(obj kolektor.Modeler) error {
+	return coll.RestoreContext(context.Background(), obj)
+}
+
+// RestoreContext restores an object like Restore, using the provided context.
+func (coll *Collection) RestoreContext(ctx context.Context, obj kolektor.Modeler) (err error) {
+	defer coll.observe("Restore", time.Now(), &err)
+
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &kolektor.InvalidObjectError{Type: reflect.TypeOf(obj)}
+	}
+
+	if !coll.softDeletes() {
+		return fmt.Errorf("objects of %s are not soft deleted", coll.model.CollectionName())
+	}
+
+	filter, err := objectFilter(obj)
+	if err != nil {
+		return err
+	}
+
+	n, err := coll.ses.store.RestoreObjects(ctx, coll.model, filter)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return stores.ErrNoObject{Name: coll.model.CollectionName()}
+	}
+
+	return nil
+}
+
+// Purge removes the objects which were soft deleted more than olderThan
+// ago, and returns the number of removed objects. Use 0 to remove all
+// soft deleted objects.
+func (coll *Collection) Purge(olderThan time.Duration) (int64, error) {
+	return coll.PurgeContext(context.Background(), olderThan)
+}
+
+// PurgeContext purges objects like Purge, using the provided context.
+func (coll *Collection) PurgeContext(ctx context.Context, olderThan time.Duration) (_ int64, err error) {
+	defer coll.observe("Purge", time.Now(), &err)
+
+	if !coll.softDeletes() {
+		return 0, fmt.Errorf("objects of %s are not soft deleted", coll.model.CollectionName())
+	}
+
+	filter := kolektor.Filter{{Field: "deleted", Operator: kolektor.OpIsNotNull}}
+	if olderThan > 0 {
+		filter = kolektor.Filter{
+			{Field: "deleted", Operator: kolektor.OpLess, Value: time.Now().UTC().Add(-olderThan)},
+		}
+	}
+
+	return coll.ses.store.DeleteObjects(ctx, coll.model, filter)
+}
+
+// objectFilter returns the filter identifying obj using its ID or, when
+// the ID is not available, its UID.
+func objectFilter(obj kolektor.Modeler) (kolektor.Filter, error) {
+	switch {
+	case obj.GetID() != 0:
+		return kolektor.Filter{{Field: "id", Operator: kolektor.OpEqual, Value: obj.GetID()}}, nil
+	case obj.GetUID() != "":
+		return kolektor.Filter{{Field: "uid", Operator: kolektor.OpEqual, Value: obj.GetUID()}}, nil
+	default:
+		return nil, fmt.Errorf("object has no ID or UID")
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package stores
+
+import "github.com/golistic/kolekto/kolektor"
+
+// MetaQueue keeps the metadata of inserted objects per UID, so that it
+// can be matched with the objects in the order they were inserted.
+type MetaQueue map[string][]*kolektor.Meta
+
+// Push adds meta for uid.
+func (q MetaQueue) Push(uid string, meta *kolektor.Meta) {
+	q[uid] = append(q[uid], meta)
+}
+
+// Pop returns and removes the first metadata added for uid. It returns
+// nil when no metadata is available.
+func (q MetaQueue) Pop(uid string) *kolektor.Meta {
+	metas := q[uid]
+	if len(metas) == 0 {
+		return nil
+	}
+	q[uid] = metas[1:]
+	return metas[0]
+}
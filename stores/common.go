@@ -2,6 +2,144 @@
 
 package stores
 
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/xstrings"
+)
+
 const SizeUID = 200
 
-var ReservedFields = []string{"id", "uid", "created", "updated", "data"}
+var ReservedFields = []string{"id", "uid", "created", "updated", "version", "deleted", "schema_version", "data"}
+
+var reFieldPath = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// IsReservedField returns whether name is one of the ReservedFields.
+func IsReservedField(name string) bool {
+	return xstrings.Search(ReservedFields, name) != -1
+}
+
+// SchemaVersion returns the version of the structure of the JSON documents
+// of model, which is 1 unless model is a kolektor.Migrator.
+func SchemaVersion(model kolektor.Modeler) int {
+	if m, ok := model.(kolektor.Migrator); ok {
+		return m.SchemaVersion()
+	}
+	return 1
+}
+
+// MarshalObject returns the JSON document of obj, which does not include the
+// metadata. When obj is a kolektor.MetaGetter, its metadata is restored once
+// marshalled, so that it is left untouched when storing obj fails.
+func MarshalObject(obj kolektor.Modeler) ([]byte, error) {
+	if mg, ok := obj.(kolektor.MetaGetter); ok {
+		defer obj.SetMeta(mg.GetMeta())
+	}
+	obj.SetMeta(nil) // we do not save Meta in the JSON document
+
+	return json.Marshal(obj)
+}
+
+// IsRawExpression returns whether field is a raw SQL expression, which
+// is the case when it starts with an opening parenthesis.
+func IsRawExpression(field string) bool {
+	return strings.HasPrefix(field, "(")
+}
+
+// FieldPath splits field, a path within a JSON document, into its keys.
+// An error is returned when field is not a valid path.
+func FieldPath(field string) ([]string, error) {
+	if !reFieldPath.MatchString(field) {
+		return nil, fmt.Errorf("invalid field path '%s'", field)
+	}
+	return strings.Split(field, "."), nil
+}
+
+// JSONValue converts value so that it can be compared with the value of
+// a JSON document extracted as text. Booleans become 'true' or 'false',
+// and time.Time values are formatted as done when encoding to JSON.
+func JSONValue(value any) any {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.Format(time.RFC3339Nano)
+	default:
+		return value
+	}
+}
+
+// IsNumeric returns whether value is an integer or floating point number.
+func IsNumeric(value any) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// LikeValue returns value as text when it is numeric, so that it can be used
+// as pattern of the LIKE operator. Numbers are formatted as when encoding to
+// JSON. Other values are returned as is.
+func LikeValue(value any) any {
+	if !IsNumeric(value) {
+		return value
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return string(data)
+}
+
+// InValues returns the elements of value, which must be a slice or an
+// array, as used with the IN-operator.
+func InValues(value any) ([]any, error) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("value for IN must be slice or array; got %T", value)
+	}
+
+	values := make([]any, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values, nil
+}
+
+// CheckKeyset checks whether values can be used for keyset pagination on
+// the fields of order, and returns whether the order is descending.
+func CheckKeyset(order []kolektor.Order, values []any) (bool, error) {
+	if len(order) == 0 || len(order) != len(values) {
+		return false, fmt.Errorf("keyset needs a value for each order field")
+	}
+
+	for _, o := range order {
+		if !IsReservedField(o.Field) || o.Field == "data" || o.Field == "deleted" || o.Field == "schema_version" {
+			return false, fmt.Errorf("keyset field '%s' must be id, uid, created, updated, or version", o.Field)
+		}
+		if o.Descending != order[0].Descending {
+			return false, fmt.Errorf("keyset fields must be sorted in the same direction")
+		}
+	}
+
+	return order[0].Descending, nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmemory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// CountObjects returns the number of objects in the model's collection
+// matching filter.
+func (s *Store) CountObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	var count int64
+	err := s.read(ctx, model, func(coll *collection) error {
+		docs, err := filterDocuments(coll, filter)
+		count = int64(len(docs))
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed counting objects (%w)", err)
+	}
+
+	return count, nil
+}
+
+// ObjectsExist returns whether at least one object in the model's
+// collection matches filter.
+func (s *Store) ObjectsExist(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (bool, error) {
+	count, err := s.CountObjects(ctx, model, filter)
+	if err != nil {
+		return false, fmt.Errorf("failed checking objects (%w)", err)
+	}
+
+	return count > 0, nil
+}
+
+// aggregator accumulates the values of one group.
+type aggregator struct {
+	group *string
+	count int
+	sum   float64
+	min   float64
+	max   float64
+}
+
+func (a *aggregator) add(v float64) {
+	if a.count == 0 || v < a.min {
+		a.min = v
+	}
+	if a.count == 0 || v > a.max {
+		a.max = v
+	}
+	a.count++
+	a.sum += v
+}
+
+// result returns the result of aggregating the values using fn. The value
+// is nil when there were no values, or when counting.
+func (a *aggregator) result(fn kolektor.AggregateFunc) kolektor.AggregateResult {
+	r := kolektor.AggregateResult{Group: a.group}
+	if fn == kolektor.AggCount {
+		r.Count = int64(a.count)
+		return r
+	}
+	if a.count == 0 {
+		return r
+	}
+
+	var v float64
+	switch fn {
+	case kolektor.AggSum:
+		v = a.sum
+	case kolektor.AggMin:
+		v = a.min
+	case kolektor.AggMax:
+		v = a.max
+	case kolektor.AggAvg:
+		v = a.sum / float64(a.count)
+	}
+	r.Value = &v
+	return r
+}
+
+// AggregateObjects aggregates the values of the objects in the model's
+// collection as defined by agg. Only numeric values, or strings holding
+// a number, are aggregated.
+func (s *Store) AggregateObjects(ctx context.Context, model kolektor.Modeler, agg kolektor.Aggregation) ([]kolektor.AggregateResult, error) {
+	if !agg.Func.Valid() {
+		return nil, fmt.Errorf("failed aggregating objects (unsupported function '%s')", agg.Func)
+	}
+
+	if agg.Field != "" {
+		if _, err := stores.FieldPath(agg.Field); err != nil {
+			return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+		}
+	} else if agg.Func != kolektor.AggCount {
+		return nil, fmt.Errorf("failed aggregating objects (field required for %s)", agg.Func)
+	}
+
+	if agg.GroupBy != "" {
+		if _, err := stores.FieldPath(agg.GroupBy); err != nil {
+			return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+		}
+	}
+
+	groups := map[string]*aggregator{}
+	var nullGroup *aggregator
+
+	err := s.read(ctx, model, func(coll *collection) error {
+		docs, err := filterDocuments(coll, agg.Filter)
+		if err != nil {
+			return err
+		}
+
+		if agg.GroupBy == "" {
+			nullGroup = &aggregator{}
+		}
+
+		for _, doc := range docs {
+			a := nullGroup
+			if agg.GroupBy != "" {
+				if group, ok := groupValue(doc, agg.GroupBy); ok {
+					if a = groups[group]; a == nil {
+						a = &aggregator{group: &group}
+						groups[group] = a
+					}
+				} else if a == nil {
+					nullGroup = &aggregator{}
+					a = nullGroup
+				}
+			}
+
+			if agg.Field == "" {
+				a.add(0)
+				continue
+			}
+			if v, ok := numericValue(doc, agg.Field); ok {
+				a.add(v)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []kolektor.AggregateResult
+	if nullGroup != nil {
+		result = append(result, nullGroup.result(agg.Func))
+	}
+	for _, name := range names {
+		result = append(result, groups[name].result(agg.Func))
+	}
+
+	return result, nil
+}
+
+// groupValue returns the value of field as text, as used for grouping.
+func groupValue(doc *document, field string) (string, bool) {
+	v, _ := doc.value(field)
+	return likeText(v)
+}
+
+// numericValue returns the value of field as number.
+func numericValue(doc *document, field string) (float64, bool) {
+	v, _ := doc.value(field)
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmemory
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// database holds the collections of a memory store. Documents are never
+// modified once stored, but replaced, so that cloning a database only
+// copies maps.
+type database struct {
+	mu          sync.RWMutex
+	collections map[string]*collection
+	// revs counts the changes made to each collection, so that transactions
+	// detect collections changed by others when committing.
+	revs     map[string]int64
+	readOnly bool
+}
+
+// collection holds the documents of a collection keyed by their ID.
+type collection struct {
+	name    string
+	lastID  int64
+	docs    map[int64]*document
+	indexes []kolektor.Index
+	// unique maps the name of each unique index to the values found
+	// in the documents, encoded as JSON, and the ID of the document.
+	unique map[string]map[string]int64
+	// uids maps the UID of each document to its ID.
+	uids map[string]int64
+}
+
+// document is a stored JSON document together with its metadata.
+type document struct {
+	id            int64
+	uid           string
+	created       time.Time
+	updated       *time.Time
+	version       int64
+	deleted       *time.Time
+	schemaVersion int
+	data          []byte
+	fields        map[string]any
+}
+
+func newDatabase() *database {
+	return &database{collections: map[string]*collection{}, revs: map[string]int64{}}
+}
+
+// clone returns a copy of db which can be changed without affecting db.
+func (db *database) clone() *database {
+	c := newDatabase()
+	for name, coll := range db.collections {
+		c.collections[name] = coll.clone()
+	}
+	for name, rev := range db.revs {
+		c.revs[name] = rev
+	}
+	return c
+}
+
+// collection returns the collection called name. An error is returned
+// when the collection was not initialized.
+func (db *database) collection(name string) (*collection, error) {
+	coll, have := db.collections[name]
+	if !have {
+		return nil, &kolektor.StoreError{
+			Kind: kolektor.ErrNotFound,
+			Err:  fmt.Errorf("collection %s does not exist", name),
+		}
+	}
+	return coll, nil
+}
+
+func newCollection(name string) *collection {
+	return &collection{
+		name:   name,
+		docs:   map[int64]*document{},
+		unique: map[string]map[string]int64{},
+		uids:   map[string]int64{},
+	}
+}
+
+func (c *collection) clone() *collection {
+	n := newCollection(c.name)
+	n.lastID = c.lastID
+	n.indexes = c.indexes
+	for id, doc := range c.docs {
+		n.docs[id] = doc
+	}
+	for uid, id := range c.uids {
+		n.uids[uid] = id
+	}
+	for name, values := range c.unique {
+		n.unique[name] = make(map[string]int64, len(values))
+		for k, id := range values {
+			n.unique[name][k] = id
+		}
+	}
+	return n
+}
+
+// sorted returns the documents sorted by their ID.
+func (c *collection) sorted() []*document {
+	docs := make([]*document, 0, len(c.docs))
+	for _, doc := range c.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].id < docs[j].id })
+	return docs
+}
+
+// setIndexes replaces the indexes of the collection. The expression of
+// each index, if any, must be a path within the JSON documents. An error is
+// returned when the stored documents violate a unique index.
+func (c *collection) setIndexes(indexes []kolektor.Index) error {
+	unique := map[string]map[string]int64{}
+
+	for _, idx := range indexes {
+		paths, err := indexPaths(idx)
+		if err != nil {
+			return fmt.Errorf("failed creating index %s (%w)", idx.Name, err)
+		}
+		for _, path := range paths {
+			if _, err := stores.FieldPath(path); err != nil {
+				return fmt.Errorf("failed creating index %s (%w)", idx.Name, err)
+			}
+		}
+		if !idx.Unique {
+			continue
+		}
+
+		unique[idx.Name] = map[string]int64{}
+		for _, doc := range c.sorted() {
+			key, ok := indexKey(doc, idx)
+			if !ok {
+				continue
+			}
+			if _, have := unique[idx.Name][key]; have {
+				return fmt.Errorf("failed creating index %s (duplicate value %s)", idx.Name, key)
+			}
+			unique[idx.Name][key] = doc.id
+		}
+	}
+
+	c.indexes = indexes
+	c.unique = unique
+	return nil
+}
+
+// indexChanges returns the number of indexes created or dropped when
+// replacing the indexes have with want; changed indexes count twice.
+func indexChanges(have, want []kolektor.Index) int64 {
+	haveByName := map[string]kolektor.Index{}
+	for _, idx := range have {
+		haveByName[idx.Name] = idx
+	}
+
+	var n int64
+	for _, idx := range want {
+		old, ok := haveByName[idx.Name]
+		switch {
+		case !ok:
+			n++
+		case !reflect.DeepEqual(old, idx):
+			n += 2
+		}
+		delete(haveByName, idx.Name)
+	}
+
+	return n + int64(len(haveByName))
+}
+
+// duplicateUIDError returns the error of a document of the collection called
+// name using uid, which is already used by another one.
+func duplicateUIDError(name, uid string) error {
+	return &kolektor.StoreError{
+		Kind:  kolektor.ErrDuplicateKey,
+		Index: "uq_" + name + "_uid",
+		Err:   fmt.Errorf("duplicate uid %s", uid),
+	}
+}
+
+// put stores doc replacing the document with the same ID, if any. An error
+// is returned when doc violates a unique index, or its UID is used by
+// another document.
+func (c *collection) put(doc *document) error {
+	if id, have := c.uids[doc.uid]; have && id != doc.id {
+		return duplicateUIDError(c.name, doc.uid)
+	}
+
+	keys := map[string]string{}
+
+	for _, idx := range c.indexes {
+		if !idx.Unique {
+			continue
+		}
+		key, ok := indexKey(doc, idx)
+		if !ok {
+			continue
+		}
+		if id, have := c.unique[idx.Name][key]; have && id != doc.id {
+			return &kolektor.StoreError{
+				Kind:  kolektor.ErrDuplicateKey,
+				Index: idx.Name,
+				Err:   fmt.Errorf("duplicate value %s for unique index %s", key, idx.Name),
+			}
+		}
+		keys[idx.Name] = key
+	}
+
+	if old, have := c.docs[doc.id]; have {
+		c.unindex(old)
+	}
+
+	for name, key := range keys {
+		c.unique[name][key] = doc.id
+	}
+	c.uids[doc.uid] = doc.id
+	c.docs[doc.id] = doc
+
+	return nil
+}
+
+// remove removes doc from the collection.
+func (c *collection) remove(doc *document) {
+	c.unindex(doc)
+	delete(c.docs, doc.id)
+}
+
+func (c *collection) unindex(doc *document) {
+	if c.uids[doc.uid] == doc.id {
+		delete(c.uids, doc.uid)
+	}
+	for _, idx := range c.indexes {
+		if !idx.Unique {
+			continue
+		}
+		if key, ok := indexKey(doc, idx); ok && c.unique[idx.Name][key] == doc.id {
+			delete(c.unique[idx.Name], key)
+		}
+	}
+}
+
+// indexPaths returns the paths within the JSON documents indexed by idx:
+// its expression, or the paths of its fields.
+func indexPaths(idx kolektor.Index) ([]string, error) {
+	if idx.Expression != "" {
+		return []string{idx.Expression}, nil
+	}
+
+	if len(idx.Fields) == 0 {
+		return nil, fmt.Errorf("index needs an expression or fields")
+	}
+
+	paths := make([]string, len(idx.Fields))
+	for i, field := range idx.Fields {
+		paths[i] = field.Path
+	}
+	return paths, nil
+}
+
+// indexKey returns the values of doc for idx encoded as JSON. Like SQL
+// NULL, documents without value for any of the paths are not indexed.
+func indexKey(doc *document, idx kolektor.Index) (string, bool) {
+	paths, err := indexPaths(idx)
+	if err != nil {
+		return "", false
+	}
+
+	values := make([]any, len(paths))
+	for i, path := range paths {
+		v, ok := doc.value(path)
+		if !ok || v == nil {
+			return "", false
+		}
+		values[i] = v
+	}
+
+	var b []byte
+	if len(values) == 1 {
+		b, err = json.Marshal(values[0])
+	} else {
+		b, err = json.Marshal(values)
+	}
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// newDocument returns a document for the JSON encoded data.
+func newDocument(data []byte) (*document, error) {
+	doc := &document{data: data}
+	if err := json.Unmarshal(data, &doc.fields); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// meta returns the metadata of the document.
+func (d *document) meta() *kolektor.Meta {
+	return &kolektor.Meta{
+		ID:            d.id,
+		UID:           d.uid,
+		Created:       d.created,
+		Updated:       d.updated,
+		Version:       d.version,
+		Deleted:       d.deleted,
+		SchemaVersion: d.schemaVersion,
+	}
+}
+
+// JSON returns the JSON document including its metadata. The caller owns
+// the returned slice.
+func (d *document) JSON() ([]byte, error) {
+	meta, err := json.Marshal(d.meta())
+	if err != nil {
+		return nil, err
+	}
+
+	doc := make([]byte, 0, len(d.data)+len(meta)+10)
+	doc = append(doc, `{"Meta":`...)
+	doc = append(doc, meta...)
+	if len(d.fields) > 0 {
+		doc = append(doc, ',')
+		doc = append(doc, d.data[1:]...)
+	} else {
+		doc = append(doc, '}')
+	}
+
+	return doc, nil
+}
+
+// value returns the value of field, which is either a reserved field or a
+// path within the JSON document. It returns false when it is not available.
+func (d *document) value(field string) (any, bool) {
+	switch field {
+	case "id":
+		return float64(d.id), true
+	case "uid":
+		return d.uid, true
+	case "created":
+		return d.created, true
+	case "updated":
+		if d.updated == nil {
+			return nil, true
+		}
+		return *d.updated, true
+	case "version":
+		return float64(d.version), true
+	case "deleted":
+		if d.deleted == nil {
+			return nil, true
+		}
+		return *d.deleted, true
+	case "schema_version":
+		return float64(d.schemaVersion), true
+	}
+
+	path, err := stores.FieldPath(field)
+	if err != nil {
+		return nil, false
+	}
+
+	var v any = d.fields
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return v, true
+}
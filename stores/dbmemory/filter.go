@@ -0,0 +1,405 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmemory
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// checkField returns an error when field can not be used with the memory
+// store, which is the case for raw SQL expressions.
+func checkField(field string) error {
+	switch {
+	case stores.IsRawExpression(field):
+		return fmt.Errorf("raw SQL expressions are not supported")
+	case field == "data":
+		return fmt.Errorf("field data can not be compared")
+	case stores.IsReservedField(field):
+		return nil
+	}
+
+	_, err := stores.FieldPath(field)
+	return err
+}
+
+// normalize converts value so that it can be compared with values of
+// the JSON documents: numbers become float64, and values of other types
+// are converted as when encoding them as JSON. Timestamps are kept so they
+// can be compared with the metadata.
+func normalize(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, string, bool, float64:
+		return v, nil
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return nil, nil
+		}
+		return *v, nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// compare compares a with b returning -1, 0, or +1. It returns false when
+// the values can not be compared, which is the case when their types
+// differ. Numbers stored as strings are compared numerically, and
+// timestamps are compared with strings formatted as RFC3339.
+func compare(a, b any) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		switch bv := b.(type) {
+		case float64:
+			return compareOrdered(av, bv), true
+		case string:
+			if f, err := strconv.ParseFloat(bv, 64); err == nil {
+				return compareOrdered(av, f), true
+			}
+		}
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return strings.Compare(av, bv), true
+		case float64:
+			if f, err := strconv.ParseFloat(av, 64); err == nil {
+				return compareOrdered(f, bv), true
+			}
+		case time.Time:
+			return strings.Compare(av, bv.Format(time.RFC3339Nano)), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, true
+			case bv:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	case time.Time:
+		switch bv := b.(type) {
+		case time.Time:
+			return av.Compare(bv), true
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, bv); err == nil {
+				return av.Compare(t), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func compareOrdered[T float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// likeRegexp returns the regular expression for the SQL LIKE pattern.
+// Like the default MySQL and SQLite collations, matching is case-insensitive.
+func likeRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// likeText returns the text used to match value with a LIKE pattern.
+func likeText(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	default:
+		return "", false
+	}
+}
+
+// matcher checks whether documents meet all conditions of a filter.
+type matcher []func(doc *document) bool
+
+// newMatcher returns the matcher for filter. An error is returned when
+// a condition is not supported.
+func newMatcher(filter kolektor.Filter) (matcher, error) {
+	var m matcher
+
+	for _, cond := range filter {
+		cond := cond
+		if err := checkField(cond.Field); err != nil {
+			return nil, err
+		}
+
+		switch cond.Operator {
+		case kolektor.OpIsNull, kolektor.OpIsNotNull:
+			isNull := cond.Operator == kolektor.OpIsNull
+			m = append(m, func(doc *document) bool {
+				v, ok := doc.value(cond.Field)
+				return (!ok || v == nil) == isNull
+			})
+		case kolektor.OpIn:
+			inValues, err := stores.InValues(cond.Value)
+			if err != nil {
+				return nil, err
+			}
+			for i, v := range inValues {
+				if inValues[i], err = normalize(v); err != nil {
+					return nil, err
+				}
+			}
+			m = append(m, func(doc *document) bool {
+				v, ok := doc.value(cond.Field)
+				if !ok {
+					return false
+				}
+				for _, in := range inValues {
+					if c, ok := compare(v, in); ok && c == 0 {
+						return true
+					}
+				}
+				return false
+			})
+		case kolektor.OpLike:
+			pattern, ok := stores.LikeValue(cond.Value).(string)
+			if !ok {
+				return nil, fmt.Errorf("value for LIKE must be string; got %T", cond.Value)
+			}
+			re, err := likeRegexp(pattern)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, func(doc *document) bool {
+				v, _ := doc.value(cond.Field)
+				text, ok := likeText(v)
+				return ok && re.MatchString(text)
+			})
+		default:
+			if !cond.Operator.Valid() {
+				return nil, fmt.Errorf("unsupported operator '%s'", cond.Operator)
+			}
+			value, err := normalize(cond.Value)
+			if err != nil {
+				return nil, err
+			}
+			op := cond.Operator
+			m = append(m, func(doc *document) bool {
+				v, ok := doc.value(cond.Field)
+				if !ok {
+					return false
+				}
+				c, ok := compare(v, value)
+				if !ok {
+					return false
+				}
+				return compareResult(op, c)
+			})
+		}
+	}
+
+	return m, nil
+}
+
+// compareResult returns whether the result c of comparing two values
+// satisfies op.
+func compareResult(op kolektor.Operator, c int) bool {
+	switch op {
+	case kolektor.OpEqual:
+		return c == 0
+	case kolektor.OpNotEqual:
+		return c != 0
+	case kolektor.OpLess:
+		return c < 0
+	case kolektor.OpLessEqual:
+		return c <= 0
+	case kolektor.OpGreater:
+		return c > 0
+	case kolektor.OpGreaterEqual:
+		return c >= 0
+	default:
+		return false
+	}
+}
+
+// match returns whether doc meets all conditions.
+func (m matcher) match(doc *document) bool {
+	for _, fn := range m {
+		if !fn(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterDocuments returns the documents of coll, sorted by ID, matching
+// filter.
+func filterDocuments(coll *collection, filter kolektor.Filter) ([]*document, error) {
+	m, err := newMatcher(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []*document
+	for _, doc := range coll.sorted() {
+		if m.match(doc) {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, nil
+}
+
+// queryDocuments returns the documents of coll matching query.
+func queryDocuments(coll *collection, query *kolektor.Query) ([]*document, error) {
+	if query == nil {
+		query = &kolektor.Query{}
+	}
+
+	docs, err := filterDocuments(coll, query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range query.Order {
+		if err := checkField(o.Field); err != nil {
+			return nil, err
+		}
+	}
+
+	if query.After != nil {
+		if docs, err = afterKeyset(docs, query.Order, query.After); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(query.Order) > 0 {
+		sort.SliceStable(docs, func(i, j int) bool {
+			for _, o := range query.Order {
+				c := compareOrder(docs[i], docs[j], o.Field)
+				if o.Descending {
+					c = -c
+				}
+				if c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+	}
+
+	if query.Offset > 0 {
+		if query.Offset >= len(docs) {
+			return nil, nil
+		}
+		docs = docs[query.Offset:]
+	}
+
+	if query.Limit > 0 && query.Limit < len(docs) {
+		docs = docs[:query.Limit]
+	}
+
+	return docs, nil
+}
+
+// afterKeyset returns the documents sorting after the keyset values.
+func afterKeyset(docs []*document, order []kolektor.Order, after []any) ([]*document, error) {
+	descending, err := stores.CheckKeyset(order, after)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, len(after))
+	for i, v := range after {
+		if values[i], err = normalize(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []*document
+	for _, doc := range docs {
+		c := 0
+		for i, o := range order {
+			v, _ := doc.value(o.Field)
+			if c, _ = compare(v, values[i]); c != 0 {
+				break
+			}
+		}
+		if (!descending && c > 0) || (descending && c < 0) {
+			result = append(result, doc)
+		}
+	}
+
+	return result, nil
+}
+
+// compareOrder compares the value of field of documents a and b. Missing
+// values sort first, followed by booleans, numbers, and strings.
+func compareOrder(a, b *document, field string) int {
+	av, _ := a.value(field)
+	bv, _ := b.value(field)
+
+	ar, br := typeRank(av), typeRank(bv)
+	if ar != br {
+		return compareOrdered(float64(ar), float64(br))
+	}
+
+	c, _ := compare(av, bv)
+	return c
+}
+
+func typeRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	case time.Time:
+		return 4
+	default:
+		return 5
+	}
+}
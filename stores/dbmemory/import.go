@@ -0,0 +1,76 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmemory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// ImportObject inserts the JSON document data into the model's collection
+// using meta as metadata. The ID and UID are assigned when they are not
+// set, as is the creation time, while the version and schema version are at
+// least 1. When an object with the same UID exists, onConflict defines
+// whether an error is returned, the document is skipped, or the object is
+// overwritten. It returns whether the document was stored.
+func (s *Store) ImportObject(ctx context.Context, model kolektor.Modeler, meta kolektor.Meta, data []byte,
+	onConflict kolektor.ImportConflict) (bool, error) {
+
+	doc, err := newDocument(data)
+	if err != nil {
+		return false, fmt.Errorf("failed importing object (%w)", err)
+	}
+
+	doc.id = meta.ID
+	doc.uid = meta.UID
+	doc.created = meta.Created.UTC()
+	doc.updated = meta.Updated
+	doc.version = max(meta.Version, 1)
+	doc.deleted = meta.Deleted
+	doc.schemaVersion = max(meta.SchemaVersion, 1)
+
+	if doc.uid == "" {
+		if doc.uid, err = stores.NewUID(); err != nil {
+			return false, fmt.Errorf("failed importing object (%w)", err)
+		}
+	}
+
+	if doc.created.IsZero() {
+		doc.created = time.Now().UTC().Truncate(time.Microsecond)
+	}
+
+	var stored bool
+	err = s.write(ctx, model, func(coll *collection) error {
+		if id, have := coll.uids[doc.uid]; have {
+			switch onConflict {
+			case kolektor.ImportSkip:
+				return nil
+			case kolektor.ImportOverwrite:
+				doc.id = id
+			default:
+				return duplicateUIDError(coll.name, doc.uid)
+			}
+		} else if doc.id == 0 {
+			doc.id = coll.lastID + 1
+		} else if _, have := coll.docs[doc.id]; have {
+			return &kolektor.StoreError{Kind: kolektor.ErrDuplicateKey, Err: fmt.Errorf("duplicate id %d", doc.id)}
+		}
+
+		if err := coll.put(doc); err != nil {
+			return err
+		}
+
+		coll.lastID = max(coll.lastID, doc.id)
+		stored = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed importing object (%w)", err)
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmemory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// rows iterates over the documents found by a query. The documents are
+// collected when querying, thus later changes are not visible.
+type rows struct {
+	docs []*document
+	data []byte
+	err  error
+}
+
+var _ kolektor.Rows = &rows{}
+
+// QueryObjects retrieves the objects of the model's collection matching
+// query, and returns them as a stream of JSON documents including their
+// metadata.
+func (s *Store) QueryObjects(ctx context.Context, model kolektor.Modeler, query *kolektor.Query) (kolektor.Rows, error) {
+	var docs []*document
+	err := s.read(ctx, model, func(coll *collection) error {
+		var err error
+		docs, err = queryDocuments(coll, query)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed querying objects (%w)", err)
+	}
+
+	return &rows{docs: docs}, nil
+}
+
+// Next prepares the next document.
+func (r *rows) Next() bool {
+	if r.err != nil || len(r.docs) == 0 {
+		return false
+	}
+
+	r.data, r.err = r.docs[0].JSON()
+	r.docs = r.docs[1:]
+
+	return r.err == nil
+}
+
+// Document returns the current document.
+func (r *rows) Document() []byte {
+	return r.data
+}
+
+// Err returns the error, if any, that was encountered while iterating.
+func (r *rows) Err() error {
+	if r.err != nil {
+		return fmt.Errorf("failed reading objects (%w)", r.err)
+	}
+	return nil
+}
+
+// Close closes the rows.
+func (r *rows) Close() error {
+	r.docs = nil
+	return nil
+}
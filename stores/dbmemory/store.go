@@ -0,0 +1,651 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmemory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// Store defines the in-memory data store. It is safe for concurrent use,
+// and is mainly meant for testing applications without a database server.
+type Store struct {
+	db *database
+}
+
+var _ kolektor.Storer = &Store{}
+
+// databases keeps the databases of all stores by their DSN.
+var databases = struct {
+	sync.Mutex
+	m map[string]*database
+}{m: map[string]*database{}}
+
+func init() {
+	stores.Register(kolektor.Memory, New)
+}
+
+// New instantiates an in-memory data store. Stores instantiated with the
+// same dsn share their data within the process, while an empty dsn gives
+// each store its own data.
+func New(dsn string) (kolektor.Storer, error) {
+	if dsn == "" {
+		return &Store{db: newDatabase()}, nil
+	}
+
+	databases.Lock()
+	defer databases.Unlock()
+
+	db, have := databases.m[dsn]
+	if !have {
+		db = newDatabase()
+		databases.m[dsn] = db
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Drop removes the data shared by stores using dsn. Stores already
+// instantiated keep their data.
+func Drop(dsn string) {
+	databases.Lock()
+	defer databases.Unlock()
+	delete(databases.m, dsn)
+}
+
+// Connection returns the store itself since there are no connections.
+// The caller is responsible for type asserting the result to *Store.
+func (s *Store) Connection(context.Context) (any, error) {
+	return s, nil
+}
+
+// Name returns the name of the data store.
+func (s *Store) Name() string {
+	return "Memory"
+}
+
+// read calls fn with the collection of model while holding a read lock.
+func (s *Store) read(ctx context.Context, model kolektor.Modeler, fn func(coll *collection) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	coll, err := s.db.collection(model.CollectionName())
+	if err != nil {
+		return err
+	}
+
+	return fn(coll)
+}
+
+// write calls fn with the collection of model while holding a write lock.
+func (s *Store) write(ctx context.Context, model kolektor.Modeler, fn func(coll *collection) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.db.readOnly {
+		return fmt.Errorf("transaction is read-only")
+	}
+
+	coll, err := s.db.collection(model.CollectionName())
+	if err != nil {
+		return err
+	}
+
+	if err := fn(coll); err != nil {
+		return err
+	}
+
+	s.db.revs[coll.name]++
+	return nil
+}
+
+// GetObject retrieves a stored object and stores it in obj.
+func (s *Store) GetObject(ctx context.Context, obj kolektor.Modeler, fieldMap kolektor.FieldMap) error {
+	if len(fieldMap) == 0 {
+		return fmt.Errorf("need at least one field to filter on")
+	}
+
+	var data []byte
+	err := s.read(ctx, obj, func(coll *collection) error {
+		docs, err := filterDocuments(coll, kolektor.FilterFromFields(fieldMap))
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return stores.ErrNoObject{Name: obj.CollectionName()}
+		}
+		data, err = docs[0].JSON()
+		return err
+	})
+	if err != nil {
+		if _, ok := err.(stores.ErrNoObject); ok {
+			return err
+		}
+		return fmt.Errorf("failed getting object (%w)", err)
+	}
+
+	if err := json.Unmarshal(data, obj); err != nil {
+		return fmt.Errorf("failed getting object (%w)", err)
+	}
+
+	return nil
+}
+
+// StoreObject stores obj into the collection of the object's model.
+// Objects which were stored before are only updated when their version
+// did not change, otherwise stores.ErrConflict is returned.
+func (s *Store) StoreObject(ctx context.Context, obj kolektor.Modeler) (*kolektor.Meta, error) {
+	var meta *kolektor.Meta
+
+	err := s.write(ctx, obj, func(coll *collection) error {
+		var err error
+		meta, err = storeObject(coll, obj)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed storing object (%w)", err)
+	}
+
+	return meta, nil
+}
+
+// StoreObjects stores objs into the model's collection. Either all objects
+// are stored, or none. The returned metadata is in the same order as objs.
+func (s *Store) StoreObjects(ctx context.Context, model kolektor.Modeler, objs []kolektor.Modeler) ([]*kolektor.Meta, error) {
+	metas := make([]*kolektor.Meta, len(objs))
+
+	err := s.write(ctx, model, func(coll *collection) error {
+		// changes are made to a copy so nothing is stored on error
+		work := coll.clone()
+		for i, obj := range objs {
+			var err error
+			if metas[i], err = storeObject(work, obj); err != nil {
+				return err
+			}
+		}
+		s.db.collections[coll.name] = work
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed storing objects (%w)", err)
+	}
+
+	return metas, nil
+}
+
+// UpgradeObject replaces the JSON document of the object with the given ID
+// by data, recording the schema version of model. The version of the object
+// is kept. It returns false, leaving the object untouched, when the object
+// does not exist or its version is no longer version.
+func (s *Store) UpgradeObject(ctx context.Context, model kolektor.Modeler, id, version int64, data []byte) (bool, error) {
+	doc, err := newDocument(data)
+	if err != nil {
+		return false, fmt.Errorf("failed upgrading object (%w)", err)
+	}
+
+	var upgraded bool
+	err = s.write(ctx, model, func(coll *collection) error {
+		old, have := coll.docs[id]
+		if !have || old.version != version {
+			return nil
+		}
+
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		doc.id = old.id
+		doc.uid = old.uid
+		doc.created = old.created
+		doc.updated = &now
+		doc.version = old.version
+		doc.deleted = old.deleted
+		doc.schemaVersion = stores.SchemaVersion(model)
+
+		if err := coll.put(doc); err != nil {
+			return err
+		}
+		upgraded = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed upgrading object (%w)", err)
+	}
+
+	return upgraded, nil
+}
+
+// UpsertObject inserts obj, or updates the object which has the same
+// values for keyFields, and returns the resulting metadata and whether obj
+// was inserted. The keyFields identify a unique index of the model (see
+// stores.UniqueIndex), or the UID when empty. Updated objects are restored
+// when they were soft deleted.
+func (s *Store) UpsertObject(ctx context.Context, obj kolektor.Modeler, keyFields []string) (*kolektor.Meta, bool, error) {
+	idx, err := stores.UniqueIndex(obj, kolektor.Memory, keyFields)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	var meta *kolektor.Meta
+	var inserted bool
+
+	err = s.write(ctx, obj, func(coll *collection) error {
+		var err error
+		meta, inserted, err = upsertObject(coll, obj, idx)
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	return meta, inserted, nil
+}
+
+// upsertObject inserts obj into coll, or replaces the document which has
+// the same values for idx, or the same UID when idx is nil.
+func upsertObject(coll *collection, obj kolektor.Modeler, idx *kolektor.Index) (*kolektor.Meta, bool, error) {
+	objUID := obj.GetUID()
+	if objUID == "" {
+		var err error
+		if objUID, err = stores.NewUID(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	data, err := stores.MarshalObject(obj)
+	if err != nil {
+		return nil, false, err
+	}
+
+	doc, err := newDocument(data)
+	if err != nil {
+		return nil, false, err
+	}
+	doc.uid = objUID
+	doc.schemaVersion = stores.SchemaVersion(obj)
+
+	var old *document
+	if idx == nil {
+		if id, have := coll.uids[objUID]; have {
+			old = coll.docs[id]
+		}
+	} else if key, ok := indexKey(doc, *idx); ok {
+		if id, have := coll.unique[idx.Name][key]; have {
+			old = coll.docs[id]
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	if old == nil {
+		doc.id = coll.lastID + 1
+		doc.created = now
+		doc.version = 1
+	} else {
+		doc.id = old.id
+		doc.uid = old.uid
+		doc.created = old.created
+		doc.updated = &now
+		doc.version = old.version + 1
+	}
+
+	if err := coll.put(doc); err != nil {
+		return nil, false, err
+	}
+
+	if old == nil {
+		coll.lastID = doc.id
+	}
+
+	return doc.meta(), old == nil, nil
+}
+
+// PatchObject applies patch to the document of the object matching filter.
+// When filter matches multiple objects, the one with the lowest ID is
+// patched. Error stores.ErrPatch is returned when the patch could not be
+// applied.
+func (s *Store) PatchObject(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter,
+	patch kolektor.Patch) (*kolektor.Meta, error) {
+
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	if err := stores.CheckPatch(patch); err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", err)
+	}
+
+	var meta *kolektor.Meta
+	err := s.write(ctx, model, func(coll *collection) error {
+		docs, err := filterDocuments(coll, filter)
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return stores.ErrNoObject{Name: coll.name}
+		}
+
+		data, err := stores.ApplyPatch(docs[0].data, patch)
+		if err != nil {
+			return stores.ErrPatch{Name: coll.name, Err: err}
+		}
+
+		doc, err := newDocument(data)
+		if err != nil {
+			return err
+		}
+
+		// documents are replaced, never modified
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		old := docs[0]
+		doc.id = old.id
+		doc.uid = old.uid
+		doc.created = old.created
+		doc.updated = &now
+		doc.version = old.version + 1
+		doc.deleted = old.deleted
+		doc.schemaVersion = old.schemaVersion
+
+		if err := coll.put(doc); err != nil {
+			return err
+		}
+		meta = doc.meta()
+		return nil
+	})
+	if err != nil {
+		switch err.(type) {
+		case stores.ErrNoObject, stores.ErrPatch:
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed patching object (%w)", err)
+	}
+
+	return meta, nil
+}
+
+// storeObject inserts obj into coll, or replaces the stored document when
+// obj has an ID.
+func storeObject(coll *collection, obj kolektor.Modeler) (*kolektor.Meta, error) {
+	objID := obj.GetID()
+	objUID := obj.GetUID()
+	objVersion := obj.GetVersion()
+
+	if objUID == "" {
+		var err error
+		if objUID, err = stores.NewUID(); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := stores.MarshalObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := newDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	doc.uid = objUID
+	doc.schemaVersion = stores.SchemaVersion(obj)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	if objID == 0 {
+		doc.id = coll.lastID + 1
+		doc.created = now
+		doc.version = 1
+	} else {
+		old, have := coll.docs[objID]
+		if !have {
+			return nil, stores.ErrNoObject{Name: coll.name}
+		}
+		if objVersion > 0 && objVersion != old.version {
+			return nil, stores.ErrConflict{Name: coll.name, ID: objID, Version: objVersion}
+		}
+		doc.id = objID
+		doc.created = old.created
+		doc.updated = &now
+		doc.version = old.version + 1
+		doc.deleted = old.deleted
+	}
+
+	if err := coll.put(doc); err != nil {
+		return nil, err
+	}
+
+	if objID == 0 {
+		coll.lastID = doc.id
+	}
+
+	return doc.meta(), nil
+}
+
+// DeleteObjects removes the objects of the model's collection matching
+// filter and returns the number of removed objects.
+func (s *Store) DeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	var n int64
+	err := s.write(ctx, model, func(coll *collection) error {
+		docs, err := filterDocuments(coll, filter)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			coll.remove(doc)
+		}
+		n = int64(len(docs))
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", err)
+	}
+
+	return n, nil
+}
+
+// SoftDeleteObjects sets the deleted timestamp of the objects of the
+// model's collection matching filter which were not yet soft deleted,
+// and returns the number of soft deleted objects.
+func (s *Store) SoftDeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	return s.setDeleted(ctx, model, filter, true)
+}
+
+// RestoreObjects clears the deleted timestamp of the soft deleted objects
+// of the model's collection matching filter, and returns the number of
+// restored objects.
+func (s *Store) RestoreObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	return s.setDeleted(ctx, model, filter, false)
+}
+
+func (s *Store) setDeleted(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter, deleted bool) (int64, error) {
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	op := kolektor.OpIsNull
+	if !deleted {
+		op = kolektor.OpIsNotNull
+	}
+	filter = append(filter[:len(filter):len(filter)], kolektor.Condition{Field: "deleted", Operator: op})
+
+	var n int64
+	err := s.write(ctx, model, func(coll *collection) error {
+		docs, err := filterDocuments(coll, filter)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		for _, doc := range docs {
+			// documents are replaced, never modified
+			d := *doc
+			d.updated = &now
+			d.version++
+			d.deleted = nil
+			if deleted {
+				d.deleted = &now
+			}
+			if err := coll.put(&d); err != nil {
+				return err
+			}
+		}
+		n = int64(len(docs))
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", err)
+	}
+
+	return n, nil
+}
+
+// InitCollection initializes the model's collection. Indexes are defined
+// using paths within the JSON documents as expression, for example,
+// "isbn13" or "address.city". Only unique indexes have an effect.
+func (s *Store) InitCollection(ctx context.Context, model kolektor.Modeler) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", err)
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	name := model.CollectionName()
+	coll, have := s.db.collections[name]
+	if !have {
+		coll = newCollection(name)
+	}
+
+	indexes, err := stores.ModelIndexes(model, kolektor.Memory)
+	if err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", err)
+	}
+
+	// indexes are set on a copy so the collection is unchanged on error
+	work := coll.clone()
+	changed, err := reconcileIndexes(ctx, work, indexes)
+	if err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", err)
+	}
+	s.db.collections[name] = work
+	if !have || changed > 0 {
+		s.db.revs[name]++
+	}
+
+	return nil
+}
+
+// reconcileIndexes replaces the indexes of coll with indexes, and returns the
+// number of indexes created or dropped. This is traced as "ReconcileIndexes"
+// when ctx carries a kolektor.Tracer.
+func reconcileIndexes(ctx context.Context, coll *collection, indexes []kolektor.Index) (_ int64, err error) {
+	_, span := kolektor.StartSpan(ctx, kolektor.Operation{
+		Name:       "ReconcileIndexes",
+		Collection: coll.name,
+		Store:      kolektor.Memory.String(),
+	})
+	defer func() { span.End(err) }()
+
+	changed := indexChanges(coll.indexes, indexes)
+	if err := coll.setIndexes(indexes); err != nil {
+		return 0, err
+	}
+
+	span.SetRows(changed)
+	return changed, nil
+}
+
+// RemoveCollection removes the model's collection.
+func (s *Store) RemoveCollection(ctx context.Context, model kolektor.Modeler) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed removing collection (%w)", err)
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.db.readOnly {
+		return fmt.Errorf("failed removing collection (transaction is read-only)")
+	}
+
+	delete(s.db.collections, model.CollectionName())
+	s.db.revs[model.CollectionName()]++
+	return nil
+}
+
+// ListCollections returns the names of all collections sorted by name.
+func (s *Store) ListCollections(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed listing collections (%w)", err)
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	names := make([]string, 0, len(s.db.collections))
+	for name := range s.db.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// ListIndexes returns the indexes of the model's collection, starting with
+// the index on the UID. The definition of each index is the list of paths
+// within the JSON documents. No indexes are returned when the collection
+// does not exist.
+func (s *Store) ListIndexes(ctx context.Context, model kolektor.Modeler) ([]kolektor.IndexStatus, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed listing indexes (%w)", err)
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	name := model.CollectionName()
+	coll, have := s.db.collections[name]
+	if !have {
+		return nil, nil
+	}
+
+	indexes := []kolektor.IndexStatus{{
+		Name:       "uq_" + name + "_uid",
+		Unique:     true,
+		Definition: "(uid)",
+		Valid:      true,
+	}}
+
+	for _, idx := range coll.indexes {
+		paths, err := indexPaths(idx)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing indexes (%w)", err)
+		}
+		indexes = append(indexes, kolektor.IndexStatus{
+			Name:       idx.Name,
+			Unique:     idx.Unique,
+			Definition: "(" + strings.Join(paths, ", ") + ")",
+			Managed:    true,
+			Valid:      true,
+		})
+	}
+
+	return indexes, nil
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/geertjanvdk/xkit/xt"
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+type Book struct {
+	kolektor.Model
+	ISBN13  string   `json:"isbn13"`
+	Title   string   `json:"title"`
+	Authors []string `json:"authors,omitempty"`
+}
+
+var _ kolektor.Modeler = &Book{}
+
+func (b Book) CollectionName() string {
+	return "books"
+}
+
+func (b Book) Indexes(kind kolektor.StoreKind) []kolektor.Index {
+	if kind != kolektor.Memory {
+		return nil
+	}
+
+	return []kolektor.Index{
+		{
+			Name:       "uq_books_isbn13",
+			Unique:     true,
+			Expression: "isbn13",
+		},
+	}
+}
+
+func newTestStore(t *testing.T) *Store {
+	s, err := New("")
+	xt.OK(t, err)
+	store := s.(*Store)
+	xt.OK(t, store.InitCollection(context.Background(), &Book{}))
+	return store
+}
+
+func TestNew(t *testing.T) {
+	t.Run("same DSN shares data", func(t *testing.T) {
+		dsn := "test_new_shared"
+		defer Drop(dsn)
+
+		s1, err := New(dsn)
+		xt.OK(t, err)
+		xt.OK(t, s1.InitCollection(context.Background(), &Book{}))
+		_, err = s1.StoreObject(context.Background(), &Book{ISBN13: "978-0201633610"})
+		xt.OK(t, err)
+
+		s2, err := New(dsn)
+		xt.OK(t, err)
+		n, err := s2.CountObjects(context.Background(), &Book{}, nil)
+		xt.OK(t, err)
+		xt.Eq(t, 1, n)
+	})
+
+	t.Run("empty DSN does not share data", func(t *testing.T) {
+		s1 := newTestStore(t)
+		_, err := s1.StoreObject(context.Background(), &Book{ISBN13: "978-0201633610"})
+		xt.OK(t, err)
+
+		s2 := newTestStore(t)
+		n, err := s2.CountObjects(context.Background(), &Book{}, nil)
+		xt.OK(t, err)
+		xt.Eq(t, 0, n)
+	})
+}
+
+func TestStore_StoreObject(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	t.Run("metadata is maintained", func(t *testing.T) {
+		book := &Book{ISBN13: "978-0132350884", Title: "Clean Code"}
+		meta, err := store.StoreObject(ctx, book)
+		xt.OK(t, err)
+		xt.Eq(t, 1, meta.ID)
+		xt.Match(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, meta.UID)
+		xt.Assert(t, !meta.Created.IsZero(), "expected created")
+		xt.Assert(t, meta.Updated == nil, "expected updated to be nil")
+
+		book.SetMeta(meta)
+		book.Title = "Clean Code: A Handbook of Agile Software Craftsmanship"
+		updated, err := store.StoreObject(ctx, book)
+		xt.OK(t, err)
+		xt.Eq(t, meta.ID, updated.ID)
+		xt.Eq(t, meta.UID, updated.UID)
+		xt.Eq(t, meta.Created, updated.Created)
+		xt.Assert(t, updated.Updated != nil, "expected updated")
+
+		got := &Book{}
+		xt.OK(t, store.GetObject(ctx, got, kolektor.FieldMap{"uid": meta.UID}))
+		xt.Eq(t, book.Title, got.Title)
+		xt.Eq(t, meta.ID, got.Meta.ID)
+	})
+
+	t.Run("unique index is enforced", func(t *testing.T) {
+		_, err := store.StoreObject(ctx, &Book{ISBN13: "978-0132350884"})
+		xt.KO(t, err)
+		xt.Assert(t, regexp.MustCompile(`uq_books_isbn13`).MatchString(err.Error()))
+
+		// not all objects are stored when one violates the index
+		_, err = store.StoreObjects(ctx, &Book{}, []kolektor.Modeler{
+			&Book{ISBN13: "978-1593279288"},
+			&Book{ISBN13: "978-0132350884"},
+		})
+		xt.KO(t, err)
+		exists, err := store.ObjectsExist(ctx, &Book{}, kolektor.Filter{
+			{Field: "isbn13", Operator: kolektor.OpEqual, Value: "978-1593279288"},
+		})
+		xt.OK(t, err)
+		xt.Assert(t, !exists, "expected no object to be stored")
+	})
+
+	t.Run("unique value is available after delete", func(t *testing.T) {
+		n, err := store.DeleteObjects(ctx, &Book{}, kolektor.Filter{
+			{Field: "isbn13", Operator: kolektor.OpEqual, Value: "978-0132350884"},
+		})
+		xt.OK(t, err)
+		xt.Eq(t, 1, n)
+
+		_, err = store.StoreObject(ctx, &Book{ISBN13: "978-0132350884"})
+		xt.OK(t, err)
+	})
+
+	t.Run("collection must exist", func(t *testing.T) {
+		xt.OK(t, store.RemoveCollection(ctx, &Book{}))
+		_, err := store.StoreObject(ctx, &Book{ISBN13: "978-0132350884"})
+		xt.KO(t, err)
+		err = store.GetObject(ctx, &Book{}, kolektor.FieldMap{"id": 1})
+		xt.KO(t, err)
+		xt.Assert(t, !errors.As(err, &stores.ErrNoObject{}), "expected other error than ErrNoObject")
+	})
+}
+
+func TestStore_concurrent(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				book := &Book{ISBN13: fmt.Sprintf("isbn-%d-%d", i, j), Title: "Concurrency in Go"}
+				if _, err := store.StoreObject(ctx, book); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := store.CountObjects(ctx, &Book{}, nil); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		xt.OK(t, err)
+	}
+
+	n, err := store.CountObjects(ctx, &Book{}, nil)
+	xt.OK(t, err)
+	xt.Eq(t, 500, n)
+}
+
+func TestStore_BeginTx(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	t.Run("rollback discards changes", func(t *testing.T) {
+		tx, err := store.BeginTx(ctx, nil)
+		xt.OK(t, err)
+		_, err = tx.StoreObject(ctx, &Book{ISBN13: "978-0262033848"})
+		xt.OK(t, err)
+		xt.OK(t, tx.Rollback(ctx))
+		xt.KO(t, tx.Rollback(ctx))
+
+		n, err := store.CountObjects(ctx, &Book{}, nil)
+		xt.OK(t, err)
+		xt.Eq(t, 0, n)
+	})
+
+	t.Run("commit keeps changes", func(t *testing.T) {
+		tx, err := store.BeginTx(ctx, nil)
+		xt.OK(t, err)
+		_, err = tx.StoreObject(ctx, &Book{ISBN13: "978-0262033848"})
+		xt.OK(t, err)
+		xt.OK(t, tx.Commit(ctx))
+
+		n, err := store.CountObjects(ctx, &Book{}, nil)
+		xt.OK(t, err)
+		xt.Eq(t, 1, n)
+	})
+
+	t.Run("store usable while transaction is active", func(t *testing.T) {
+		tx, err := store.BeginTx(ctx, nil)
+		xt.OK(t, err)
+		_, err = tx.StoreObject(ctx, &Book{ISBN13: "978-0131103627"})
+		xt.OK(t, err)
+
+		// would deadlock if the transaction locked the store
+		n, err := store.CountObjects(ctx, &Book{}, nil)
+		xt.OK(t, err)
+		xt.Eq(t, 1, n)
+
+		xt.OK(t, tx.Commit(ctx))
+
+		n, err = store.CountObjects(ctx, &Book{}, nil)
+		xt.OK(t, err)
+		xt.Eq(t, 2, n)
+	})
+
+	t.Run("commit fails when collection was changed", func(t *testing.T) {
+		tx, err := store.BeginTx(ctx, nil)
+		xt.OK(t, err)
+		_, err = tx.StoreObject(ctx, &Book{ISBN13: "978-0201835953"})
+		xt.OK(t, err)
+
+		_, err = store.StoreObject(ctx, &Book{ISBN13: "978-0596007126"})
+		xt.OK(t, err)
+
+		err = tx.Commit(ctx)
+		xt.Assert(t, errors.Is(err, kolektor.ErrConflict), "expected kolektor.ErrConflict")
+
+		n, err := store.CountObjects(ctx, &Book{}, nil)
+		xt.OK(t, err)
+		xt.Eq(t, 3, n)
+	})
+}
+
+type taggedBook struct {
+	kolektor.Model
+	ISBN13 string `json:"isbn13" kolekto:"index,unique"`
+}
+
+func (b taggedBook) CollectionName() string {
+	return "tagged_books"
+}
+
+func TestStore_InitCollection_tagged(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	xt.OK(t, store.InitCollection(ctx, &taggedBook{}))
+
+	_, err := store.StoreObject(ctx, &taggedBook{ISBN13: "978-1491903995"})
+	xt.OK(t, err)
+	_, err = store.StoreObject(ctx, &taggedBook{ISBN13: "978-1491903995"})
+	xt.KO(t, err)
+	xt.Match(t, `uq_tagged_books_isbn13`, err.Error())
+}
+
+type portableBook struct {
+	kolektor.Model
+	Publisher string `json:"publisher"`
+	Year      int    `json:"year"`
+}
+
+func (b portableBook) CollectionName() string {
+	return "portable_books"
+}
+
+func (b portableBook) Indexes(kolektor.StoreKind) []kolektor.Index {
+	return []kolektor.Index{
+		{
+			Name:   "uq_portable_books_publisher_year",
+			Unique: true,
+			Fields: []kolektor.IndexField{{Path: "publisher"}, {Path: "year", Type: kolektor.IndexInt}},
+		},
+	}
+}
+
+func TestStore_InitCollection_portable(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	xt.OK(t, store.InitCollection(ctx, &portableBook{}))
+
+	_, err := store.StoreObject(ctx, &portableBook{Publisher: "O'Reilly", Year: 2015})
+	xt.OK(t, err)
+	_, err = store.StoreObject(ctx, &portableBook{Publisher: "O'Reilly", Year: 2016})
+	xt.OK(t, err)
+	_, err = store.StoreObject(ctx, &portableBook{Publisher: "O'Reilly", Year: 2015})
+	xt.KO(t, err)
+	xt.Match(t, `uq_portable_books_publisher_year`, err.Error())
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmemory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// txStore is a Store bound to a transaction. It works on a copy of the
+// data, of which the changed collections replace those of the store when
+// committing.
+type txStore struct {
+	*Store
+	parent *database
+	// revs are the revisions of the collections when the transaction started.
+	revs map[string]int64
+	done bool
+}
+
+var _ kolektor.TxStorer = &txStore{}
+
+// BeginTx starts a transaction and returns a store which executes all
+// operations within it. The transaction works on a snapshot of the data,
+// so that the store can still be used, also outside the transaction.
+// Committing fails with kolektor.ErrConflict when a collection changed by
+// the transaction was changed by others since the transaction started.
+func (s *Store) BeginTx(ctx context.Context, opts *sql.TxOptions) (kolektor.TxStorer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed starting transaction (%w)", err)
+	}
+
+	s.db.mu.RLock()
+	db := s.db.clone()
+	s.db.mu.RUnlock()
+
+	db.readOnly = opts != nil && opts.ReadOnly
+	revs := make(map[string]int64, len(db.revs))
+	for name, rev := range db.revs {
+		revs[name] = rev
+	}
+
+	return &txStore{
+		Store:  &Store{db: db},
+		parent: s.db,
+		revs:   revs,
+	}, nil
+}
+
+// BeginTx returns an error since nested transactions are not supported.
+func (s *txStore) BeginTx(context.Context, *sql.TxOptions) (kolektor.TxStorer, error) {
+	return nil, fmt.Errorf("nested transactions are not supported")
+}
+
+// Commit commits the transaction, replacing the collections of the store
+// which were changed within the transaction.
+func (s *txStore) Commit(context.Context) error {
+	if s.done {
+		return fmt.Errorf("failed committing transaction (transaction already finished)")
+	}
+	s.done = true
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	// the collections become those of the store
+	s.db.readOnly = true
+
+	s.parent.mu.Lock()
+	defer s.parent.mu.Unlock()
+
+	var changed []string
+	for name, rev := range s.db.revs {
+		if rev == s.revs[name] {
+			continue
+		}
+		if s.parent.revs[name] != s.revs[name] {
+			return fmt.Errorf("failed committing transaction (%w)", &kolektor.StoreError{
+				Kind: kolektor.ErrConflict,
+				Err:  fmt.Errorf("collection %s was changed", name),
+			})
+		}
+		changed = append(changed, name)
+	}
+
+	for _, name := range changed {
+		if coll, have := s.db.collections[name]; have {
+			s.parent.collections[name] = coll
+		} else {
+			delete(s.parent.collections, name)
+		}
+		s.parent.revs[name]++
+	}
+
+	return nil
+}
+
+// Rollback rolls back the transaction.
+func (s *txStore) Rollback(context.Context) error {
+	if s.done {
+		return fmt.Errorf("failed rolling back transaction (transaction already finished)")
+	}
+	s.done = true
+
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nomysql
+
+package dbmysql
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// errorKinds maps MySQL error numbers to the errors classified by kolektor.
+var errorKinds = map[uint16]error{
+	1062: kolektor.ErrDuplicateKey,          // ER_DUP_ENTRY
+	1586: kolektor.ErrDuplicateKey,          // ER_DUP_ENTRY_WITH_KEY_NAME
+	1213: kolektor.ErrConflict,              // ER_LOCK_DEADLOCK
+	1205: kolektor.ErrTimeout,               // ER_LOCK_WAIT_TIMEOUT
+	3024: kolektor.ErrTimeout,               // ER_QUERY_TIMEOUT
+	1146: kolektor.ErrNotFound,              // ER_NO_SUCH_TABLE
+	1103: kolektor.ErrInvalidCollectionName, // ER_WRONG_TABLE_NAME
+	1059: kolektor.ErrInvalidCollectionName, // ER_TOO_LONG_IDENT
+	1040: kolektor.ErrStoreUnavailable,      // ER_CON_COUNT_ERROR
+	1053: kolektor.ErrStoreUnavailable,      // ER_SERVER_SHUTDOWN
+	1927: kolektor.ErrStoreUnavailable,      // ER_CONNECTION_KILLED (MariaDB)
+	3169: kolektor.ErrStoreUnavailable,      // ER_SESSION_WAS_KILLED
+}
+
+// reDuplicateKey extracts the name of the index from the message of
+// ER_DUP_ENTRY, which MySQL 8.0 prefixes with the name of the table.
+var reDuplicateKey = regexp.MustCompile(`for key '([^']+)'`)
+
+// translateError returns err as *kolektor.StoreError when the MySQL error
+// number, or the error itself, is classified by kolektor. Otherwise, err is
+// returned as is, which is also the case when err was already classified.
+func translateError(err error) error {
+	var classified *kolektor.StoreError
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+
+	if errors.Is(err, mysql.ErrInvalidConn) {
+		return &kolektor.StoreError{Kind: kolektor.ErrStoreUnavailable, Err: err}
+	}
+
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return stores.ClassifyError(err)
+	}
+
+	kind, ok := errorKinds[myErr.Number]
+	if !ok {
+		return err
+	}
+
+	storeErr := &kolektor.StoreError{Kind: kind, Code: strconv.Itoa(int(myErr.Number)), Err: err}
+	if kind == kolektor.ErrDuplicateKey {
+		if m := reDuplicateKey.FindStringSubmatch(myErr.Message); m != nil {
+			storeErr.Index = m[1][strings.LastIndex(m[1], ".")+1:]
+		}
+	}
+
+	return storeErr
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nomysql
+
+package dbmysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// ImportObject inserts the JSON document data into the model's collection
+// using meta as metadata. The ID and UID are assigned when they are not
+// set, as is the creation time, while the version and schema version are at
+// least 1. When an object with the same UID exists, onConflict defines
+// whether an error is returned, the document is skipped, or the object is
+// overwritten. It returns whether the document was stored.
+// Note that MySQL also skips or overwrites the object having the same ID.
+func (s *Store) ImportObject(ctx context.Context, model kolektor.Modeler, meta kolektor.Meta, data []byte,
+	onConflict kolektor.ImportConflict) (bool, error) {
+
+	q := fmt.Sprintf("INSERT INTO %s (id, uid, created, updated, version, deleted, schema_version, data) "+
+		"VALUES (NULLIF(?, 0), ?, COALESCE(?, CURRENT_TIMESTAMP(6)), ?, ?, ?, ?, ?) AS new",
+		model.CollectionName())
+
+	switch onConflict {
+	case kolektor.ImportSkip:
+		q += " ON DUPLICATE KEY UPDATE id = " + model.CollectionName() + ".id"
+	case kolektor.ImportOverwrite:
+		q += " ON DUPLICATE KEY UPDATE created = new.created, updated = new.updated, version = new.version, " +
+			"deleted = new.deleted, schema_version = new.schema_version, data = new.data"
+	}
+
+	var created any
+	if !meta.Created.IsZero() {
+		created = meta.Created
+	}
+
+	res, err := s.db.ExecContext(ctx, q, meta.ID, meta.UID, created, meta.Updated,
+		max(meta.Version, 1), meta.Deleted, max(meta.SchemaVersion, 1), data)
+	if err != nil {
+		return false, storeError(model, "failed importing object", err)
+	}
+
+	// no rows are affected when skipping, or overwriting with the same values
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed importing object (%w)", translateError(err))
+	}
+
+	return n > 0 || onConflict == kolektor.ImportOverwrite, nil
+}
@@ -5,12 +5,12 @@ package dbmysql
 import (
 	"context"
 	"crypto/md5"
-	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"strings"
 
 	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
 	"github.com/golistic/xstrings"
 )
 
@@ -19,23 +19,66 @@ func md5sum[T string | []byte](value T) string {
 	return hex.EncodeToString(sum[:])
 }
 
-func addIndexes(conn *sql.Conn, idxer kolektor.Indexer, tableName string) error {
+// defaultIndexLength is the length of indexed strings when the index
+// field does not set it.
+const defaultIndexLength = 255
+
+// indexFieldExpression returns the expression of a field of a store-agnostic
+// index. Values are cast since MySQL can not index JSON values.
+func indexFieldExpression(field kolektor.IndexField) string {
+	value := "data->>'$." + field.Path + "'"
+
+	switch field.Type {
+	case kolektor.IndexInt:
+		return "CAST(" + value + " AS SIGNED)"
+	case kolektor.IndexFloat:
+		return "CAST(" + value + " AS DOUBLE)"
+	case kolektor.IndexBool:
+		return "CAST(" + value + " AS CHAR(5))"
+	case kolektor.IndexTimestamp:
+		// RFC 3339 with nanoseconds and time zone offset
+		return "CAST(" + value + " AS CHAR(35))"
+	default:
+		length := field.Length
+		if length == 0 {
+			length = defaultIndexLength
+		}
+		return fmt.Sprintf("CAST(%s AS CHAR(%d))", value, length)
+	}
+}
+
+// addIndexes creates, recreates, or drops the indexes of the table so that
+// they match indexes. This is traced as "ReconcileIndexes" when ctx carries
+// a kolektor.Tracer.
+func addIndexes(ctx context.Context, conn stores.SQLQuerier, indexes []kolektor.Index, tableName string) (err error) {
+	ctx, span := kolektor.StartSpan(ctx, kolektor.Operation{
+		Name:       "ReconcileIndexes",
+		Collection: tableName,
+		Store:      kolektor.MySQL.String(),
+	})
+	defer func() { span.End(err) }()
+
 	var alters []string
 
-	haveIndexes, err := getIndexes(conn, tableName)
+	haveIndexes, err := getIndexes(ctx, conn, tableName)
 	if err != nil {
 		return err
 	}
 
 	var wantIndexes []string
-	for _, idx := range idxer.Indexes(kolektor.MySQL) {
+	for _, idx := range indexes {
 		wantIndexes = append(wantIndexes, idx.Name)
 		unique := ""
 		if idx.Unique {
 			unique = "UNIQUE"
 		}
 
-		exprSum := md5sum(idx.Expression)
+		expr, err := stores.IndexExpression(idx, indexFieldExpression)
+		if err != nil {
+			return err
+		}
+
+		exprSum := md5sum(expr)
 		if haveHash, have := haveIndexes[idx.Name]; have {
 			if exprSum == haveHash {
 				// index did not change; skip
@@ -47,7 +90,7 @@ func addIndexes(conn *sql.Conn, idxer kolektor.Indexer, tableName string) error
 		}
 
 		alters = append(alters, fmt.Sprintf("ADD %s INDEX %s %s COMMENT 'kolekto#%s'",
-			unique, idx.Name, idx.Expression, exprSum))
+			unique, idx.Name, expr, exprSum))
 	}
 
 	for name := range haveIndexes {
@@ -58,22 +101,26 @@ func addIndexes(conn *sql.Conn, idxer kolektor.Indexer, tableName string) error
 
 	if len(alters) > 0 {
 		dml := "ALTER TABLE " + tableName + " " + strings.Join(alters, ", ")
-		if _, err := conn.ExecContext(context.Background(), dml); err != nil {
+		if _, err := conn.ExecContext(ctx, dml); err != nil {
 			return fmt.Errorf("failed creating indexes for %s (%w)", tableName, err)
 		}
 	}
+
+	// each alteration creates or drops one index
+	span.SetRows(int64(len(alters)))
 	return nil
 }
 
-func getIndexes(conn *sql.Conn, tableName string) (map[string]string, error) {
+func getIndexes(ctx context.Context, conn stores.SQLQuerier, tableName string) (map[string]string, error) {
 	q := "SELECT INDEX_NAME, INDEX_COMMENT FROM INFORMATION_SCHEMA.STATISTICS" +
 		" WHERE TABLE_SCHEMA = DATABASE() AND" +
 		" TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY' AND INDEX_COMMENT LIKE 'kolekto#%'"
 
-	rows, err := conn.QueryContext(context.Background(), q, tableName)
+	rows, err := conn.QueryContext(ctx, q, tableName)
 	if err != nil {
 		return nil, fmt.Errorf("failed getting indexes (%w)", err)
 	}
+	defer func() { _ = rows.Close() }()
 
 	indexes := map[string]string{}
 
@@ -90,5 +137,9 @@ func getIndexes(conn *sql.Conn, tableName string) (map[string]string, error) {
 		indexes[name] = parts[1]
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed getting indexes (%w)", err)
+	}
+
 	return indexes, nil
 }
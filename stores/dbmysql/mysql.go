@@ -6,38 +6,137 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/golistic/kolekto/stores"
 )
 
-const dmlReturningMeta = "id, uid, created, updated"
+const dmlReturningMeta = "id, uid, created, updated, version, deleted, schema_version"
 
 const mysqlMetaAsJson = "JSON_OBJECT('Meta', JSON_OBJECT(" +
 	"'id', id, " +
 	"'uid', uid, " +
-	"'created', DATE_FORMAT('%Y-%m-%dT%H:%m:%i.%fZ', created), " +
-	"'updated', DATE_FORMAT('%Y-%m-%dT%H:%m:%i.%fZ', updated)))"
+	"'created', DATE_FORMAT(created, '%Y-%m-%dT%H:%i:%s.%fZ'), " +
+	"'updated', DATE_FORMAT(updated, '%Y-%m-%dT%H:%i:%s.%fZ'), " +
+	"'version', version, " +
+	"'deleted', DATE_FORMAT(deleted, '%Y-%m-%dT%H:%i:%s.%fZ'), " +
+	"'schema_version', schema_version))"
 
 const mysqlMergeDataMeta = "JSON_MERGE(data, " + mysqlMetaAsJson + ")"
 
+// errCheckConstraintViolated is the MySQL error number returned when a row
+// violates a CHECK constraint (ER_CHECK_CONSTRAINT_VIOLATED).
+const errCheckConstraintViolated = 3819
+
 func ddlTable(name string) string {
 	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
 id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
 uid VARCHAR(%d) NOT NULL,
 created TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6),
 updated TIMESTAMP(6) NULL ON UPDATE CURRENT_TIMESTAMP(6),
+version BIGINT NOT NULL DEFAULT 1,
+deleted TIMESTAMP(6) NULL DEFAULT NULL,
+schema_version INT NOT NULL DEFAULT 1,
 data JSON
 )`, name, stores.SizeUID)
 }
 
-func mysqlRoutineVersion(db *sql.Conn, routine string) (int, error) {
+// addedColumns are the columns added after tables of collections were
+// first created. They are added to existing tables by migrateTable.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{name: "version", definition: "BIGINT NOT NULL DEFAULT 1 AFTER updated"},
+	{name: "deleted", definition: "TIMESTAMP(6) NULL DEFAULT NULL AFTER version"},
+	{name: "schema_version", definition: "INT NOT NULL DEFAULT 1 AFTER deleted"},
+}
+
+// migrateTable adds the columns which are missing from the table of a
+// collection created by a previous version.
+func migrateTable(ctx context.Context, conn stores.SQLQuerier, tableName string) error {
+	q := "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?"
+
+	rows, err := conn.QueryContext(ctx, q, tableName)
+	if err != nil {
+		return fmt.Errorf("failed migrating %s (%w)", tableName, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed migrating %s (%w)", tableName, err)
+		}
+		have[strings.ToLower(name)] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed migrating %s (%w)", tableName, err)
+	}
+
+	var alters []string
+	for _, c := range addedColumns {
+		if !have[c.name] {
+			alters = append(alters, fmt.Sprintf("ADD COLUMN %s %s", c.name, c.definition))
+		}
+	}
+
+	if len(alters) > 0 {
+		ddl := "ALTER TABLE " + tableName + " " + strings.Join(alters, ", ")
+		if _, err := conn.ExecContext(ctx, ddl); err != nil {
+			return fmt.Errorf("failed migrating %s (%w)", tableName, err)
+		}
+	}
+
+	return nil
+}
+
+// metaIndexes are the indexes on the metadata columns of the tables of
+// collections. The unique index on the uid column is also used by
+// UpsertObject, while the index on created and id is used when paging by
+// creation time. The name of each is formatted using the name of the table.
+var metaIndexes = []struct {
+	name       string
+	definition string
+}{
+	{name: "uq_%s_uid", definition: "UNIQUE INDEX %s (uid)"},
+	{name: "ix_%s_created", definition: "INDEX %s (created, id)"},
+}
+
+// addMetaIndexes adds the metaIndexes which the table does not have.
+func addMetaIndexes(ctx context.Context, conn stores.SQLQuerier, tableName string) error {
+	q := "SELECT COUNT(*) FROM INFORMATION_SCHEMA.STATISTICS " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?"
+
+	for _, idx := range metaIndexes {
+		name := fmt.Sprintf(idx.name, tableName)
+
+		var n int
+		if err := conn.QueryRowContext(ctx, q, tableName, name).Scan(&n); err != nil {
+			return fmt.Errorf("failed adding index %s to %s (%w)", name, tableName, err)
+		}
+
+		if n == 0 {
+			ddl := fmt.Sprintf("ALTER TABLE %s ADD "+idx.definition, tableName, name)
+			if _, err := conn.ExecContext(ctx, ddl); err != nil {
+				return fmt.Errorf("failed adding index %s to %s (%w)", name, tableName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func mysqlRoutineVersion(ctx context.Context, db *sql.Conn, routine string) (int, error) {
 	q := "SELECT JSON_EXTRACT(ROUTINE_COMMENT, '$.version') " +
 		"FROM information_schema.ROUTINES " +
 		"WHERE ROUTINE_SCHEMA = DATABASE() AND ROUTINE_NAME = ?"
 
 	var version int
 
-	if err := db.QueryRowContext(context.Background(), q, routine).Scan(&version); err != nil {
+	if err := db.QueryRowContext(ctx, q, routine).Scan(&version); err != nil {
 		return 0, err
 	}
 
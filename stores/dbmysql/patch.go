@@ -0,0 +1,222 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nomysql
+
+package dbmysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// sqlExpr is an SQL expression together with the values of its
+// placeholders.
+type sqlExpr struct {
+	sql    string
+	values []any
+}
+
+func param(v any) sqlExpr {
+	return sqlExpr{sql: "?", values: []any{v}}
+}
+
+// call returns the expression calling the SQL function name with args.
+func call(name string, args ...sqlExpr) sqlExpr {
+	e := sqlExpr{sql: name + "("}
+	for i, a := range args {
+		if i > 0 {
+			e.sql += ", "
+		}
+		e.sql += a.sql
+		e.values = append(e.values, a.values...)
+	}
+	e.sql += ")"
+	return e
+}
+
+// PatchObject applies patch to the document of the object matching filter
+// using one UPDATE statement. When filter matches multiple objects, the one
+// with the lowest ID is patched. Merge patches are applied using
+// JSON_MERGE_PATCH, and JSON patches using JSON_SET, JSON_ARRAY_INSERT,
+// JSON_REMOVE, and related functions. Error stores.ErrPatch is returned when
+// a test operation failed, or a path is not available.
+func (s *Store) PatchObject(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter,
+	patch kolektor.Patch) (*kolektor.Meta, error) {
+
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	if err := stores.CheckPatch(patch); err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", err)
+	}
+
+	var set sqlExpr
+	var conds []sqlExpr
+	switch p := patch.(type) {
+	case kolektor.MergePatch:
+		set = call("JSON_MERGE_PATCH", sqlExpr{sql: "data"}, jsonParam(p))
+	case kolektor.JSONPatch:
+		var err error
+		if set, conds, err = jsonPatchExpression(p); err != nil {
+			return nil, fmt.Errorf("failed patching object (%w)", err)
+		}
+	}
+
+	where, values, err := whereClause(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", err)
+	}
+
+	// MySQL cannot select from the table being updated within a subquery,
+	// unless the subquery is materialized as derived table; LAST_INSERT_ID
+	// makes the ID of the patched object available
+	q := fmt.Sprintf("UPDATE %[1]s SET data = %[2]s, version = version + 1, id = LAST_INSERT_ID(id) "+
+		"WHERE id = (SELECT id FROM (SELECT id FROM %[1]s%[3]s ORDER BY id LIMIT 1) AS t)",
+		model.CollectionName(), set.sql, where)
+	values = append(set.values, values...)
+	for _, cond := range conds {
+		q += " AND " + cond.sql
+		values = append(values, cond.values...)
+	}
+
+	res, err := s.db.ExecContext(ctx, q, values...)
+	if err != nil {
+		return nil, storeError(model, "failed patching object", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", translateError(err))
+	}
+	if n == 0 {
+		if len(conds) > 0 {
+			exists, err := s.ObjectsExist(ctx, model, filter)
+			if err != nil {
+				return nil, fmt.Errorf("failed patching object (%w)", err)
+			}
+			if exists {
+				return nil, stores.ErrPatch{Name: model.CollectionName(), Err: stores.ErrPatchFailed}
+			}
+		}
+		return nil, stores.ErrNoObject{Name: model.CollectionName()}
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", translateError(err))
+	}
+
+	// second round-trip to fetch meta
+	meta := &kolektor.Meta{}
+	q = "SELECT " + dmlReturningMeta + " FROM " + model.CollectionName() + " WHERE id = ?"
+	row := s.db.QueryRowContext(ctx, q, id)
+	if err := row.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated, &meta.Version, &meta.Deleted, &meta.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", translateError(err))
+	}
+
+	return meta, nil
+}
+
+// jsonParam returns the placeholder for the JSON encoded value b.
+func jsonParam(b []byte) sqlExpr {
+	return sqlExpr{sql: "CAST(? AS JSON)", values: []any{string(b)}}
+}
+
+// jsonPatchExpression returns the expression applying the operations of
+// patch to the document, and the conditions which must be true for the
+// patch to be applied.
+func jsonPatchExpression(patch kolektor.JSONPatch) (sqlExpr, []sqlExpr, error) {
+	doc := sqlExpr{sql: "data"}
+	var conds []sqlExpr
+
+	exists := func(doc sqlExpr, path []string) sqlExpr {
+		return call("JSON_CONTAINS_PATH", doc, sqlExpr{sql: "'one'"}, param(pointerPath(path)))
+	}
+
+	for _, op := range patch {
+		path, err := stores.PointerTokens(op.Path)
+		if err != nil {
+			return sqlExpr{}, nil, err
+		}
+
+		var value sqlExpr
+		switch op.Op {
+		case kolektor.PatchAdd, kolektor.PatchReplace, kolektor.PatchTest:
+			b, err := json.Marshal(op.Value)
+			if err != nil {
+				return sqlExpr{}, nil, err
+			}
+			value = jsonParam(b)
+		case kolektor.PatchMove, kolektor.PatchCopy:
+			from, err := stores.PointerTokens(op.From)
+			if err != nil {
+				return sqlExpr{}, nil, err
+			}
+			conds = append(conds, exists(doc, from))
+			value = call("JSON_EXTRACT", doc, param(pointerPath(from)))
+			if op.Op == kolektor.PatchMove {
+				doc = call("JSON_REMOVE", doc, param(pointerPath(from)))
+			}
+		}
+
+		switch op.Op {
+		case kolektor.PatchAdd, kolektor.PatchMove, kolektor.PatchCopy:
+			if len(path) > 1 {
+				conds = append(conds, exists(doc, path[:len(path)-1]))
+			}
+			doc = addExpression(doc, path, value)
+		case kolektor.PatchRemove:
+			conds = append(conds, exists(doc, path))
+			doc = call("JSON_REMOVE", doc, param(pointerPath(path)))
+		case kolektor.PatchReplace:
+			conds = append(conds, exists(doc, path))
+			doc = call("JSON_REPLACE", doc, param(pointerPath(path)), value)
+		case kolektor.PatchTest:
+			extract := call("JSON_EXTRACT", doc, param(pointerPath(path)))
+			conds = append(conds, sqlExpr{
+				sql:    extract.sql + " = " + value.sql,
+				values: append(extract.values, value.values...),
+			})
+		}
+	}
+
+	return doc, conds, nil
+}
+
+// addExpression returns the expression adding value at path within doc.
+// Values are inserted into arrays when the last token of path is an array
+// index or "-", which appends.
+func addExpression(doc sqlExpr, path []string, value sqlExpr) sqlExpr {
+	last := path[len(path)-1]
+
+	switch {
+	case last == "-":
+		return call("JSON_ARRAY_APPEND", doc, param(pointerPath(path[:len(path)-1])), value)
+	case stores.IsArrayIndex(last):
+		return call("JSON_ARRAY_INSERT", doc, param(pointerPath(path)), value)
+	default:
+		return call("JSON_SET", doc, param(pointerPath(path)), value)
+	}
+}
+
+// pointerPath returns the MySQL path expression for the tokens of a JSON
+// Pointer. Tokens which are array indexes select array elements, others
+// are quoted member names.
+func pointerPath(tokens []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, t := range tokens {
+		if stores.IsArrayIndex(t) {
+			b.WriteString("[" + t + "]")
+			continue
+		}
+		b.WriteString(`."` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(t) + `"`)
+	}
+	return b.String()
+}
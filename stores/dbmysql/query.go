@@ -0,0 +1,173 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmysql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// fieldExpression returns the SQL expression for field as used in
+// conditions. Fields within the JSON document are extracted as text.
+func fieldExpression(field string) (string, bool, error) {
+	if stores.IsRawExpression(field) || stores.IsReservedField(field) {
+		return field, false, nil
+	}
+
+	if _, err := stores.FieldPath(field); err != nil {
+		return "", false, err
+	}
+
+	return "data->>'$." + field + "'", true, nil
+}
+
+// orderExpression returns the SQL expression for field as used when
+// sorting. Fields within the JSON document are extracted as JSON so that,
+// for example, numbers are sorted numerically.
+func orderExpression(field string) (string, error) {
+	expr, isJSON, err := fieldExpression(field)
+	if err != nil {
+		return "", err
+	}
+	if isJSON {
+		expr = "data->'$." + field + "'"
+	}
+	return expr, nil
+}
+
+// whereClause returns the WHERE-clause including its values for filter.
+// An empty string is returned when filter has no conditions.
+func whereClause(filter kolektor.Filter) (string, []any, error) {
+	if len(filter) == 0 {
+		return "", nil, nil
+	}
+
+	var ands []string
+	var values []any
+
+	for _, cond := range filter {
+		expr, isJSON, err := fieldExpression(cond.Field)
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch cond.Operator {
+		case kolektor.OpIsNull:
+			if isJSON {
+				// JSON null extracted as text is 'null' in MySQL
+				expr = "data->'$." + cond.Field + "'"
+				ands = append(ands, fmt.Sprintf("(%s IS NULL OR JSON_TYPE(%s) = 'NULL')", expr, expr))
+			} else {
+				ands = append(ands, expr+" IS NULL")
+			}
+		case kolektor.OpIsNotNull:
+			if isJSON {
+				expr = "data->'$." + cond.Field + "'"
+				ands = append(ands, fmt.Sprintf("(%s IS NOT NULL AND JSON_TYPE(%s) <> 'NULL')", expr, expr))
+			} else {
+				ands = append(ands, expr+" IS NOT NULL")
+			}
+		case kolektor.OpIn:
+			inValues, err := stores.InValues(cond.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			if len(inValues) == 0 {
+				ands = append(ands, "FALSE")
+				continue
+			}
+			for _, v := range inValues {
+				if isJSON {
+					v = stores.JSONValue(v)
+				}
+				values = append(values, v)
+			}
+			ands = append(ands, fmt.Sprintf("%s IN (%s)",
+				expr, strings.TrimSuffix(strings.Repeat("?, ", len(inValues)), ", ")))
+		default:
+			if !cond.Operator.Valid() {
+				return "", nil, fmt.Errorf("unsupported operator '%s'", cond.Operator)
+			}
+			value := cond.Value
+			if isJSON {
+				value = stores.JSONValue(value)
+			}
+			ands = append(ands, fmt.Sprintf("%s %s ?", expr, cond.Operator))
+			values = append(values, value)
+		}
+	}
+
+	return " WHERE " + strings.Join(ands, " AND "), values, nil
+}
+
+// queryClauses returns the WHERE, ORDER BY, and LIMIT clauses including
+// the values for query.
+func queryClauses(query *kolektor.Query) (string, []any, error) {
+	if query == nil {
+		return "", nil, nil
+	}
+
+	clauses, values, err := whereClause(query.Filter)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if query.After != nil {
+		descending, err := stores.CheckKeyset(query.Order, query.After)
+		if err != nil {
+			return "", nil, err
+		}
+
+		var fields []string
+		for _, o := range query.Order {
+			fields = append(fields, o.Field)
+		}
+
+		op := ">"
+		if descending {
+			op = "<"
+		}
+
+		keyset := fmt.Sprintf("(%s) %s (%s)", strings.Join(fields, ", "), op,
+			strings.TrimSuffix(strings.Repeat("?, ", len(fields)), ", "))
+		if clauses == "" {
+			clauses = " WHERE " + keyset
+		} else {
+			clauses += " AND " + keyset
+		}
+		values = append(values, query.After...)
+	}
+
+	if len(query.Order) > 0 {
+		var orders []string
+		for _, o := range query.Order {
+			expr, err := orderExpression(o.Field)
+			if err != nil {
+				return "", nil, err
+			}
+			if o.Descending {
+				expr += " DESC"
+			}
+			orders = append(orders, expr)
+		}
+		clauses += " ORDER BY " + strings.Join(orders, ", ")
+	}
+
+	switch {
+	case query.Limit > 0:
+		clauses += " LIMIT " + strconv.Itoa(query.Limit)
+	case query.Offset > 0:
+		// MySQL does not support OFFSET without LIMIT
+		clauses += " LIMIT 18446744073709551615"
+	}
+
+	if query.Offset > 0 {
+		clauses += " OFFSET " + strconv.Itoa(query.Offset)
+	}
+
+	return clauses, values, nil
+}
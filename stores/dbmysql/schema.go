@@ -0,0 +1,91 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbmysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// addSchemaCheck adds a CHECK constraint to the table validating the data
+// column against the JSON Schema of the model. The name of the constraint
+// contains the checksum of the schema so that it is recreated when the
+// schema changes. The constraint is dropped when the model does not have
+// a schema (anymore).
+func addSchemaCheck(ctx context.Context, conn stores.SQLQuerier, model kolektor.Modeler, tableName string) error {
+	prefix := tableName + "_schema_"
+
+	haveChecks, err := getChecks(ctx, conn, tableName)
+	if err != nil {
+		return err
+	}
+
+	var want string
+	var schema string
+	if schemer, ok := model.(kolektor.JSONSchemer); ok {
+		schema = schemer.JSONSchema()
+		want = prefix + md5sum(schema)[:8]
+	}
+
+	var alters []string
+	have := false
+	for _, name := range haveChecks {
+		switch {
+		case name == want:
+			have = true
+		case strings.HasPrefix(name, prefix):
+			alters = append(alters, "DROP CHECK "+name)
+		}
+	}
+
+	if want != "" && !have {
+		alters = append(alters, fmt.Sprintf("ADD CONSTRAINT %s CHECK (JSON_SCHEMA_VALID(%s, data))",
+			want, quoteString(schema)))
+	}
+
+	if len(alters) > 0 {
+		ddl := "ALTER TABLE " + tableName + " " + strings.Join(alters, ", ")
+		if _, err := conn.ExecContext(ctx, ddl); err != nil {
+			return fmt.Errorf("failed adding JSON schema check for %s (%w)", tableName, err)
+		}
+	}
+
+	return nil
+}
+
+func getChecks(ctx context.Context, conn stores.SQLQuerier, tableName string) ([]string, error) {
+	q := "SELECT CONSTRAINT_NAME FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS" +
+		" WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_TYPE = 'CHECK'"
+
+	rows, err := conn.QueryContext(ctx, q, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting checks (%w)", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed getting checks (%w)", err)
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed getting checks (%w)", err)
+	}
+
+	return names, nil
+}
+
+// quoteString returns s as SQL string literal, for use within statements
+// which do not support placeholders, such as DDL.
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
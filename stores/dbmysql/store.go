@@ -9,6 +9,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"text/template"
@@ -16,21 +17,32 @@ import (
 	"github.com/go-sql-driver/mysql"
 	"github.com/golistic/kolekto/kolektor"
 	"github.com/golistic/kolekto/stores"
-	"github.com/golistic/xstrings"
 )
 
 // Store defines the MySQL backed data store.
+// The connections use the time zone UTC ('+00:00'), unless the DSN sets
+// the time_zone parameter, which is needed for the timestamps within the
+// metadata of objects to be correct. This affects the session of each
+// connection, including statements executed using Connection, for example,
+// NOW() returns the time in UTC.
 type Store struct {
 	pool *sql.DB
+	// db is used to execute statements which must be part of a
+	// transaction, if any.
+	db     stores.SQLQuerier
+	logger kolektor.StatementLogger
 }
 
 var _ kolektor.Storer = &Store{}
+var _ kolektor.PoolStater = &Store{}
+var _ kolektor.StatementLogging = &Store{}
 
 func init() {
 	stores.Register(kolektor.MySQL, New)
 }
 
-// New instantiates a PostgreSQL backed data store.
+// New instantiates a MySQL backed data store. The time_zone of the
+// connections is set to UTC, unless dsn sets it.
 func New(dsn string) (kolektor.Storer, error) {
 	var err error
 
@@ -42,19 +54,25 @@ func New(dsn string) (kolektor.Storer, error) {
 		config.Params = map[string]string{}
 	}
 	config.Params["parseTime"] = "true"
+	if _, have := config.Params["time_zone"]; !have {
+		// timestamps are formatted as UTC within the metadata of objects
+		config.Params["time_zone"] = "'+00:00'"
+	}
 
 	s := &Store{}
 	s.pool, err = sql.Open("mysql", config.FormatDSN())
 	if err != nil {
-		return nil, fmt.Errorf("failed checking store connection (%w)", err)
+		return nil, fmt.Errorf("failed checking store connection (%w)", translateError(err))
 	}
 
+	s.db = s.pool
+
 	if err := s.pool.PingContext(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed checking store connection (%w)", err)
+		return nil, fmt.Errorf("failed checking store connection (%w)", translateError(err))
 	}
 
-	if err := s.init(); err != nil {
-		return nil, fmt.Errorf("failed checking store connection (%w)", err)
+	if err := s.init(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed checking store connection (%w)", translateError(err))
 	}
 
 	return s, nil
@@ -67,10 +85,29 @@ func (s *Store) Connection(ctx context.Context) (any, error) {
 	return s.connection(ctx)
 }
 
+// PoolStats returns the statistics of the pool of connections.
+func (s *Store) PoolStats() kolektor.PoolStats {
+	stats := s.pool.Stats()
+	return kolektor.PoolStats{
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration,
+	}
+}
+
+// SetStatementLogger logs the executed statements using logger.
+func (s *Store) SetStatementLogger(logger kolektor.StatementLogger) {
+	s.logger = logger
+	s.db = stores.LogSQLQuerier(s.db, logger)
+}
+
 func (s *Store) connection(ctx context.Context) (*sql.Conn, error) {
 	conn, err := s.pool.Conn(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed getting store collection (%w)", err)
+		return nil, fmt.Errorf("failed getting store collection (%w)", translateError(err))
 	}
 	return conn, nil
 }
@@ -91,30 +128,24 @@ func (s *Store) Name() string {
 }
 
 // GetObject retrieves a stored object and stores it in obj.
-func (s *Store) GetObject(obj kolektor.Modeler, fieldMap kolektor.FieldMap) error {
+func (s *Store) GetObject(ctx context.Context, obj kolektor.Modeler, fieldMap kolektor.FieldMap) error {
 	if len(fieldMap) == 0 {
 		return fmt.Errorf("need at least one field to filter on")
 	}
 
-	var ands []string
-	var values []any
-	for name, value := range fieldMap {
-		if !(strings.HasPrefix(name, "(") || xstrings.Search(stores.ReservedFields, name) != -1) {
-			name = fmt.Sprintf("data->>'$.%s'", name)
-		}
-		ands = append(ands, name+" = ?")
-		values = append(values, value)
+	where, values, err := whereClause(kolektor.FilterFromFields(fieldMap))
+	if err != nil {
+		return fmt.Errorf("failed getting object (%w)", err)
 	}
 
-	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s",
-		mysqlMergeDataMeta, obj.CollectionName(), strings.Join(ands, " AND "))
+	q := fmt.Sprintf("SELECT %s FROM %s%s", mysqlMergeDataMeta, obj.CollectionName(), where)
 
 	var data []byte
-	if err := s.pool.QueryRowContext(context.Background(), q, values...).Scan(&data); err != nil {
+	if err := s.db.QueryRowContext(ctx, q, values...).Scan(&data); err != nil {
 		if err == sql.ErrNoRows {
 			return stores.ErrNoObject{Name: obj.CollectionName()}
 		}
-		return fmt.Errorf("failed getting object (%w)", err)
+		return fmt.Errorf("failed getting object (%w)", translateError(err))
 	}
 
 	if err := json.Unmarshal(data, obj); err != nil {
@@ -125,62 +156,295 @@ func (s *Store) GetObject(obj kolektor.Modeler, fieldMap kolektor.FieldMap) erro
 }
 
 // StoreObject stores obj into the collection of the object's model.
-func (s *Store) StoreObject(obj kolektor.Modeler) (*kolektor.Meta, error) {
+// Objects which were stored before are only updated when their version
+// did not change, otherwise stores.ErrConflict is returned.
+func (s *Store) StoreObject(ctx context.Context, obj kolektor.Modeler) (*kolektor.Meta, error) {
 	objID := obj.GetID()
 	objUID := obj.GetUID()
-	obj.SetMeta(nil) // we do not save Meta in the JSON document
+	objVersion := obj.GetVersion()
 
-	data, err := json.Marshal(obj)
+	data, err := stores.MarshalObject(obj)
 	if err != nil {
 		return nil, fmt.Errorf("failed storing object (%w)", err)
 	}
 
 	var res sql.Result
 	if objID == 0 {
-		q := fmt.Sprintf("INSERT INTO %s (data, uid) VALUES (?, ?)", obj.CollectionName())
+		q := fmt.Sprintf("INSERT INTO %s (data, uid, schema_version) VALUES (?, ?, ?)", obj.CollectionName())
 		var err error
-		res, err = s.pool.ExecContext(context.Background(), q, data, objUID)
+		res, err = s.db.ExecContext(ctx, q, data, objUID, stores.SchemaVersion(obj))
 		if err != nil {
-			return nil, fmt.Errorf("failed storing object (%w)", err)
+			return nil, storeError(obj, "failed storing object", err)
 		}
 		objID, err = res.LastInsertId()
 		if err != nil {
-			return nil, fmt.Errorf("failed storing object (%w)", err)
+			return nil, fmt.Errorf("failed storing object (%w)", translateError(err))
 		}
 	} else {
-		q := fmt.Sprintf("UPDATE %s SET data = ?, uid = ? WHERE id = ?",
+		q := fmt.Sprintf("UPDATE %s SET data = ?, uid = ?, schema_version = ?, version = version + 1 WHERE id = ?",
 			obj.CollectionName())
+		values := []any{data, objUID, stores.SchemaVersion(obj), objID}
+		if objVersion > 0 {
+			// only update when nobody else did since the object was retrieved
+			q += " AND version = ?"
+			values = append(values, objVersion)
+		}
+
 		var err error
-		res, err = s.pool.ExecContext(context.Background(), q, data, objUID, objID)
+		res, err = s.db.ExecContext(ctx, q, values...)
+		if err != nil {
+			return nil, storeError(obj, "failed storing object", err)
+		}
+
+		n, err := res.RowsAffected()
 		if err != nil {
-			return nil, fmt.Errorf("failed storing object (%w)", err)
+			return nil, fmt.Errorf("failed storing object (%w)", translateError(err))
+		}
+		if n == 0 {
+			return nil, s.updateError(ctx, obj, objID, objVersion)
 		}
 	}
 
 	// second round-trip to fetch meta
 	meta := &kolektor.Meta{}
 	q := "SELECT " + dmlReturningMeta + " FROM " + obj.CollectionName() + " WHERE id = ?"
-	row := s.pool.QueryRowContext(context.Background(), q, objID)
-	if err := row.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated); err != nil {
-		return nil, fmt.Errorf("failed storing object (%w)", err)
+	row := s.db.QueryRowContext(ctx, q, objID)
+	if err := row.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated, &meta.Version, &meta.Deleted, &meta.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("failed storing object (%w)", translateError(err))
 	}
 
 	return meta, nil
 }
 
-func (s *Store) init() error {
-	conn, err := s.pool.Conn(context.Background())
+// updateError returns the error for an update of obj which did not change
+// any row: stores.ErrNoObject when the object does not exist, otherwise
+// stores.ErrConflict since its version changed.
+func (s *Store) updateError(ctx context.Context, obj kolektor.Modeler, id, version int64) error {
+	q := "SELECT version FROM " + obj.CollectionName() + " WHERE id = ?"
+
+	var current int64
+	if err := s.db.QueryRowContext(ctx, q, id).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return stores.ErrNoObject{Name: obj.CollectionName()}
+		}
+		return fmt.Errorf("failed storing object (%w)", translateError(err))
+	}
+
+	return stores.ErrConflict{Name: obj.CollectionName(), ID: id, Version: version}
+}
+
+// storeError returns stores.ErrInvalid when err is caused by the JSON
+// schema check of the model's collection, otherwise err prefixed with msg.
+func storeError(model kolektor.Modeler, msg string, err error) error {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == errCheckConstraintViolated {
+		return stores.ErrInvalid{Name: model.CollectionName(), Err: err}
+	}
+	return fmt.Errorf("%s (%w)", msg, translateError(err))
+}
+
+// StoreObjects stores objs into the model's collection. New objects are
+// inserted using one multi-row INSERT statement, while objects which were
+// stored before are updated one by one. The returned metadata is in the
+// same order as objs. Either all objects are stored, or none, only when
+// done within a transaction.
+func (s *Store) StoreObjects(ctx context.Context, model kolektor.Modeler, objs []kolektor.Modeler) ([]*kolektor.Meta, error) {
+	metas := make([]*kolektor.Meta, len(objs))
+	schemaVersion := stores.SchemaVersion(model)
+
+	var inserted []int
+	var uids []string
+	var values []any
+
+	for i, obj := range objs {
+		if obj.GetID() != 0 {
+			meta, err := s.StoreObject(ctx, obj)
+			if err != nil {
+				return nil, err
+			}
+			metas[i] = meta
+			continue
+		}
+
+		// UIDs are generated so we can match the metadata with the objects
+		uid := obj.GetUID()
+		if uid == "" {
+			var err error
+			if uid, err = stores.NewUID(); err != nil {
+				return nil, fmt.Errorf("failed storing objects (%w)", err)
+			}
+		}
+
+		data, err := stores.MarshalObject(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed storing objects (%w)", err)
+		}
+
+		inserted = append(inserted, i)
+		uids = append(uids, uid)
+		values = append(values, data, uid, schemaVersion)
+	}
+
+	if len(inserted) == 0 {
+		return metas, nil
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s (data, uid, schema_version) VALUES %s", model.CollectionName(),
+		strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(inserted)), ", "))
+	res, err := s.db.ExecContext(ctx, q, values...)
+	if err != nil {
+		return nil, storeError(model, "failed storing objects", err)
+	}
+
+	firstID, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+	}
+
+	// second round-trip to fetch meta
+	values = []any{firstID}
+	for _, uid := range uids {
+		values = append(values, uid)
+	}
+	q = fmt.Sprintf("SELECT %s FROM %s WHERE id >= ? AND uid IN (%s) ORDER BY id",
+		dmlReturningMeta, model.CollectionName(), strings.TrimSuffix(strings.Repeat("?, ", len(uids)), ", "))
+
+	rows, err := s.db.QueryContext(ctx, q, values...)
 	if err != nil {
-		return fmt.Errorf("init MySQL store failed (%w)", err)
+		return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+	}
+	defer func() { _ = rows.Close() }()
+
+	queue := stores.MetaQueue{}
+	for rows.Next() {
+		meta := &kolektor.Meta{}
+		if err := rows.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated, &meta.Version, &meta.Deleted, &meta.SchemaVersion); err != nil {
+			return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+		}
+		queue.Push(meta.UID, meta)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+	}
+
+	for n, i := range inserted {
+		if metas[i] = queue.Pop(uids[n]); metas[i] == nil {
+			return nil, fmt.Errorf("failed storing objects (no metadata for %s)", uids[n])
+		}
+	}
+
+	return metas, nil
+}
+
+// UpgradeObject replaces the JSON document of the object with the given ID
+// by data, recording the schema version of model. The version of the object
+// is kept. It returns false, leaving the object untouched, when the object
+// does not exist or its version is no longer version.
+func (s *Store) UpgradeObject(ctx context.Context, model kolektor.Modeler, id, version int64, data []byte) (bool, error) {
+	q := fmt.Sprintf("UPDATE %s SET data = ?, schema_version = ? WHERE id = ? AND version = ?",
+		model.CollectionName())
+
+	res, err := s.db.ExecContext(ctx, q, data, stores.SchemaVersion(model), id, version)
+	if err != nil {
+		return false, fmt.Errorf("failed upgrading object (%w)", translateError(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed upgrading object (%w)", translateError(err))
+	}
+
+	return n > 0, nil
+}
+
+// DeleteObjects removes the objects of the model's collection matching
+// filter and returns the number of removed objects.
+func (s *Store) DeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	where, values, err := whereClause(filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("DELETE FROM %s%s", model.CollectionName(), where)
+
+	res, err := s.db.ExecContext(ctx, q, values...)
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", translateError(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", translateError(err))
+	}
+
+	return n, nil
+}
+
+// SoftDeleteObjects sets the deleted timestamp of the objects of the
+// model's collection matching filter which were not yet soft deleted,
+// and returns the number of soft deleted objects.
+func (s *Store) SoftDeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	return s.setDeleted(ctx, model, filter, true)
+}
+
+// RestoreObjects clears the deleted timestamp of the soft deleted objects
+// of the model's collection matching filter, and returns the number of
+// restored objects.
+func (s *Store) RestoreObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	return s.setDeleted(ctx, model, filter, false)
+}
+
+func (s *Store) setDeleted(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter, deleted bool) (int64, error) {
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	set := "CURRENT_TIMESTAMP(6)"
+	op := kolektor.OpIsNull
+	if !deleted {
+		set = "NULL"
+		op = kolektor.OpIsNotNull
+	}
+
+	where, values, err := whereClause(append(filter[:len(filter):len(filter)],
+		kolektor.Condition{Field: "deleted", Operator: op}))
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("UPDATE %s SET deleted = %s, version = version + 1%s", model.CollectionName(), set, where)
+
+	res, err := s.db.ExecContext(ctx, q, values...)
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", translateError(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", translateError(err))
+	}
+
+	return n, nil
+}
+
+func (s *Store) init(ctx context.Context) error {
+	conn, err := s.pool.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("init MySQL store failed (%w)", translateError(err))
 	}
 	defer func() { _ = conn.Close() }()
 
 	for name, r := range mysqlRoutines {
-		v, err := mysqlRoutineVersion(conn, name)
+		v, err := mysqlRoutineVersion(ctx, conn, name)
 		switch {
 		case err == sql.ErrNoRows || v < r.version:
-			if _, err := conn.ExecContext(context.Background(), "DROP FUNCTION IF EXISTS "+name); err != nil {
-				return fmt.Errorf("init MySQL store failed (%w)", err)
+			if _, err := conn.ExecContext(ctx, "DROP FUNCTION IF EXISTS "+name); err != nil {
+				return fmt.Errorf("init MySQL store failed (%w)", translateError(err))
 			}
 
 			tmpl, err := template.New("sql").Parse(r.ddl)
@@ -199,67 +463,149 @@ func (s *Store) init() error {
 				return fmt.Errorf("init MySQL store failed (%w)", err)
 			}
 
-			if _, err := conn.ExecContext(context.Background(), ddl.String()); err != nil {
-				return fmt.Errorf("init MySQL store failed (%w)", err)
+			if _, err := conn.ExecContext(ctx, ddl.String()); err != nil {
+				return fmt.Errorf("init MySQL store failed (%w)", translateError(err))
 			}
 		case err != nil:
-			return fmt.Errorf("init MySQL store failed (%w)", err)
+			return fmt.Errorf("init MySQL store failed (%w)", translateError(err))
 		}
 	}
 
 	return nil
 }
 
-// InitCollection initializes the model's collection.
-func (s *Store) InitCollection(model kolektor.Modeler) error {
-	conn, err := s.connection(context.Background())
+// InitCollection initializes the model's collection. When the model is
+// a kolektor.JSONSchemer, the schema is enforced using a CHECK constraint.
+func (s *Store) InitCollection(ctx context.Context, model kolektor.Modeler) error {
+	conn, err := s.connection(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = conn.Close() }()
 
+	db := stores.LogSQLQuerier(conn, s.logger)
+
 	tableName := model.CollectionName()
 
 	// default for uid is set using trigger
 	ddl := ddlTable(tableName)
 
 	// CREATE TABLE
-	if _, err := conn.ExecContext(context.Background(), ddl); err != nil {
-		return fmt.Errorf("failed initializing collection (%w)", err)
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
+	}
+
+	if err := migrateTable(ctx, db, tableName); err != nil {
+		return translateError(err)
+	}
+
+	if err := addMetaIndexes(ctx, db, tableName); err != nil {
+		return translateError(err)
 	}
 
 	// CREATE TRIGGERs
 	tr := fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS tr_%s_updated
 BEFORE INSERT ON %s FOR EACH ROW SET new.uid = IF(new.uid='', default_uid(), new.uid)`,
 		tableName, tableName)
-	if _, err := conn.ExecContext(context.Background(), tr); err != nil {
-		return fmt.Errorf("failed initializing collection (%w)", err)
+	if _, err := db.ExecContext(ctx, tr); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
 	}
 
 	tr = fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS tr_%s_updated
 BEFORE UPDATE ON %s FOR EACH ROW SET new.uid = IF(new.uid='', default_uid(), new.uid)`,
 		tableName, tableName)
-	if _, err := conn.ExecContext(context.Background(), tr); err != nil {
-		return fmt.Errorf("failed initializing collection (%w)", err)
+	if _, err := db.ExecContext(ctx, tr); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
 	}
 
 	// INDEXING
-	if idxer, ok := model.(kolektor.Indexer); ok {
-		if err := addIndexes(conn, idxer, tableName); err != nil {
-			return err
-		}
+	indexes, err := stores.ModelIndexes(model, kolektor.MySQL)
+	if err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", err)
+	}
+	if err := addIndexes(ctx, db, indexes, tableName); err != nil {
+		return translateError(err)
+	}
+
+	// JSON SCHEMA
+	if err := addSchemaCheck(ctx, db, model, tableName); err != nil {
+		return translateError(err)
 	}
 
 	return nil
 }
 
 // RemoveCollection removes the model's collection.
-func (s *Store) RemoveCollection(model kolektor.Modeler) error {
+func (s *Store) RemoveCollection(ctx context.Context, model kolektor.Modeler) error {
 	ddl := fmt.Sprintf("DROP TABLE IF EXISTS %s", model.CollectionName())
 
-	if _, err := s.pool.ExecContext(context.Background(), ddl); err != nil {
-		return fmt.Errorf("failed removing collection (%w)", err)
+	if _, err := s.db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("failed removing collection (%w)", translateError(err))
 	}
 
 	return nil
 }
+
+// ListCollections returns the names of all collections within the current
+// database sorted by name, which are the tables having the columns of a
+// collection.
+func (s *Store) ListCollections(ctx context.Context) ([]string, error) {
+	q := "SELECT TABLE_NAME FROM INFORMATION_SCHEMA.COLUMNS" +
+		" WHERE TABLE_SCHEMA = DATABASE() AND COLUMN_NAME IN ('id', 'uid', 'data')" +
+		" GROUP BY TABLE_NAME HAVING COUNT(*) = 3 ORDER BY TABLE_NAME"
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
+	}
+	defer func() { _ = rows.Close() }()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
+	}
+
+	return names, nil
+}
+
+// ListIndexes returns the indexes of the model's collection sorted by name,
+// except for the primary key. The definition of each index is the list of
+// its key parts. Indexes are not valid when they are invisible to the
+// optimizer. No indexes are returned when the collection does not exist.
+func (s *Store) ListIndexes(ctx context.Context, model kolektor.Modeler) ([]kolektor.IndexStatus, error) {
+	q := "SELECT INDEX_NAME, MIN(NON_UNIQUE) = 0," +
+		" CONCAT('(', GROUP_CONCAT(COALESCE(EXPRESSION, COLUMN_NAME) ORDER BY SEQ_IN_INDEX SEPARATOR ', '), ')')," +
+		" MIN(IS_VISIBLE) = 'YES', MAX(INDEX_COMMENT) LIKE 'kolekto#%'" +
+		" FROM INFORMATION_SCHEMA.STATISTICS" +
+		" WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY'" +
+		" GROUP BY INDEX_NAME ORDER BY INDEX_NAME"
+
+	rows, err := s.db.QueryContext(ctx, q, model.CollectionName())
+	if err != nil {
+		return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+	}
+	defer func() { _ = rows.Close() }()
+
+	var indexes []kolektor.IndexStatus
+	for rows.Next() {
+		var idx kolektor.IndexStatus
+		if err := rows.Scan(&idx.Name, &idx.Unique, &idx.Definition, &idx.Valid, &idx.Managed); err != nil {
+			return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+		}
+		indexes = append(indexes, idx)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+	}
+
+	return indexes, nil
+}
@@ -5,10 +5,16 @@
 package dbmysql
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/geertjanvdk/xkit/xt"
+	"github.com/go-sql-driver/mysql"
 	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
 )
 
 type Book struct {
@@ -69,8 +75,8 @@ func TestStore_InitCollection(t *testing.T) {
 			}
 		}
 
-		xt.OK(t, store.InitCollection(book))
-		indexes, err := getIndexes(store.mustSQLConn(), book.CollectionName())
+		xt.OK(t, store.InitCollection(context.Background(), book))
+		indexes, err := getIndexes(context.Background(), store.mustSQLConn(), book.CollectionName())
 		xt.OK(t, err)
 		xt.Eq(t, 2, len(indexes))
 		exprSum, _ := indexes[expIndex1[0]]
@@ -94,8 +100,8 @@ func TestStore_InitCollection(t *testing.T) {
 				}
 			}
 
-			xt.OK(t, store.InitCollection(book))
-			indexes, err := getIndexes(store.mustSQLConn(), book.CollectionName())
+			xt.OK(t, store.InitCollection(context.Background(), book))
+			indexes, err := getIndexes(context.Background(), store.mustSQLConn(), book.CollectionName())
 			xt.OK(t, err)
 			xt.Eq(t, 1, len(indexes))
 			exprSum, _ := indexes[expIndex1[0]]
@@ -120,8 +126,8 @@ func TestStore_InitCollection(t *testing.T) {
 				}
 			}
 
-			xt.OK(t, store.InitCollection(book))
-			indexes, err := getIndexes(store.mustSQLConn(), book.CollectionName())
+			xt.OK(t, store.InitCollection(context.Background(), book))
+			indexes, err := getIndexes(context.Background(), store.mustSQLConn(), book.CollectionName())
 			xt.OK(t, err)
 			xt.Eq(t, 1, len(indexes))
 			exprSum, _ := indexes[expIndex1[0]]
@@ -129,3 +135,201 @@ func TestStore_InitCollection(t *testing.T) {
 		})
 	})
 }
+
+func TestStore_GetObject(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+
+	book := &Book{
+		fuCollectionName: func() string { return "books_c8d3k2x1" },
+		fuIndex:          func() map[kolektor.StoreKind][]kolektor.Index { return nil },
+	}
+	xt.OK(t, store.InitCollection(context.Background(), book))
+	book.ISBN13 = "978-0135800911"
+	_, err = store.StoreObject(context.Background(), book)
+	xt.OK(t, err)
+
+	t.Run("cancelled context aborts slow query", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := store.GetObject(ctx, book, kolektor.FieldMap{"(SELECT SLEEP(5))": 0})
+		xt.KO(t, err)
+		xt.Assert(t, time.Since(start) < 4*time.Second, "expected query to be aborted")
+		xt.Eq(t, context.DeadlineExceeded, ctx.Err())
+	})
+
+	t.Run("already cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := store.GetObject(ctx, book, kolektor.FieldMap{"isbn13": book.ISBN13})
+		xt.Assert(t, errors.Is(err, context.Canceled), "expected context.Canceled")
+	})
+}
+
+func TestStore_InitCollection_migrate(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+
+	book := &Book{
+		fuCollectionName: func() string { return "books_m1g7a2e4" },
+		fuIndex:          func() map[kolektor.StoreKind][]kolektor.Index { return nil },
+	}
+
+	// table as created before objects had versions
+	ddl := "CREATE TABLE " + book.CollectionName() + " (id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, uid VARCHAR(200) NOT NULL, created TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6), updated TIMESTAMP(6) NULL ON UPDATE CURRENT_TIMESTAMP(6), data JSON)"
+	_, err = store.mustSQLConn().ExecContext(context.Background(), ddl)
+	xt.OK(t, err)
+
+	xt.OK(t, store.InitCollection(context.Background(), book))
+
+	book.ISBN13 = "978-0201835953"
+	meta, err := store.StoreObject(context.Background(), book)
+	xt.OK(t, err)
+	xt.Eq(t, int64(1), meta.Version)
+}
+
+type schemaBook struct {
+	Book
+	schema string
+}
+
+func (b schemaBook) JSONSchema() string {
+	return b.schema
+}
+
+func TestStore_InitCollection_schema(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+	ctx := context.Background()
+
+	book := &schemaBook{
+		Book: Book{
+			fuCollectionName: func() string { return "books_s4c7e9m2" },
+			fuIndex:          func() map[kolektor.StoreKind][]kolektor.Index { return nil },
+		},
+		schema: `{"type": "object", "required": ["isbn13"], "properties": {"isbn13": {"type": "string", "minLength": 13}}}`,
+	}
+
+	t.Run("check is added", func(t *testing.T) {
+		xt.OK(t, store.RemoveCollection(ctx, book))
+		xt.OK(t, store.InitCollection(ctx, book))
+
+		checks, err := getChecks(ctx, store.mustSQLConn(), book.CollectionName())
+		xt.OK(t, err)
+		xt.Eq(t, []string{book.CollectionName() + "_schema_" + md5sum(book.schema)[:8]}, checks)
+
+		book.ISBN13 = "978"
+		_, err = store.StoreObject(ctx, book)
+		xt.Assert(t, errors.As(err, &stores.ErrInvalid{}), "expected stores.ErrInvalid")
+
+		book.ISBN13 = "978-0134190440"
+		_, err = store.StoreObject(ctx, book)
+		xt.OK(t, err)
+	})
+
+	t.Run("check is replaced when schema changes", func(t *testing.T) {
+		book.schema = `{"type": "object", "required": ["isbn13", "title"]}`
+		xt.OK(t, store.RemoveCollection(ctx, book))
+		xt.OK(t, store.InitCollection(ctx, book))
+		book.schema = `{"type": "object", "required": ["isbn13"]}`
+		xt.OK(t, store.InitCollection(ctx, book))
+
+		checks, err := getChecks(ctx, store.mustSQLConn(), book.CollectionName())
+		xt.OK(t, err)
+		xt.Eq(t, []string{book.CollectionName() + "_schema_" + md5sum(book.schema)[:8]}, checks)
+	})
+}
+
+type taggedBook struct {
+	kolektor.Model
+	ISBN13 string `json:"isbn13" kolekto:"index,unique,len=20"`
+	Title  string `json:"title" kolekto:"index"`
+}
+
+func (b taggedBook) CollectionName() string {
+	return "books_t4g9e1d5"
+}
+
+func TestStore_InitCollection_tagged(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+	ctx := context.Background()
+
+	book := &taggedBook{}
+	xt.OK(t, store.RemoveCollection(ctx, book))
+	xt.OK(t, store.InitCollection(ctx, book))
+
+	indexes, err := getIndexes(ctx, store.mustSQLConn(), book.CollectionName())
+	xt.OK(t, err)
+	xt.Eq(t, map[string]string{
+		"uq_books_t4g9e1d5_isbn13": md5sum("((CAST(data->>'$.isbn13' AS CHAR(20))))"),
+		"ix_books_t4g9e1d5_title":  md5sum("((CAST(data->>'$.title' AS CHAR(255))))"),
+	}, indexes)
+}
+
+func TestIndexFieldExpression(t *testing.T) {
+	idx := kolektor.Index{
+		Name: "ix_books_publisher_year",
+		Fields: []kolektor.IndexField{
+			{Path: "publisher.name", Length: 100},
+			{Path: "year", Type: kolektor.IndexInt, Descending: true},
+			{Path: "price", Type: kolektor.IndexFloat},
+			{Path: "inStock", Type: kolektor.IndexBool},
+			{Path: "released", Type: kolektor.IndexTimestamp},
+		},
+	}
+
+	expr, err := stores.IndexExpression(idx, indexFieldExpression)
+	xt.OK(t, err)
+	xt.Eq(t, "((CAST(data->>'$.publisher.name' AS CHAR(100))), (CAST(data->>'$.year' AS SIGNED)) DESC, "+
+		"(CAST(data->>'$.price' AS DOUBLE)), (CAST(data->>'$.inStock' AS CHAR(5))), "+
+		"(CAST(data->>'$.released' AS CHAR(35))))", expr)
+
+	idx.Expression = "((CAST(data->>'$.isbn13' AS CHAR(20))))"
+	expr, err = stores.IndexExpression(idx, indexFieldExpression)
+	xt.OK(t, err)
+	xt.Eq(t, idx.Expression, expr)
+}
+
+func TestTranslateError(t *testing.T) {
+	t.Run("duplicate entry", func(t *testing.T) {
+		myErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'books.uq_books_isbn13'"}
+		err := translateError(fmt.Errorf("failed storing object (%w)", myErr))
+
+		var storeErr *kolektor.StoreError
+		xt.Assert(t, errors.As(err, &storeErr))
+		xt.Assert(t, errors.Is(err, kolektor.ErrDuplicateKey))
+		xt.Eq(t, "1062", storeErr.Code)
+		xt.Eq(t, "uq_books_isbn13", storeErr.Index)
+		xt.Assert(t, errors.As(err, &myErr))
+	})
+
+	t.Run("classified error numbers", func(t *testing.T) {
+		cases := map[uint16]error{
+			1213: kolektor.ErrConflict,
+			1205: kolektor.ErrTimeout,
+			1146: kolektor.ErrNotFound,
+			1103: kolektor.ErrInvalidCollectionName,
+			1053: kolektor.ErrStoreUnavailable,
+		}
+		for number, kind := range cases {
+			xt.Assert(t, errors.Is(translateError(&mysql.MySQLError{Number: number}), kind))
+		}
+	})
+
+	t.Run("other errors", func(t *testing.T) {
+		xt.Assert(t, errors.Is(translateError(mysql.ErrInvalidConn), kolektor.ErrStoreUnavailable))
+		xt.Assert(t, errors.Is(translateError(context.DeadlineExceeded), kolektor.ErrTimeout))
+
+		myErr := &mysql.MySQLError{Number: 1064}
+		xt.Eq(t, error(myErr), translateError(myErr))
+		xt.Assert(t, translateError(nil) == nil)
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nomysql
+
+package dbmysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// txStore is a Store bound to a transaction.
+type txStore struct {
+	*Store
+	tx *sql.Tx
+}
+
+var _ kolektor.TxStorer = &txStore{}
+
+// BeginTx starts a transaction and returns a store which executes all
+// statements within it. When opts is nil, the default isolation level
+// of the server is used.
+func (s *Store) BeginTx(ctx context.Context, opts *sql.TxOptions) (kolektor.TxStorer, error) {
+	tx, err := s.pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed starting transaction (%w)", translateError(err))
+	}
+
+	return &txStore{
+		Store: &Store{pool: s.pool, db: stores.LogSQLQuerier(tx, s.logger), logger: s.logger},
+		tx:    tx,
+	}, nil
+}
+
+// BeginTx returns an error since nested transactions are not supported.
+func (s *txStore) BeginTx(context.Context, *sql.TxOptions) (kolektor.TxStorer, error) {
+	return nil, fmt.Errorf("nested transactions are not supported")
+}
+
+// Connection returns the transaction of this store. The caller is
+// responsible for type asserting the result to *sql.Tx.
+func (s *txStore) Connection(context.Context) (any, error) {
+	return s.tx, nil
+}
+
+// Commit commits the transaction.
+func (s *txStore) Commit(context.Context) error {
+	if err := s.tx.Commit(); err != nil {
+		return fmt.Errorf("failed committing transaction (%w)", translateError(err))
+	}
+	return nil
+}
+
+// Rollback rolls back the transaction.
+func (s *txStore) Rollback(context.Context) error {
+	if err := s.tx.Rollback(); err != nil {
+		return fmt.Errorf("failed rolling back transaction (%w)", translateError(err))
+	}
+	return nil
+}
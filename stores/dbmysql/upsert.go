@@ -0,0 +1,97 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nomysql
+
+package dbmysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// UpsertObject inserts obj, or updates the object which has the same
+// values for keyFields, and returns the resulting metadata and whether obj
+// was inserted. The keyFields identify a unique index of the model (see
+// stores.UniqueIndex), or the uid column when empty. Updated objects are
+// restored when they were soft deleted.
+//
+// MySQL uses INSERT ... ON DUPLICATE KEY UPDATE, which updates the object
+// conflicting with any of the unique keys, not only the one identified by
+// keyFields. Objects are therefore only upserted when the unique key
+// identified by keyFields is the only one: models with unique indexes
+// cannot be upserted using the uid, and models with more than one unique
+// index cannot be upserted at all.
+func (s *Store) UpsertObject(ctx context.Context, obj kolektor.Modeler, keyFields []string) (*kolektor.Meta, bool, error) {
+	idx, err := stores.UniqueIndex(obj, kolektor.MySQL, keyFields)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	if err := checkUpsertKey(obj, idx); err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	objUID := obj.GetUID()
+
+	data, err := stores.MarshalObject(obj)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	// LAST_INSERT_ID(id) makes the ID of the updated row available
+	q := fmt.Sprintf("INSERT INTO %[1]s (data, uid, schema_version) VALUES (?, ?, ?) AS new "+
+		"ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(%[1]s.id), data = new.data, "+
+		"schema_version = new.schema_version, deleted = NULL, version = %[1]s.version + 1",
+		obj.CollectionName())
+
+	res, err := s.db.ExecContext(ctx, q, data, objUID, stores.SchemaVersion(obj))
+	if err != nil {
+		return nil, false, storeError(obj, "failed upserting object", err)
+	}
+
+	objID, err := res.LastInsertId()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", translateError(err))
+	}
+
+	// one affected row for inserts, two for updates
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", translateError(err))
+	}
+
+	// second round-trip to fetch meta
+	meta := &kolektor.Meta{}
+	q = "SELECT " + dmlReturningMeta + " FROM " + obj.CollectionName() + " WHERE id = ?"
+	row := s.db.QueryRowContext(ctx, q, objID)
+	if err := row.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated, &meta.Version, &meta.Deleted, &meta.SchemaVersion); err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", translateError(err))
+	}
+
+	return meta, n == 1, nil
+}
+
+// checkUpsertKey returns an error when obj has unique indexes other than idx,
+// which identifies the unique key used to upsert; the uid when nil.
+func checkUpsertKey(obj kolektor.Modeler, idx *kolektor.Index) error {
+	indexes, err := stores.ModelIndexes(obj, kolektor.MySQL)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range indexes {
+		if !other.Unique || (idx != nil && other.Name == idx.Name) {
+			continue
+		}
+		if idx == nil {
+			return fmt.Errorf("cannot upsert using uid; model has unique index %s", other.Name)
+		}
+		return fmt.Errorf("cannot upsert using unique index %s; model has unique index %s",
+			idx.Name, other.Name)
+	}
+
+	return nil
+}
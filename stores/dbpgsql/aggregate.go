@@ -0,0 +1,121 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nopgsql
+
+package dbpgsql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// CountObjects returns the number of objects in the model's collection
+// matching filter.
+func (s *Store) CountObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	args := &arguments{}
+	where, err := whereClause(filter, args)
+	if err != nil {
+		return 0, fmt.Errorf("failed counting objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", model.CollectionName(), where)
+
+	var count int64
+	if err := s.db.QueryRow(ctx, q, args.values...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed counting objects (%w)", translateError(err))
+	}
+
+	return count, nil
+}
+
+// ObjectsExist returns whether at least one object in the model's
+// collection matches filter.
+func (s *Store) ObjectsExist(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (bool, error) {
+	args := &arguments{}
+	where, err := whereClause(filter, args)
+	if err != nil {
+		return false, fmt.Errorf("failed checking objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s%s)", model.CollectionName(), where)
+
+	var exists bool
+	if err := s.db.QueryRow(ctx, q, args.values...).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed checking objects (%w)", translateError(err))
+	}
+
+	return exists, nil
+}
+
+// AggregateObjects aggregates the values of the objects in the model's
+// collection as defined by agg. Only numeric values are aggregated; other
+// values are ignored, as if they were not available.
+func (s *Store) AggregateObjects(ctx context.Context, model kolektor.Modeler, agg kolektor.Aggregation) ([]kolektor.AggregateResult, error) {
+	if !agg.Func.Valid() {
+		return nil, fmt.Errorf("failed aggregating objects (unsupported function '%s')", agg.Func)
+	}
+
+	aggExpr := "COUNT(*)"
+	if agg.Field != "" {
+		expr, err := jsonPath(agg.Field, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+		}
+		textExpr, _ := jsonPath(agg.Field, true)
+
+		value := fmt.Sprintf("CASE WHEN jsonb_typeof(%s) = 'number' THEN (%s)::double precision END", expr, textExpr)
+		if agg.Func == kolektor.AggCount {
+			aggExpr = fmt.Sprintf("COUNT(%s)", value)
+		} else {
+			aggExpr = fmt.Sprintf("%s(%s)::double precision", agg.Func, value)
+		}
+	} else if agg.Func != kolektor.AggCount {
+		return nil, fmt.Errorf("failed aggregating objects (field required for %s)", agg.Func)
+	}
+
+	groupExpr := "NULL::text"
+	if agg.GroupBy != "" {
+		var err error
+		if groupExpr, err = jsonPath(agg.GroupBy, true); err != nil {
+			return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+		}
+	}
+
+	args := &arguments{}
+	where, err := whereClause(agg.Filter, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT %s AS grp, %s FROM %s%s", groupExpr, aggExpr, model.CollectionName(), where)
+	if agg.GroupBy != "" {
+		q += " GROUP BY grp ORDER BY grp"
+	}
+
+	rows, err := s.db.Query(ctx, q, args.values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed aggregating objects (%w)", translateError(err))
+	}
+	defer rows.Close()
+
+	var result []kolektor.AggregateResult
+	for rows.Next() {
+		r := kolektor.AggregateResult{}
+		var dest any = &r.Value
+		if agg.Func == kolektor.AggCount {
+			dest = &r.Count
+		}
+		if err := rows.Scan(&r.Group, dest); err != nil {
+			return nil, fmt.Errorf("failed aggregating objects (%w)", translateError(err))
+		}
+		result = append(result, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed aggregating objects (%w)", translateError(err))
+	}
+
+	return result, nil
+}
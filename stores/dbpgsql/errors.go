@@ -0,0 +1,72 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nopgsql
+
+package dbpgsql
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	"github.com/jackc/pgconn"
+)
+
+// errorKinds maps PostgreSQL SQLSTATE codes to the errors classified by
+// kolektor.
+var errorKinds = map[string]error{
+	"23505": kolektor.ErrDuplicateKey,          // unique_violation
+	"40001": kolektor.ErrConflict,              // serialization_failure
+	"40P01": kolektor.ErrConflict,              // deadlock_detected
+	"55P03": kolektor.ErrTimeout,               // lock_not_available
+	"42P01": kolektor.ErrNotFound,              // undefined_table
+	"42602": kolektor.ErrInvalidCollectionName, // invalid_name
+	"42622": kolektor.ErrInvalidCollectionName, // name_too_long
+	"53300": kolektor.ErrStoreUnavailable,      // too_many_connections
+	"57P01": kolektor.ErrStoreUnavailable,      // admin_shutdown
+	"57P02": kolektor.ErrStoreUnavailable,      // crash_shutdown
+	"57P03": kolektor.ErrStoreUnavailable,      // cannot_connect_now
+}
+
+// translateError returns err as *kolektor.StoreError when the SQLSTATE
+// code, or the error itself, is classified by kolektor. Otherwise, err is
+// returned as is, which is also the case when err was already classified,
+// or was caused by cancelling the context.
+func translateError(err error) error {
+	var classified *kolektor.StoreError
+	if err == nil || errors.As(err, &classified) || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	// pgconn also reports a cancelled context as timeout
+	if pgconn.Timeout(err) {
+		return &kolektor.StoreError{Kind: kolektor.ErrTimeout, Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return stores.ClassifyError(err)
+	}
+
+	kind, ok := errorKinds[pgErr.Code]
+	if !ok && pgErr.Code == "57014" && strings.Contains(pgErr.Message, "statement timeout") {
+		// query_canceled is also reported when cancelled on request
+		kind, ok = kolektor.ErrTimeout, true
+	}
+	if !ok && strings.HasPrefix(pgErr.Code, "08") {
+		// class 08: connection exception
+		kind, ok = kolektor.ErrStoreUnavailable, true
+	}
+	if !ok {
+		return err
+	}
+
+	storeErr := &kolektor.StoreError{Kind: kind, Code: pgErr.Code, Err: err}
+	if kind == kolektor.ErrDuplicateKey {
+		storeErr.Index = pgErr.ConstraintName
+	}
+
+	return storeErr
+}
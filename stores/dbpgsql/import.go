@@ -0,0 +1,59 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nopgsql
+
+package dbpgsql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// ImportObject inserts the JSON document data into the model's collection
+// using meta as metadata. The ID and UID are assigned when they are not
+// set, as is the creation time, while the version and schema version are at
+// least 1. When an object with the same UID exists, onConflict defines
+// whether an error is returned, the document is skipped, or the object is
+// overwritten. It returns whether the document was stored.
+// Note that the updated timestamp of overwritten objects is set by trigger.
+func (s *Store) ImportObject(ctx context.Context, model kolektor.Modeler, meta kolektor.Meta, data []byte,
+	onConflict kolektor.ImportConflict) (bool, error) {
+
+	q := fmt.Sprintf("INSERT INTO %[1]s (id, uid, created, updated, version, deleted, schema_version, data) "+
+		"VALUES (COALESCE(NULLIF($1::bigint, 0), nextval(pg_get_serial_sequence('%[1]s', 'id'))), "+
+		"NULLIF($2, ''), COALESCE($3::timestamptz, NOW()), $4, $5, $6, $7, $8)",
+		model.CollectionName())
+
+	switch onConflict {
+	case kolektor.ImportSkip:
+		q += " ON CONFLICT (uid) DO NOTHING"
+	case kolektor.ImportOverwrite:
+		q += " ON CONFLICT (uid) DO UPDATE SET created = EXCLUDED.created, updated = EXCLUDED.updated, " +
+			"version = EXCLUDED.version, deleted = EXCLUDED.deleted, " +
+			"schema_version = EXCLUDED.schema_version, data = EXCLUDED.data"
+	}
+
+	var created any
+	if !meta.Created.IsZero() {
+		created = meta.Created
+	}
+
+	tag, err := s.db.Exec(ctx, q, meta.ID, meta.UID, created, meta.Updated,
+		max(meta.Version, 1), meta.Deleted, max(meta.SchemaVersion, 1), data)
+	if err != nil {
+		return false, fmt.Errorf("failed importing object (%w)", translateError(err))
+	}
+
+	if meta.ID != 0 {
+		// the sequence is not used when inserting IDs
+		q := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), "+
+			"GREATEST((SELECT MAX(id) FROM %[1]s), 1))", model.CollectionName())
+		if _, err := s.db.Exec(ctx, q); err != nil {
+			return false, fmt.Errorf("failed importing object (%w)", translateError(err))
+		}
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
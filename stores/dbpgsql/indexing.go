@@ -10,8 +10,8 @@ import (
 	"strings"
 
 	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
 	"github.com/golistic/xstrings"
-	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 func md5sum[T string | []byte](value T) string {
@@ -19,21 +19,55 @@ func md5sum[T string | []byte](value T) string {
 	return hex.EncodeToString(sum[:])
 }
 
-func addIndexes(conn *pgxpool.Conn, idxer kolektor.Indexer, tableName string) error {
-	haveIndexes, err := getIndexes(conn, tableName)
+// indexFieldExpression returns the expression of a field of a store-agnostic
+// index. Timestamps are indexed as text since casting them is not immutable.
+func indexFieldExpression(field kolektor.IndexField) string {
+	value, _ := jsonPath(field.Path, true)
+
+	switch field.Type {
+	case kolektor.IndexInt:
+		return "(" + value + ")::bigint"
+	case kolektor.IndexFloat:
+		return "(" + value + ")::double precision"
+	case kolektor.IndexBool:
+		return "(" + value + ")::boolean"
+	default:
+		return value
+	}
+}
+
+// addIndexes creates, recreates, or drops the indexes of the table so that
+// they match indexes. This is traced as "ReconcileIndexes" when ctx carries
+// a kolektor.Tracer.
+func addIndexes(ctx context.Context, conn querier, indexes []kolektor.Index, tableName string) (err error) {
+	ctx, span := kolektor.StartSpan(ctx, kolektor.Operation{
+		Name:       "ReconcileIndexes",
+		Collection: tableName,
+		Store:      kolektor.PgSQL.String(),
+	})
+	defer func() { span.End(err) }()
+
+	var changed int64
+
+	haveIndexes, err := getIndexes(ctx, conn, tableName)
 	if err != nil {
 		return err
 	}
 
 	var wantIndexes []string
-	for _, idx := range idxer.Indexes(kolektor.PgSQL) {
+	for _, idx := range indexes {
 		wantIndexes = append(wantIndexes, idx.Name)
 		unique := ""
 		if idx.Unique {
 			unique = "UNIQUE"
 		}
 
-		exprSum := md5sum(idx.Expression)
+		expr, err := stores.IndexExpression(idx, indexFieldExpression)
+		if err != nil {
+			return err
+		}
+
+		exprSum := md5sum(expr)
 		if haveHash, have := haveIndexes[idx.Name]; have {
 			if exprSum == haveHash {
 				// index did not change; skip
@@ -41,22 +75,24 @@ func addIndexes(conn *pgxpool.Conn, idxer kolektor.Indexer, tableName string) er
 			} else {
 				// index changed; recreate it by dropping it first
 				dml := fmt.Sprintf("DROP INDEX %s", idx.Name)
-				if _, err := conn.Exec(context.Background(), dml); err != nil {
+				if _, err := conn.Exec(ctx, dml); err != nil {
 					return fmt.Errorf("failed dropping index %s (%w)", idx.Name, err)
 				}
+				changed++
 			}
 		}
 
 		dml := fmt.Sprintf("CREATE %s INDEX CONCURRENTLY %s ON %s %s",
-			unique, idx.Name, tableName, idx.Expression)
+			unique, idx.Name, tableName, expr)
 
-		if _, err := conn.Exec(context.Background(), dml); err != nil {
+		if _, err := conn.Exec(ctx, dml); err != nil {
 			return fmt.Errorf("failed creating index %s (%w)", idx.Name, err)
 		}
+		changed++
 
 		comment := fmt.Sprintf("COMMENT ON INDEX %s IS 'kolekto#%s'", idx.Name, exprSum)
 
-		if _, err := conn.Exec(context.Background(), comment); err != nil {
+		if _, err := conn.Exec(ctx, comment); err != nil {
 			return fmt.Errorf("failed adding comment to index %s (%w)", idx.Name, err)
 		}
 	}
@@ -64,26 +100,29 @@ func addIndexes(conn *pgxpool.Conn, idxer kolektor.Indexer, tableName string) er
 	for name := range haveIndexes {
 		if xstrings.Search(wantIndexes, name) == -1 {
 			dml := fmt.Sprintf("DROP INDEX %s", name)
-			if _, err := conn.Exec(context.Background(), dml); err != nil {
+			if _, err := conn.Exec(ctx, dml); err != nil {
 				return fmt.Errorf("failed dropping index %s (%w)", name, err)
 			}
+			changed++
 		}
 	}
 
+	span.SetRows(changed)
 	return nil
 }
 
-func getIndexes(conn *pgxpool.Conn, tableName string) (map[string]string, error) {
+func getIndexes(ctx context.Context, conn querier, tableName string) (map[string]string, error) {
 	q := "SELECT indexrelname, description" +
 		" FROM pg_catalog.pg_stat_all_indexes as idx" +
 		" LEFT JOIN pg_catalog.pg_description ON idx.indexrelid = pg_description.objoid" +
 		" WHERE relname = $1 AND schemaname = \"current_schema\"() AND" +
 		" description LIKE 'kolekto#%'"
 
-	rows, err := conn.Query(context.Background(), q, tableName)
+	rows, err := conn.Query(ctx, q, tableName)
 	if err != nil {
 		return nil, fmt.Errorf("failed getting indexes (%w)", err)
 	}
+	defer rows.Close()
 
 	indexes := map[string]string{}
 
@@ -100,5 +139,9 @@ func getIndexes(conn *pgxpool.Conn, tableName string) (map[string]string, error)
 		indexes[name] = parts[1]
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed getting indexes (%w)", err)
+	}
+
 	return indexes, nil
 }
@@ -0,0 +1,100 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbpgsql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// querier is implemented by *pgxpool.Pool, *pgxpool.Conn, and pgx.Tx, and is
+// used to execute statements which must be part of a transaction, if any.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// logQuerier returns a querier executing the statements using q, and logging
+// each of them using logger. When q was returned by logQuerier before, the
+// querier it wraps around is used instead. When logger is nil, statements
+// are not logged and the (unwrapped) q is returned.
+func logQuerier(q querier, logger kolektor.StatementLogger) querier {
+	if lq, ok := q.(*loggingQuerier); ok {
+		q = lq.querier
+	}
+
+	if logger == nil {
+		return q
+	}
+
+	return &loggingQuerier{querier: q, logger: logger}
+}
+
+// loggingQuerier wraps around a querier and logs the statements it executes.
+type loggingQuerier struct {
+	querier
+	logger kolektor.StatementLogger
+}
+
+func (q *loggingQuerier) log(ctx context.Context, sql string, args []any, start time.Time, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil
+	}
+
+	q.logger.LogStatement(ctx, kolektor.Statement{
+		SQL:      sql,
+		Args:     args,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+func (q *loggingQuerier) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	start := time.Now()
+	tag, err := q.querier.Exec(ctx, sql, args...)
+	q.log(ctx, sql, args, start, err)
+	return tag, err
+}
+
+// Query logs the statement once its first result is available; the time
+// spent iterating the rows is not included.
+func (q *loggingQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	start := time.Now()
+	rows, err := q.querier.Query(ctx, sql, args...)
+	q.log(ctx, sql, args, start, err)
+	return rows, err
+}
+
+// QueryRow returns a row which logs the statement when it is scanned, since
+// pgx only executes it then.
+func (q *loggingQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &loggingRow{
+		Row:   q.querier.QueryRow(ctx, sql, args...),
+		ctx:   ctx,
+		q:     q,
+		sql:   sql,
+		args:  args,
+		start: time.Now(),
+	}
+}
+
+type loggingRow struct {
+	pgx.Row
+	ctx   context.Context
+	q     *loggingQuerier
+	sql   string
+	args  []any
+	start time.Time
+}
+
+func (r *loggingRow) Scan(dest ...any) error {
+	err := r.Row.Scan(dest...)
+	r.q.log(r.ctx, r.sql, r.args, r.start, err)
+	return err
+}
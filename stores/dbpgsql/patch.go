@@ -0,0 +1,210 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nopgsql
+
+package dbpgsql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	"github.com/jackc/pgx/v4"
+)
+
+// PatchObject applies patch to the document of the object matching filter
+// using one UPDATE statement. When filter matches multiple objects, the one
+// with the lowest ID is patched. Merge patches are applied using the jsonb
+// operators || and -, and JSON patches using jsonb_set, jsonb_insert, and #-.
+// Error stores.ErrPatch is returned when a test operation failed, or a path
+// is not available.
+func (s *Store) PatchObject(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter,
+	patch kolektor.Patch) (*kolektor.Meta, error) {
+
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	if err := stores.CheckPatch(patch); err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", err)
+	}
+
+	args := &arguments{}
+
+	var set string
+	var conds []string
+	switch p := patch.(type) {
+	case kolektor.MergePatch:
+		members, err := stores.MergePatchMembers(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed patching object (%w)", err)
+		}
+		set = mergePatchExpression(nil, members, args)
+	case kolektor.JSONPatch:
+		var err error
+		if set, conds, err = jsonPatchExpression(p, args); err != nil {
+			return nil, fmt.Errorf("failed patching object (%w)", err)
+		}
+	}
+
+	where, err := whereClause(filter, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", err)
+	}
+
+	q := fmt.Sprintf("UPDATE %[1]s SET data = %[2]s, version = version + 1 "+
+		"WHERE id = (SELECT id FROM %[1]s%[3]s ORDER BY id LIMIT 1)",
+		model.CollectionName(), set, where)
+	for _, cond := range conds {
+		q += " AND " + cond
+	}
+	q += " RETURNING " + dmlReturningMeta
+
+	meta := &kolektor.Meta{}
+	row := s.db.QueryRow(ctx, q, args.values...)
+	if err := row.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated, &meta.Version, &meta.Deleted, &meta.SchemaVersion); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed patching object (%w)", translateError(err))
+		}
+		if len(conds) > 0 {
+			exists, err := s.ObjectsExist(ctx, model, filter)
+			if err != nil {
+				return nil, fmt.Errorf("failed patching object (%w)", err)
+			}
+			if exists {
+				return nil, stores.ErrPatch{Name: model.CollectionName(), Err: stores.ErrPatchFailed}
+			}
+		}
+		return nil, stores.ErrNoObject{Name: model.CollectionName()}
+	}
+
+	return meta, nil
+}
+
+// mergePatchExpression returns the expression applying the members of a
+// merge patch to the object at path within the document. Members which are
+// null are removed, objects are merged recursively, and other values are
+// set. The object is replaced with an empty one when it is not an object.
+func mergePatchExpression(path []string, members map[string]any, args *arguments) string {
+	expr := "data"
+	if len(path) > 0 {
+		p := args.add(path) + "::text[]"
+		expr = fmt.Sprintf("(CASE WHEN jsonb_typeof(data #> %[1]s) = 'object' THEN data #> %[1]s "+
+			"ELSE '{}'::jsonb END)", p)
+	}
+
+	keys := make([]string, 0, len(members))
+	for k := range members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var removed []string
+	var nested []string
+	values := map[string]any{}
+
+	for _, k := range keys {
+		switch v := members[k].(type) {
+		case nil:
+			removed = append(removed, k)
+		case map[string]any:
+			nested = append(nested, args.add(k)+"::text, "+
+				mergePatchExpression(append(path[:len(path):len(path)], k), v, args))
+		default:
+			values[k] = v
+		}
+	}
+
+	if len(removed) > 0 {
+		expr = fmt.Sprintf("(%s - %s::text[])", expr, args.add(removed))
+	}
+
+	if len(values) > 0 {
+		b, _ := json.Marshal(values) // values were decoded from JSON
+		expr = fmt.Sprintf("(%s || %s::jsonb)", expr, args.add(string(b)))
+	}
+
+	if len(nested) > 0 {
+		expr = fmt.Sprintf("(%s || jsonb_build_object(%s))", expr, strings.Join(nested, ", "))
+	}
+
+	return expr
+}
+
+// jsonPatchExpression returns the expression applying the operations of
+// patch to the document, and the conditions which must be true for the
+// patch to be applied.
+func jsonPatchExpression(patch kolektor.JSONPatch, args *arguments) (string, []string, error) {
+	doc := "data"
+	var conds []string
+
+	for _, op := range patch {
+		path, err := stores.PointerTokens(op.Path)
+		if err != nil {
+			return "", nil, err
+		}
+
+		var value string
+		switch op.Op {
+		case kolektor.PatchAdd, kolektor.PatchReplace, kolektor.PatchTest:
+			b, err := json.Marshal(op.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			value = args.add(string(b)) + "::jsonb"
+		case kolektor.PatchMove, kolektor.PatchCopy:
+			from, err := stores.PointerTokens(op.From)
+			if err != nil {
+				return "", nil, err
+			}
+			f := args.add(from) + "::text[]"
+			conds = append(conds, fmt.Sprintf("(%s #> %s) IS NOT NULL", doc, f))
+			value = fmt.Sprintf("(%s #> %s)", doc, f)
+			if op.Op == kolektor.PatchMove {
+				doc = fmt.Sprintf("(%s #- %s)", doc, f)
+			}
+		}
+
+		switch op.Op {
+		case kolektor.PatchAdd, kolektor.PatchMove, kolektor.PatchCopy:
+			if len(path) > 1 {
+				conds = append(conds, fmt.Sprintf("(%s #> %s) IS NOT NULL", doc, args.add(path[:len(path)-1])+"::text[]"))
+			}
+			doc = addExpression(doc, path, value, args)
+		case kolektor.PatchRemove:
+			p := args.add(path) + "::text[]"
+			conds = append(conds, fmt.Sprintf("(%s #> %s) IS NOT NULL", doc, p))
+			doc = fmt.Sprintf("(%s #- %s)", doc, p)
+		case kolektor.PatchReplace:
+			p := args.add(path) + "::text[]"
+			conds = append(conds, fmt.Sprintf("(%s #> %s) IS NOT NULL", doc, p))
+			doc = fmt.Sprintf("jsonb_set(%s, %s, %s, false)", doc, p, value)
+		case kolektor.PatchTest:
+			conds = append(conds, fmt.Sprintf("(%s #> %s) = %s", doc, args.add(path)+"::text[]", value))
+		}
+	}
+
+	return doc, conds, nil
+}
+
+// addExpression returns the expression adding value at path within doc.
+// Values are inserted into arrays when the last token of path is an array
+// index or "-", which appends.
+func addExpression(doc string, path []string, value string, args *arguments) string {
+	last := path[len(path)-1]
+
+	switch {
+	case last == "-":
+		p := append(path[:len(path)-1:len(path)-1], "-1")
+		return fmt.Sprintf("jsonb_insert(%s, %s::text[], %s, true)", doc, args.add(p), value)
+	case stores.IsArrayIndex(last):
+		return fmt.Sprintf("jsonb_insert(%s, %s::text[], %s)", doc, args.add(path), value)
+	default:
+		return fmt.Sprintf("jsonb_set(%s, %s::text[], %s, true)", doc, args.add(path), value)
+	}
+}
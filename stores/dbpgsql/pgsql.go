@@ -4,17 +4,21 @@ package dbpgsql
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/golistic/kolekto/stores"
 )
 
-const dmlReturningMeta = "id, uid, created, updated"
+const dmlReturningMeta = "id, uid, created, updated, version, deleted, schema_version"
 
 const pgsqlMetaAsJson = "jsonb_build_object('Meta', jsonb_build_object(" +
 	"'id', id::numeric, " +
 	"'uid', uid, " +
 	"'created', created, " +
-	"'updated', updated))"
+	"'updated', updated, " +
+	"'version', version, " +
+	"'deleted', deleted, " +
+	"'schema_version', schema_version))"
 
 const pgsqlMergeDataMeta = "data || " + pgsqlMetaAsJson
 
@@ -42,6 +46,42 @@ id serial NOT NULL PRIMARY KEY,
 uid VARCHAR(%d) NOT NULL,
 created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
 updated TIMESTAMPTZ DEFAULT NULL,
+version BIGINT NOT NULL DEFAULT 1,
+deleted TIMESTAMPTZ DEFAULT NULL,
+schema_version INTEGER NOT NULL DEFAULT 1,
 data JSONB
 )`, name, stores.SizeUID)
 }
+
+// addedColumns are the columns added after tables of collections were
+// first created. They are added to existing tables by ddlMigrateTable.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{name: "version", definition: "BIGINT NOT NULL DEFAULT 1"},
+	{name: "deleted", definition: "TIMESTAMPTZ DEFAULT NULL"},
+	{name: "schema_version", definition: "INTEGER NOT NULL DEFAULT 1"},
+}
+
+// ddlUIDIndex returns the DDL of the unique index on the uid column, which
+// is also used by UpsertObject.
+func ddlUIDIndex(name string) string {
+	return fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS uq_%s_uid ON %s (uid)", name, name)
+}
+
+// ddlCreatedIndex returns the DDL of the index on the created and id
+// columns, which is used when paging by creation time.
+func ddlCreatedIndex(name string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS ix_%s_created ON %s (created, id)", name, name)
+}
+
+// ddlMigrateTable returns the statement adding the columns which are
+// missing from the table of a collection created by a previous version.
+func ddlMigrateTable(name string) string {
+	var adds []string
+	for _, c := range addedColumns {
+		adds = append(adds, fmt.Sprintf("ADD COLUMN IF NOT EXISTS %s %s", c.name, c.definition))
+	}
+	return "ALTER TABLE " + name + " " + strings.Join(adds, ", ")
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbpgsql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// arguments keeps the values for the positional parameters of a statement.
+type arguments struct {
+	values []any
+}
+
+// add adds v to the arguments and returns its placeholder.
+func (a *arguments) add(v any) string {
+	a.values = append(a.values, v)
+	return "$" + strconv.Itoa(len(a.values))
+}
+
+// jsonPath returns the expression extracting field from the JSON document.
+// When asText is true, the value is extracted as text.
+func jsonPath(field string, asText bool) (string, error) {
+	path, err := stores.FieldPath(field)
+	if err != nil {
+		return "", err
+	}
+
+	op := "->"
+	if asText {
+		op = "->>"
+	}
+
+	if len(path) == 1 {
+		return fmt.Sprintf("data%s'%s'", op, field), nil
+	}
+
+	return fmt.Sprintf("data#%s'{%s}'", op[1:], strings.Join(path, ",")), nil
+}
+
+// fieldExpression returns the SQL expression for field as used in
+// conditions. Fields within the JSON document are extracted as text.
+func fieldExpression(field string) (string, bool, error) {
+	if stores.IsRawExpression(field) || stores.IsReservedField(field) {
+		return field, false, nil
+	}
+
+	expr, err := jsonPath(field, true)
+	if err != nil {
+		return "", false, err
+	}
+	return expr, true, nil
+}
+
+// orderExpression returns the SQL expression for field as used when
+// sorting. Fields within the JSON document are extracted as JSONB so that,
+// for example, numbers are sorted numerically.
+func orderExpression(field string) (string, error) {
+	if stores.IsRawExpression(field) || stores.IsReservedField(field) {
+		return field, nil
+	}
+	return jsonPath(field, false)
+}
+
+// whereClause returns the WHERE-clause for filter adding the values to args.
+// An empty string is returned when filter has no conditions.
+func whereClause(filter kolektor.Filter, args *arguments) (string, error) {
+	if len(filter) == 0 {
+		return "", nil
+	}
+
+	var ands []string
+
+	for _, cond := range filter {
+		expr, isJSON, err := fieldExpression(cond.Field)
+		if err != nil {
+			return "", err
+		}
+
+		switch cond.Operator {
+		case kolektor.OpIsNull, kolektor.OpIsNotNull:
+			ands = append(ands, fmt.Sprintf("%s %s", expr, cond.Operator))
+		case kolektor.OpIn:
+			inValues, err := stores.InValues(cond.Value)
+			if err != nil {
+				return "", err
+			}
+			if len(inValues) == 0 {
+				ands = append(ands, "FALSE")
+				continue
+			}
+			if isJSON && stores.IsNumeric(inValues[0]) {
+				expr = "(" + expr + ")::numeric"
+			}
+			var placeholders []string
+			for _, v := range inValues {
+				if isJSON {
+					v = stores.JSONValue(v)
+				}
+				placeholders = append(placeholders, args.add(v))
+			}
+			ands = append(ands, fmt.Sprintf("%s IN (%s)", expr, strings.Join(placeholders, ", ")))
+		default:
+			if !cond.Operator.Valid() {
+				return "", fmt.Errorf("unsupported operator '%s'", cond.Operator)
+			}
+			value := cond.Value
+			if isJSON {
+				switch {
+				case cond.Operator == kolektor.OpLike:
+					// there is no numeric LIKE operator
+					value = stores.LikeValue(value)
+				case stores.IsNumeric(value):
+					// text extracted from JSON is compared numerically
+					expr = "(" + expr + ")::numeric"
+				}
+				value = stores.JSONValue(value)
+			}
+			ands = append(ands, fmt.Sprintf("%s %s %s", expr, cond.Operator, args.add(value)))
+		}
+	}
+
+	return " WHERE " + strings.Join(ands, " AND "), nil
+}
+
+// queryClauses returns the WHERE, ORDER BY, LIMIT and OFFSET clauses for
+// query adding the values to args.
+func queryClauses(query *kolektor.Query, args *arguments) (string, error) {
+	if query == nil {
+		return "", nil
+	}
+
+	clauses, err := whereClause(query.Filter, args)
+	if err != nil {
+		return "", err
+	}
+
+	if query.After != nil {
+		descending, err := stores.CheckKeyset(query.Order, query.After)
+		if err != nil {
+			return "", err
+		}
+
+		var fields []string
+		var placeholders []string
+		for i, o := range query.Order {
+			fields = append(fields, o.Field)
+			placeholders = append(placeholders, args.add(query.After[i]))
+		}
+
+		op := ">"
+		if descending {
+			op = "<"
+		}
+
+		keyset := fmt.Sprintf("(%s) %s (%s)", strings.Join(fields, ", "), op, strings.Join(placeholders, ", "))
+		if clauses == "" {
+			clauses = " WHERE " + keyset
+		} else {
+			clauses += " AND " + keyset
+		}
+	}
+
+	if len(query.Order) > 0 {
+		var orders []string
+		for _, o := range query.Order {
+			expr, err := orderExpression(o.Field)
+			if err != nil {
+				return "", err
+			}
+			if o.Descending {
+				expr += " DESC"
+			}
+			orders = append(orders, expr)
+		}
+		clauses += " ORDER BY " + strings.Join(orders, ", ")
+	}
+
+	if query.Limit > 0 {
+		clauses += " LIMIT " + strconv.Itoa(query.Limit)
+	}
+
+	if query.Offset > 0 {
+		clauses += " OFFSET " + strconv.Itoa(query.Offset)
+	}
+
+	return clauses, nil
+}
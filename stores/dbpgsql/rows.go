@@ -0,0 +1,84 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nopgsql
+
+package dbpgsql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/jackc/pgx/v4"
+)
+
+// rows wraps around pgx.Rows of which each row is a JSON document.
+type rows struct {
+	rows pgx.Rows
+	data []byte
+	err  error
+}
+
+var _ kolektor.Rows = &rows{}
+
+// QueryObjects retrieves the objects of the model's collection matching
+// query, and returns them as a stream of JSON documents including their
+// metadata. The underlying connection is released when the returned
+// rows are closed, exhausted, or when ctx is done.
+func (s *Store) QueryObjects(ctx context.Context, model kolektor.Modeler, query *kolektor.Query) (kolektor.Rows, error) {
+	args := &arguments{}
+	clauses, err := queryClauses(query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed querying objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT %s FROM %s%s", pgsqlMergeDataMeta, model.CollectionName(), clauses)
+
+	pgxRows, err := s.db.Query(ctx, q, args.values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed querying objects (%w)", translateError(err))
+	}
+
+	return &rows{rows: pgxRows}, nil
+}
+
+// Next prepares the next document.
+func (r *rows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+
+	var data []byte
+	if err := r.rows.Scan(&data); err != nil {
+		r.err = err
+		r.rows.Close()
+		return false
+	}
+	// the driver reuses the buffer when reading the next row
+	r.data = append([]byte(nil), data...)
+
+	return true
+}
+
+// Document returns the current document.
+func (r *rows) Document() []byte {
+	return r.data
+}
+
+// Err returns the error, if any, that was encountered while iterating.
+func (r *rows) Err() error {
+	if r.err == nil {
+		r.err = r.rows.Err()
+	}
+
+	if r.err != nil {
+		return fmt.Errorf("failed reading objects (%w)", translateError(r.err))
+	}
+	return nil
+}
+
+// Close closes the rows releasing the connection.
+func (r *rows) Close() error {
+	r.rows.Close()
+	return nil
+}
@@ -6,24 +6,27 @@ package dbpgsql
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/golistic/kolekto/kolektor"
 	"github.com/golistic/kolekto/stores"
-	"github.com/golistic/xstrings"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // Store defines the PostgreSQL backed data store.
 type Store struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool
+	db     querier
+	logger kolektor.StatementLogger
 }
 
 var _ kolektor.Storer = &Store{}
+var _ kolektor.PoolStater = &Store{}
+var _ kolektor.StatementLogging = &Store{}
 
 func init() {
 	stores.Register(kolektor.PgSQL, New)
@@ -38,13 +41,14 @@ func New(dsn string) (kolektor.Storer, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.db = s.pool
 
 	if conn, err := s.pool.Acquire(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed checking store connection (%w)", err)
+		return nil, fmt.Errorf("failed checking store connection (%w)", translateError(err))
 	} else {
 		defer func() { conn.Release() }()
 		if _, err := s.pool.Exec(context.Background(), PostgreSQLFunctions); err != nil {
-			return nil, fmt.Errorf("failed checking store connection (%w)", err)
+			return nil, fmt.Errorf("failed checking store connection (%w)", translateError(err))
 		}
 	}
 
@@ -58,10 +62,32 @@ func (s *Store) Connection(ctx context.Context) (any, error) {
 	return s.connection(ctx)
 }
 
+// PoolStats returns the statistics of the pool of connections. Connections
+// are waited for when acquiring them while the pool is empty; since pgxpool
+// only reports the total time spent acquiring connections, this is used as
+// WaitDuration.
+func (s *Store) PoolStats() kolektor.PoolStats {
+	stat := s.pool.Stat()
+	return kolektor.PoolStats{
+		MaxOpen:      int(stat.MaxConns()),
+		Open:         int(stat.TotalConns()),
+		InUse:        int(stat.AcquiredConns()),
+		Idle:         int(stat.IdleConns()),
+		WaitCount:    stat.EmptyAcquireCount(),
+		WaitDuration: stat.AcquireDuration(),
+	}
+}
+
+// SetStatementLogger logs the executed statements using logger.
+func (s *Store) SetStatementLogger(logger kolektor.StatementLogger) {
+	s.logger = logger
+	s.db = logQuerier(s.db, logger)
+}
+
 func (s *Store) connection(ctx context.Context) (*pgxpool.Conn, error) {
 	conn, err := s.pool.Acquire(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed getting store collection (%w)", err)
+		return nil, fmt.Errorf("failed getting store collection (%w)", translateError(err))
 	}
 	return conn, nil
 }
@@ -82,51 +108,38 @@ func (s *Store) Name() string {
 }
 
 // GetObject retrieves a stored object and stores it in obj.
-func (s *Store) GetObject(obj kolektor.Modeler, fieldMap kolektor.FieldMap) error {
+func (s *Store) GetObject(ctx context.Context, obj kolektor.Modeler, fieldMap kolektor.FieldMap) error {
 	if len(fieldMap) == 0 {
 		return fmt.Errorf("need at least one field to filter on")
 	}
-	var ands []string
-	var values []any
-	var c = 1
-	for name, value := range fieldMap {
-		if !(strings.HasPrefix(name, "(") || xstrings.Search(stores.ReservedFields, name) != -1) {
-			name = fmt.Sprintf("data->>'%s'", name)
-		}
-		ands = append(ands, fmt.Sprintf("%s = $%d", name, c))
-		values = append(values, value)
-		c += 1
-	}
-
-	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s",
-		pgsqlMergeDataMeta, obj.CollectionName(), strings.Join(ands, " AND "))
 
-	conn, err := s.pool.Acquire(context.Background())
+	args := &arguments{}
+	where, err := whereClause(kolektor.FilterFromFields(fieldMap), args)
 	if err != nil {
 		return fmt.Errorf("failed getting object (%w)", err)
 	}
-	if err := pgxscan.Get(context.Background(), conn, &obj, q, values...); err != nil {
-		if err == pgx.ErrNoRows {
+
+	q := fmt.Sprintf("SELECT %s FROM %s%s", pgsqlMergeDataMeta, obj.CollectionName(), where)
+
+	if err := pgxscan.Get(ctx, s.db, &obj, q, args.values...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return stores.ErrNoObject{Name: obj.CollectionName()}
 		}
-		return fmt.Errorf("failed getting object (%w)", err)
+		return fmt.Errorf("failed getting object (%w)", translateError(err))
 	}
 
-	return err
+	return nil
 }
 
 // StoreObject stores obj into the collection of the object's model.
-func (s *Store) StoreObject(obj kolektor.Modeler) (*kolektor.Meta, error) {
-	conn, err := s.pool.Acquire(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed storing object (%w)", err)
-	}
-
+// Objects which were stored before are only updated when their version
+// did not change, otherwise stores.ErrConflict is returned.
+func (s *Store) StoreObject(ctx context.Context, obj kolektor.Modeler) (*kolektor.Meta, error) {
 	objID := obj.GetID()
 	objUID := obj.GetUID()
-	obj.SetMeta(nil) // we do not save Meta in the JSON document
+	objVersion := obj.GetVersion()
 
-	data, err := json.Marshal(obj)
+	data, err := stores.MarshalObject(obj)
 	if err != nil {
 		return nil, fmt.Errorf("failed storing object (%w)", err)
 	}
@@ -134,84 +147,340 @@ func (s *Store) StoreObject(obj kolektor.Modeler) (*kolektor.Meta, error) {
 	var row pgx.Row
 
 	if objID == 0 {
-		q := fmt.Sprintf("INSERT INTO %s (data, uid) VALUES ($1, NULLIF($2, '')) "+
+		q := fmt.Sprintf("INSERT INTO %s (data, uid, schema_version) VALUES ($1, NULLIF($2, ''), $3) "+
 			"RETURNING "+dmlReturningMeta,
 			obj.CollectionName())
-		row = conn.QueryRow(context.Background(), q, data, objUID)
+		row = s.db.QueryRow(ctx, q, data, objUID, stores.SchemaVersion(obj))
 	} else {
-		q := fmt.Sprintf("UPDATE %s SET data = $1, uid = NULLIF($2, '') "+
-			"WHERE id = $3 RETURNING "+dmlReturningMeta,
+		q := fmt.Sprintf("UPDATE %s SET data = $1, uid = NULLIF($2, ''), schema_version = $5, version = version + 1 "+
+			"WHERE id = $3 AND ($4::bigint = 0 OR version = $4::bigint) RETURNING "+dmlReturningMeta,
 			obj.CollectionName())
-		row = conn.QueryRow(context.Background(), q, data, objUID, objID)
+		// only update when nobody else did since the object was retrieved
+		row = s.db.QueryRow(ctx, q, data, objUID, objID, objVersion, stores.SchemaVersion(obj))
 	}
 
 	meta := &kolektor.Meta{}
-	if err := row.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated); err != nil {
-		return nil, fmt.Errorf("failed storing object (%w)", err)
+	if err := row.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated, &meta.Version, &meta.Deleted, &meta.SchemaVersion); err != nil {
+		if objID != 0 && errors.Is(err, pgx.ErrNoRows) {
+			return nil, s.updateError(ctx, obj, objID, objVersion)
+		}
+		return nil, fmt.Errorf("failed storing object (%w)", translateError(err))
+	}
+
+	return meta, nil
+}
+
+// updateError returns the error for an update of obj which did not change
+// any row: stores.ErrNoObject when the object does not exist, otherwise
+// stores.ErrConflict since its version changed.
+func (s *Store) updateError(ctx context.Context, obj kolektor.Modeler, id, version int64) error {
+	q := "SELECT version FROM " + obj.CollectionName() + " WHERE id = $1"
+
+	var current int64
+	if err := s.db.QueryRow(ctx, q, id).Scan(&current); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return stores.ErrNoObject{Name: obj.CollectionName()}
+		}
+		return fmt.Errorf("failed storing object (%w)", translateError(err))
+	}
+
+	return stores.ErrConflict{Name: obj.CollectionName(), ID: id, Version: version}
+}
+
+// StoreObjects stores objs into the model's collection. New objects are
+// inserted using one multi-row INSERT statement, while objects which were
+// stored before are updated one by one. The returned metadata is in the
+// same order as objs. Either all objects are stored, or none, only when
+// done within a transaction.
+// The COPY protocol (pgx.CopyFrom) is not used since it cannot return rows:
+// the INSERT statement uses RETURNING to retrieve the metadata assigned by
+// PostgreSQL, such as the ID and creation time.
+func (s *Store) StoreObjects(ctx context.Context, model kolektor.Modeler, objs []kolektor.Modeler) ([]*kolektor.Meta, error) {
+	metas := make([]*kolektor.Meta, len(objs))
+	schemaVersion := stores.SchemaVersion(model)
+
+	var inserted []int
+	var uids []string
+	var rowValues []string
+	args := &arguments{}
+
+	for i, obj := range objs {
+		if obj.GetID() != 0 {
+			meta, err := s.StoreObject(ctx, obj)
+			if err != nil {
+				return nil, err
+			}
+			metas[i] = meta
+			continue
+		}
+
+		// UIDs are generated so we can match the metadata with the objects
+		uid := obj.GetUID()
+		if uid == "" {
+			var err error
+			if uid, err = stores.NewUID(); err != nil {
+				return nil, fmt.Errorf("failed storing objects (%w)", err)
+			}
+		}
+
+		data, err := stores.MarshalObject(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed storing objects (%w)", err)
+		}
+
+		inserted = append(inserted, i)
+		uids = append(uids, uid)
+		rowValues = append(rowValues, fmt.Sprintf("(%s, %s, %s)", args.add(data), args.add(uid), args.add(schemaVersion)))
+	}
+
+	if len(inserted) == 0 {
+		return metas, nil
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s (data, uid, schema_version) VALUES %s RETURNING %s",
+		model.CollectionName(), strings.Join(rowValues, ", "), dmlReturningMeta)
+
+	rows, err := s.db.Query(ctx, q, args.values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+	}
+	defer rows.Close()
+
+	queue := stores.MetaQueue{}
+	for rows.Next() {
+		meta := &kolektor.Meta{}
+		if err := rows.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated, &meta.Version, &meta.Deleted, &meta.SchemaVersion); err != nil {
+			return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+		}
+		queue.Push(meta.UID, meta)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+	}
+
+	for n, i := range inserted {
+		if metas[i] = queue.Pop(uids[n]); metas[i] == nil {
+			return nil, fmt.Errorf("failed storing objects (no metadata for %s)", uids[n])
+		}
+	}
+
+	return metas, nil
+}
+
+// UpgradeObject replaces the JSON document of the object with the given ID
+// by data, recording the schema version of model. The version of the object
+// is kept. It returns false, leaving the object untouched, when the object
+// does not exist or its version is no longer version.
+func (s *Store) UpgradeObject(ctx context.Context, model kolektor.Modeler, id, version int64, data []byte) (bool, error) {
+	q := fmt.Sprintf("UPDATE %s SET data = $1, schema_version = $2 WHERE id = $3 AND version = $4",
+		model.CollectionName())
+
+	tag, err := s.db.Exec(ctx, q, data, stores.SchemaVersion(model), id, version)
+	if err != nil {
+		return false, fmt.Errorf("failed upgrading object (%w)", translateError(err))
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteObjects removes the objects of the model's collection matching
+// filter and returns the number of removed objects.
+func (s *Store) DeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("need at least one condition to filter on")
 	}
 
-	conn.Release()
+	args := &arguments{}
+	where, err := whereClause(filter, args)
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("DELETE FROM %s%s", model.CollectionName(), where)
+
+	tag, err := s.db.Exec(ctx, q, args.values...)
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", translateError(err))
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// SoftDeleteObjects sets the deleted timestamp of the objects of the
+// model's collection matching filter which were not yet soft deleted,
+// and returns the number of soft deleted objects.
+func (s *Store) SoftDeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	return s.setDeleted(ctx, model, filter, true)
+}
+
+// RestoreObjects clears the deleted timestamp of the soft deleted objects
+// of the model's collection matching filter, and returns the number of
+// restored objects.
+func (s *Store) RestoreObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	return s.setDeleted(ctx, model, filter, false)
+}
+
+func (s *Store) setDeleted(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter, deleted bool) (int64, error) {
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	set := "NOW()"
+	op := kolektor.OpIsNull
+	if !deleted {
+		set = "NULL"
+		op = kolektor.OpIsNotNull
+	}
+
+	args := &arguments{}
+	where, err := whereClause(append(filter[:len(filter):len(filter)],
+		kolektor.Condition{Field: "deleted", Operator: op}), args)
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("UPDATE %s SET deleted = %s, version = version + 1%s", model.CollectionName(), set, where)
+
+	tag, err := s.db.Exec(ctx, q, args.values...)
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", translateError(err))
+	}
 
-	return meta, err
+	return tag.RowsAffected(), nil
 }
 
 // InitCollection initializes the model's collection.
-func (s *Store) InitCollection(model kolektor.Modeler) error {
+func (s *Store) InitCollection(ctx context.Context, model kolektor.Modeler) error {
 	tableName := model.CollectionName()
 
 	ddl := ddlTable(tableName)
 
-	conn, err := s.pool.Acquire(context.Background())
+	conn, err := s.pool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed initializing collection (%w)", err)
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
 	}
+	defer conn.Release()
+
+	db := logQuerier(conn, s.logger)
 
 	// CREATE TABLE
-	if _, err := conn.Exec(context.Background(), ddl); err != nil {
-		return fmt.Errorf("failed initializing collection (%w)", err)
+	if _, err := db.Exec(ctx, ddl); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
+	}
+
+	// add columns missing from tables created by a previous version
+	if _, err := db.Exec(ctx, ddlMigrateTable(tableName)); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
+	}
+
+	if _, err := db.Exec(ctx, ddlUIDIndex(tableName)); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
+	}
+
+	if _, err := db.Exec(ctx, ddlCreatedIndex(tableName)); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
 	}
 
 	// CREATE TRIGGERs
 	tr := fmt.Sprintf(`CREATE OR REPLACE TRIGGER tr_%s_updated
 BEFORE UPDATE ON %s FOR EACH ROW EXECUTE PROCEDURE updated_now()`,
 		tableName, tableName)
-	if _, err := conn.Exec(context.Background(), tr); err != nil {
-		return fmt.Errorf("failed initializing collection (%w)", err)
+	if _, err := db.Exec(ctx, tr); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
 	}
 
 	tr = fmt.Sprintf(`CREATE OR REPLACE TRIGGER tr_%s_uid
 BEFORE INSERT OR UPDATE ON %s FOR EACH ROW EXECUTE PROCEDURE default_uid()`,
 		tableName, tableName)
-	if _, err := conn.Exec(context.Background(), tr); err != nil {
-		return fmt.Errorf("failed initializing collection (%w)", err)
+	if _, err := db.Exec(ctx, tr); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
 	}
 
 	// INDEXING
-	if idxer, ok := model.(kolektor.Indexer); ok {
-		if err := addIndexes(conn, idxer, tableName); err != nil {
-			return err
-		}
+	indexes, err := stores.ModelIndexes(model, kolektor.PgSQL)
+	if err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", err)
+	}
+	if err := addIndexes(ctx, db, indexes, tableName); err != nil {
+		return translateError(err)
 	}
-
-	conn.Release()
 
 	return nil
 }
 
 // RemoveCollection removes the model's collection.
-func (s *Store) RemoveCollection(model kolektor.Modeler) error {
+func (s *Store) RemoveCollection(ctx context.Context, model kolektor.Modeler) error {
 	ddl := fmt.Sprintf("DROP TABLE IF EXISTS %s", model.CollectionName())
 
-	conn, err := s.pool.Acquire(context.Background())
+	if _, err := s.db.Exec(ctx, ddl); err != nil {
+		return fmt.Errorf("failed removing collection (%w)", translateError(err))
+	}
+
+	return nil
+}
+
+// ListCollections returns the names of all collections within the current
+// schema sorted by name, which are the tables having the columns of a
+// collection.
+func (s *Store) ListCollections(ctx context.Context) ([]string, error) {
+	q := "SELECT table_name FROM information_schema.columns" +
+		" WHERE table_schema = current_schema() AND column_name IN ('id', 'uid', 'data')" +
+		" GROUP BY table_name HAVING COUNT(*) = 3 ORDER BY table_name"
+
+	rows, err := s.db.Query(ctx, q)
 	if err != nil {
-		return fmt.Errorf("failed removing collection (%w)", err)
+		return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
 	}
+	defer rows.Close()
 
-	if _, err := conn.Exec(context.Background(), ddl); err != nil {
-		return fmt.Errorf("failed removing collection (%w)", err)
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
+		}
+		names = append(names, name)
 	}
 
-	conn.Release()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
+	}
 
-	return nil
+	return names, nil
+}
+
+// ListIndexes returns the indexes of the model's collection sorted by name,
+// except for the primary key. The definition of each index is its SQL.
+// Indexes are not valid when creating them concurrently failed. No indexes
+// are returned when the collection does not exist.
+func (s *Store) ListIndexes(ctx context.Context, model kolektor.Modeler) ([]kolektor.IndexStatus, error) {
+	q := "SELECT i.relname, x.indisunique, pg_get_indexdef(x.indexrelid), x.indisvalid," +
+		" COALESCE(obj_description(x.indexrelid, 'pg_class'), '') LIKE 'kolekto#%'" +
+		" FROM pg_catalog.pg_index AS x" +
+		" JOIN pg_catalog.pg_class AS i ON i.oid = x.indexrelid" +
+		" JOIN pg_catalog.pg_class AS t ON t.oid = x.indrelid" +
+		" WHERE t.relname = $1 AND t.relnamespace = current_schema()::regnamespace" +
+		" AND NOT x.indisprimary ORDER BY i.relname"
+
+	rows, err := s.db.Query(ctx, q, model.CollectionName())
+	if err != nil {
+		return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+	}
+	defer rows.Close()
+
+	var indexes []kolektor.IndexStatus
+	for rows.Next() {
+		var idx kolektor.IndexStatus
+		if err := rows.Scan(&idx.Name, &idx.Unique, &idx.Definition, &idx.Valid, &idx.Managed); err != nil {
+			return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+		}
+		indexes = append(indexes, idx)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+	}
+
+	return indexes, nil
 }
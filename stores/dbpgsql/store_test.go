@@ -5,10 +5,16 @@
 package dbpgsql
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/geertjanvdk/xkit/xt"
 	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	"github.com/jackc/pgconn"
 )
 
 type Book struct {
@@ -69,8 +75,8 @@ func TestStore_InitCollection(t *testing.T) {
 			}
 		}
 
-		xt.OK(t, store.InitCollection(book))
-		indexes, err := getIndexes(store.mustConn(), book.CollectionName())
+		xt.OK(t, store.InitCollection(context.Background(), book))
+		indexes, err := getIndexes(context.Background(), store.mustConn(), book.CollectionName())
 		xt.OK(t, err)
 		xt.Eq(t, 2, len(indexes))
 		exprSum, _ := indexes[expIndex1[0]]
@@ -94,8 +100,8 @@ func TestStore_InitCollection(t *testing.T) {
 				}
 			}
 
-			xt.OK(t, store.InitCollection(book))
-			indexes, err := getIndexes(store.mustConn(), book.CollectionName())
+			xt.OK(t, store.InitCollection(context.Background(), book))
+			indexes, err := getIndexes(context.Background(), store.mustConn(), book.CollectionName())
 			xt.OK(t, err)
 			xt.Eq(t, 1, len(indexes))
 			exprSum, _ := indexes[expIndex1[0]]
@@ -120,8 +126,8 @@ func TestStore_InitCollection(t *testing.T) {
 				}
 			}
 
-			xt.OK(t, store.InitCollection(book))
-			indexes, err := getIndexes(store.mustConn(), book.CollectionName())
+			xt.OK(t, store.InitCollection(context.Background(), book))
+			indexes, err := getIndexes(context.Background(), store.mustConn(), book.CollectionName())
 			xt.OK(t, err)
 			xt.Eq(t, 1, len(indexes))
 			exprSum, _ := indexes[expIndex1[0]]
@@ -129,3 +135,132 @@ func TestStore_InitCollection(t *testing.T) {
 		})
 	})
 }
+
+func TestStore_GetObject(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+
+	book := &Book{
+		fuCollectionName: func() string { return "books_c8d3k2x1" },
+		fuIndex:          func() map[kolektor.StoreKind][]kolektor.Index { return nil },
+	}
+	xt.OK(t, store.InitCollection(context.Background(), book))
+	book.ISBN13 = "978-0135800911"
+	_, err = store.StoreObject(context.Background(), book)
+	xt.OK(t, err)
+
+	t.Run("cancelled context aborts slow query", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := store.GetObject(ctx, book, kolektor.FieldMap{"(SELECT pg_sleep(5)::text)": ""})
+		xt.KO(t, err)
+		xt.Assert(t, time.Since(start) < 4*time.Second, "expected query to be aborted")
+		xt.Eq(t, context.DeadlineExceeded, ctx.Err())
+	})
+
+	t.Run("already cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := store.GetObject(ctx, book, kolektor.FieldMap{"isbn13": book.ISBN13})
+		xt.Assert(t, errors.Is(err, context.Canceled), "expected context.Canceled")
+	})
+}
+
+func TestStore_InitCollection_migrate(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+
+	book := &Book{
+		fuCollectionName: func() string { return "books_m1g7a2e4" },
+		fuIndex:          func() map[kolektor.StoreKind][]kolektor.Index { return nil },
+	}
+
+	// table as created before objects had versions
+	ddl := "CREATE TABLE " + book.CollectionName() + " (id serial NOT NULL PRIMARY KEY, uid VARCHAR(200) NOT NULL, created TIMESTAMPTZ NOT NULL DEFAULT NOW(), updated TIMESTAMPTZ DEFAULT NULL, data JSONB)"
+	_, err = store.mustConn().Exec(context.Background(), ddl)
+	xt.OK(t, err)
+
+	xt.OK(t, store.InitCollection(context.Background(), book))
+
+	book.ISBN13 = "978-0201835953"
+	meta, err := store.StoreObject(context.Background(), book)
+	xt.OK(t, err)
+	xt.Eq(t, int64(1), meta.Version)
+}
+
+func TestIndexFieldExpression(t *testing.T) {
+	idx := kolektor.Index{
+		Name: "ix_books_publisher_year",
+		Fields: []kolektor.IndexField{
+			{Path: "publisher.name"},
+			{Path: "year", Type: kolektor.IndexInt, Descending: true},
+			{Path: "price", Type: kolektor.IndexFloat},
+			{Path: "inStock", Type: kolektor.IndexBool},
+			{Path: "released", Type: kolektor.IndexTimestamp},
+		},
+	}
+
+	expr, err := stores.IndexExpression(idx, indexFieldExpression)
+	xt.OK(t, err)
+	xt.Eq(t, "((data#>>'{publisher,name}'), ((data->>'year')::bigint) DESC, "+
+		"((data->>'price')::double precision), ((data->>'inStock')::boolean), (data->>'released'))", expr)
+
+	_, err = stores.IndexExpression(kolektor.Index{Name: "ix_bad", Fields: []kolektor.IndexField{{Path: "a'b"}}},
+		indexFieldExpression)
+	xt.KO(t, err)
+}
+
+func TestTranslateError(t *testing.T) {
+	t.Run("unique violation", func(t *testing.T) {
+		pgErr := &pgconn.PgError{Code: "23505", ConstraintName: "uq_books_isbn13"}
+		err := translateError(fmt.Errorf("failed storing object (%w)", pgErr))
+
+		var storeErr *kolektor.StoreError
+		xt.Assert(t, errors.As(err, &storeErr))
+		xt.Assert(t, errors.Is(err, kolektor.ErrDuplicateKey))
+		xt.Eq(t, "23505", storeErr.Code)
+		xt.Eq(t, "uq_books_isbn13", storeErr.Index)
+		xt.Assert(t, errors.As(err, &pgErr))
+	})
+
+	t.Run("classified SQLSTATE codes", func(t *testing.T) {
+		cases := map[string]error{
+			"40001": kolektor.ErrConflict,
+			"40P01": kolektor.ErrConflict,
+			"55P03": kolektor.ErrTimeout,
+			"42P01": kolektor.ErrNotFound,
+			"42602": kolektor.ErrInvalidCollectionName,
+			"57P01": kolektor.ErrStoreUnavailable,
+			"08006": kolektor.ErrStoreUnavailable,
+		}
+		for code, kind := range cases {
+			xt.Assert(t, errors.Is(translateError(&pgconn.PgError{Code: code}), kind))
+		}
+	})
+
+	t.Run("cancelled queries", func(t *testing.T) {
+		xt.Assert(t, errors.Is(translateError(&pgconn.PgError{
+			Code: "57014", Message: "canceling statement due to statement timeout",
+		}), kolektor.ErrTimeout))
+
+		pgErr := &pgconn.PgError{Code: "57014", Message: "canceling statement due to user request"}
+		xt.Eq(t, error(pgErr), translateError(pgErr))
+
+		err := translateError(fmt.Errorf("failed getting object (%w)", context.Canceled))
+		xt.Assert(t, errors.Is(err, context.Canceled))
+		xt.Assert(t, !errors.Is(err, kolektor.ErrTimeout))
+	})
+
+	t.Run("other errors", func(t *testing.T) {
+		xt.Assert(t, errors.Is(translateError(context.DeadlineExceeded), kolektor.ErrTimeout))
+
+		pgErr := &pgconn.PgError{Code: "42601"}
+		xt.Eq(t, error(pgErr), translateError(pgErr))
+		xt.Assert(t, translateError(nil) == nil)
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nopgsql
+
+package dbpgsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/jackc/pgx/v4"
+)
+
+// txStore is a Store bound to a transaction.
+type txStore struct {
+	*Store
+	tx pgx.Tx
+}
+
+var _ kolektor.TxStorer = &txStore{}
+
+var isoLevels = map[sql.IsolationLevel]pgx.TxIsoLevel{
+	sql.LevelDefault:         "",
+	sql.LevelReadUncommitted: pgx.ReadUncommitted,
+	sql.LevelReadCommitted:   pgx.ReadCommitted,
+	sql.LevelRepeatableRead:  pgx.RepeatableRead,
+	sql.LevelSerializable:    pgx.Serializable,
+}
+
+// BeginTx starts a transaction and returns a store which executes all
+// statements within it. When opts is nil, the default isolation level
+// of the server is used.
+func (s *Store) BeginTx(ctx context.Context, opts *sql.TxOptions) (kolektor.TxStorer, error) {
+	var txOptions pgx.TxOptions
+	if opts != nil {
+		isoLevel, ok := isoLevels[opts.Isolation]
+		if !ok {
+			return nil, fmt.Errorf("failed starting transaction (unsupported isolation level %s)", opts.Isolation)
+		}
+		txOptions.IsoLevel = isoLevel
+		if opts.ReadOnly {
+			txOptions.AccessMode = pgx.ReadOnly
+		}
+	}
+
+	tx, err := s.pool.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed starting transaction (%w)", translateError(err))
+	}
+
+	return &txStore{
+		Store: &Store{pool: s.pool, db: logQuerier(tx, s.logger), logger: s.logger},
+		tx:    tx,
+	}, nil
+}
+
+// BeginTx returns an error since nested transactions are not supported.
+func (s *txStore) BeginTx(context.Context, *sql.TxOptions) (kolektor.TxStorer, error) {
+	return nil, fmt.Errorf("nested transactions are not supported")
+}
+
+// Connection returns the transaction of this store. The caller is
+// responsible for type asserting the result to pgx.Tx.
+func (s *txStore) Connection(context.Context) (any, error) {
+	return s.tx, nil
+}
+
+// Commit commits the transaction.
+func (s *txStore) Commit(ctx context.Context) error {
+	if err := s.tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed committing transaction (%w)", translateError(err))
+	}
+	return nil
+}
+
+// Rollback rolls back the transaction.
+func (s *txStore) Rollback(ctx context.Context) error {
+	if err := s.tx.Rollback(ctx); err != nil {
+		return fmt.Errorf("failed rolling back transaction (%w)", translateError(err))
+	}
+	return nil
+}
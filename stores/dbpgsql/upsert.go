@@ -0,0 +1,50 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nopgsql
+
+package dbpgsql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// UpsertObject inserts obj, or updates the object which has the same
+// values for keyFields, and returns the resulting metadata and whether obj
+// was inserted. The keyFields identify a unique index of the model (see
+// stores.UniqueIndex), or the uid column when empty. Updated objects are
+// restored when they were soft deleted.
+func (s *Store) UpsertObject(ctx context.Context, obj kolektor.Modeler, keyFields []string) (*kolektor.Meta, bool, error) {
+	idx, err := stores.UniqueIndex(obj, kolektor.PgSQL, keyFields)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	target, err := stores.ConflictTarget(idx, indexFieldExpression)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	objUID := obj.GetUID()
+	data, err := stores.MarshalObject(obj)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	q := fmt.Sprintf("INSERT INTO %[1]s (data, uid, schema_version) VALUES ($1, NULLIF($2, ''), $3) "+
+		"ON CONFLICT %[2]s DO UPDATE SET data = EXCLUDED.data, schema_version = EXCLUDED.schema_version, "+
+		"deleted = NULL, version = %[1]s.version + 1 RETURNING "+dmlReturningMeta,
+		obj.CollectionName(), target)
+
+	meta := &kolektor.Meta{}
+	row := s.db.QueryRow(ctx, q, data, objUID, stores.SchemaVersion(obj))
+	if err := row.Scan(&meta.ID, &meta.UID, &meta.Created, &meta.Updated, &meta.Version, &meta.Deleted, &meta.SchemaVersion); err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", translateError(err))
+	}
+
+	// updates always increment the version
+	return meta, meta.Version == 1, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// CountObjects returns the number of objects in the model's collection
+// matching filter.
+func (s *Store) CountObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	where, values, err := whereClause(filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed counting objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", model.CollectionName(), where)
+
+	var count int64
+	if err := s.db.QueryRowContext(ctx, q, values...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed counting objects (%w)", translateError(err))
+	}
+
+	return count, nil
+}
+
+// ObjectsExist returns whether at least one object in the model's
+// collection matches filter.
+func (s *Store) ObjectsExist(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (bool, error) {
+	where, values, err := whereClause(filter)
+	if err != nil {
+		return false, fmt.Errorf("failed checking objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s%s)", model.CollectionName(), where)
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, q, values...).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed checking objects (%w)", translateError(err))
+	}
+
+	return exists, nil
+}
+
+// AggregateObjects aggregates the values of the objects in the model's
+// collection as defined by agg. Only numeric values are aggregated; other
+// values are ignored, as if they were not available.
+func (s *Store) AggregateObjects(ctx context.Context, model kolektor.Modeler, agg kolektor.Aggregation) ([]kolektor.AggregateResult, error) {
+	if !agg.Func.Valid() {
+		return nil, fmt.Errorf("failed aggregating objects (unsupported function '%s')", agg.Func)
+	}
+
+	aggExpr := "COUNT(*)"
+	if agg.Field != "" {
+		if _, err := stores.FieldPath(agg.Field); err != nil {
+			return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+		}
+		// CAST would turn other values into 0
+		aggExpr = fmt.Sprintf("%[1]s(CASE WHEN json_type(data, '$.%[2]s') IN ('integer', 'real') "+
+			"THEN CAST(data->>'$.%[2]s' AS REAL) END)", agg.Func, agg.Field)
+	} else if agg.Func != kolektor.AggCount {
+		return nil, fmt.Errorf("failed aggregating objects (field required for %s)", agg.Func)
+	}
+
+	groupExpr := "NULL"
+	if agg.GroupBy != "" {
+		if _, err := stores.FieldPath(agg.GroupBy); err != nil {
+			return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+		}
+		// booleans are grouped as 'true' or 'false' instead of 1 or 0
+		groupExpr = fmt.Sprintf("CASE json_type(data, '$.%[1]s') WHEN 'true' THEN 'true' "+
+			"WHEN 'false' THEN 'false' ELSE CAST(data->>'$.%[1]s' AS TEXT) END", agg.GroupBy)
+	}
+
+	where, values, err := whereClause(agg.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed aggregating objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT %s AS grp, %s FROM %s%s", groupExpr, aggExpr, model.CollectionName(), where)
+	if agg.GroupBy != "" {
+		q += " GROUP BY grp ORDER BY grp"
+	}
+
+	rows, err := s.db.QueryContext(ctx, q, values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed aggregating objects (%w)", translateError(err))
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []kolektor.AggregateResult
+	for rows.Next() {
+		r := kolektor.AggregateResult{}
+		var group sql.NullString
+		var value sql.NullFloat64
+		var dest any = &value
+		if agg.Func == kolektor.AggCount {
+			dest = &r.Count
+		}
+		if err := rows.Scan(&group, dest); err != nil {
+			return nil, fmt.Errorf("failed aggregating objects (%w)", translateError(err))
+		}
+
+		if group.Valid {
+			r.Group = &group.String
+		}
+		if value.Valid {
+			r.Value = &value.Float64
+		}
+		result = append(result, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed aggregating objects (%w)", translateError(err))
+	}
+
+	return result, nil
+}
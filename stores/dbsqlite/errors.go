@@ -0,0 +1,58 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// reUniqueIndex extracts the name of the index from the message of the
+// error of a violated unique index on expressions. When the unique index
+// is on columns, SQLite reports the columns instead.
+var reUniqueIndex = regexp.MustCompile(`UNIQUE constraint failed: index '([^']+)'`)
+
+// translateError returns err as *kolektor.StoreError when the SQLite error
+// code, or the error itself, is classified by kolektor. Otherwise, err is
+// returned as is, which is also the case when err was already classified.
+func translateError(err error) error {
+	var classified *kolektor.StoreError
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return stores.ClassifyError(err)
+	}
+
+	storeErr := &kolektor.StoreError{Code: strconv.Itoa(sqliteErr.Code()), Err: err}
+
+	switch code := sqliteErr.Code(); {
+	case code == sqlite3.SQLITE_CONSTRAINT_UNIQUE, code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		storeErr.Kind = kolektor.ErrDuplicateKey
+		if m := reUniqueIndex.FindStringSubmatch(sqliteErr.Error()); m != nil {
+			storeErr.Index = m[1]
+		}
+	case code&0xff == sqlite3.SQLITE_BUSY, code&0xff == sqlite3.SQLITE_LOCKED:
+		// busy_timeout elapsed
+		storeErr.Kind = kolektor.ErrTimeout
+	case code&0xff == sqlite3.SQLITE_CANTOPEN:
+		storeErr.Kind = kolektor.ErrStoreUnavailable
+	case code == sqlite3.SQLITE_ERROR && strings.Contains(sqliteErr.Error(), "no such table"):
+		storeErr.Kind = kolektor.ErrNotFound
+	default:
+		return err
+	}
+
+	return storeErr
+}
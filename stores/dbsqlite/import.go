@@ -0,0 +1,63 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// ImportObject inserts the JSON document data into the model's collection
+// using meta as metadata. The ID and UID are assigned when they are not
+// set, as is the creation time, while the version and schema version are at
+// least 1. When an object with the same UID exists, onConflict defines
+// whether an error is returned, the document is skipped, or the object is
+// overwritten. It returns whether the document was stored.
+func (s *Store) ImportObject(ctx context.Context, model kolektor.Modeler, meta kolektor.Meta, data []byte,
+	onConflict kolektor.ImportConflict) (bool, error) {
+
+	if meta.UID == "" {
+		// the trigger assigning UIDs runs after the conflict was checked
+		var err error
+		if meta.UID, err = stores.NewUID(); err != nil {
+			return false, fmt.Errorf("failed importing object (%w)", err)
+		}
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s (id, uid, created, updated, version, deleted, schema_version, data) "+
+		"VALUES (NULLIF(?, 0), ?, COALESCE(?, %s), ?, ?, ?, ?, json(?))",
+		model.CollectionName(), sqliteTimestamp)
+
+	switch onConflict {
+	case kolektor.ImportSkip:
+		q += " ON CONFLICT (uid) DO NOTHING"
+	case kolektor.ImportOverwrite:
+		q += " ON CONFLICT (uid) DO UPDATE SET created = excluded.created, updated = excluded.updated, " +
+			"version = excluded.version, deleted = excluded.deleted, " +
+			"schema_version = excluded.schema_version, data = excluded.data"
+	}
+
+	var created any
+	if !meta.Created.IsZero() {
+		created = meta.Created
+	}
+
+	res, err := s.db.ExecContext(ctx, q, meta.ID, meta.UID,
+		sqlValue("created", false, created), sqlValue("updated", false, meta.Updated), max(meta.Version, 1),
+		sqlValue("deleted", false, meta.Deleted), max(meta.SchemaVersion, 1), string(data))
+	if err != nil {
+		return false, fmt.Errorf("failed importing object (%w)", translateError(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed importing object (%w)", translateError(err))
+	}
+
+	return n > 0, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbsqlite
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	"github.com/golistic/xstrings"
+)
+
+// reIndexComment matches the comment added to the DDL of indexes, which
+// SQLite keeps as part of the schema.
+var reIndexComment = regexp.MustCompile(`/\* kolekto#([0-9a-f]{32}) \*/`)
+
+func md5sum[T string | []byte](value T) string {
+	sum := md5.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// indexFieldExpression returns the expression of a field of a store-agnostic
+// index. The type is not needed since SQLite extracts values as SQL values
+// of the matching type.
+func indexFieldExpression(field kolektor.IndexField) string {
+	return "data->>'$." + field.Path + "'"
+}
+
+// addIndexes creates, recreates, or drops the indexes of the table so that
+// they match indexes. This is traced as "ReconcileIndexes" when ctx carries
+// a kolektor.Tracer.
+func addIndexes(ctx context.Context, conn stores.SQLQuerier, indexes []kolektor.Index, tableName string) (err error) {
+	ctx, span := kolektor.StartSpan(ctx, kolektor.Operation{
+		Name:       "ReconcileIndexes",
+		Collection: tableName,
+		Store:      kolektor.SQLite.String(),
+	})
+	defer func() { span.End(err) }()
+
+	var ddls []string
+
+	haveIndexes, err := getIndexes(ctx, conn, tableName)
+	if err != nil {
+		return err
+	}
+
+	var wantIndexes []string
+	for _, idx := range indexes {
+		wantIndexes = append(wantIndexes, idx.Name)
+		unique := ""
+		if idx.Unique {
+			unique = "UNIQUE "
+		}
+
+		expr, err := stores.IndexExpression(idx, indexFieldExpression)
+		if err != nil {
+			return err
+		}
+
+		exprSum := md5sum(expr)
+		if haveHash, have := haveIndexes[idx.Name]; have {
+			if exprSum == haveHash {
+				// index did not change; skip
+				continue
+			} else {
+				// index changed; recreate it by dropping it first
+				ddls = append(ddls, fmt.Sprintf("DROP INDEX %s", idx.Name))
+			}
+		}
+
+		ddls = append(ddls, fmt.Sprintf("CREATE %sINDEX %s /* kolekto#%s */ ON %s %s",
+			unique, idx.Name, exprSum, tableName, expr))
+	}
+
+	for name := range haveIndexes {
+		if xstrings.Search(wantIndexes, name) == -1 {
+			ddls = append(ddls, fmt.Sprintf("DROP INDEX %s", name))
+		}
+	}
+
+	for _, ddl := range ddls {
+		if _, err := conn.ExecContext(ctx, ddl); err != nil {
+			return fmt.Errorf("failed creating indexes for %s (%w)", tableName, err)
+		}
+	}
+
+	// each statement creates or drops one index
+	span.SetRows(int64(len(ddls)))
+	return nil
+}
+
+func getIndexes(ctx context.Context, conn stores.SQLQuerier, tableName string) (map[string]string, error) {
+	q := "SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql LIKE '%kolekto#%'"
+
+	rows, err := conn.QueryContext(ctx, q, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting indexes (%w)", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	indexes := map[string]string{}
+
+	for rows.Next() {
+		var name string
+		var ddl string
+		if err := rows.Scan(&name, &ddl); err != nil {
+			return nil, fmt.Errorf("failed getting indexes (%w)", err)
+		}
+		m := reIndexComment.FindStringSubmatch(ddl)
+		if m == nil {
+			return nil, fmt.Errorf("failed getting indexes (bad index comment)")
+		}
+		indexes[name] = m[1]
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed getting indexes (%w)", err)
+	}
+
+	return indexes, nil
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golistic/kolekto/internal/ytest"
+)
+
+var (
+	testExitCode int
+	testErr      error
+	testDSN      string
+)
+
+func testTearDown() {
+	if testErr != nil {
+		fmt.Println(testErr)
+		os.Exit(1)
+	}
+}
+
+func TestMain(m *testing.M) {
+	defer func() { os.Exit(testExitCode) }()
+	defer testTearDown()
+
+	if v, have := os.LookupEnv("TEST_SQLITE_DSN"); have {
+		testDSN = v
+	} else {
+		testDSN = filepath.Join(os.TempDir(), "kolekto_test_store.db")
+	}
+
+	testDSN, testErr = ytest.PrepareSQLite(testDSN)
+	if testErr != nil {
+		return
+	}
+
+	testExitCode = m.Run()
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// maxPatchAttempts is the number of times a JSON patch is applied to an
+// object which keeps being changed concurrently.
+const maxPatchAttempts = 10
+
+// PatchObject applies patch to the document of the object matching filter.
+// When filter matches multiple objects, the one with the lowest ID is
+// patched. Merge patches are applied using json_patch. SQLite cannot insert
+// into arrays, therefore JSON patches are applied to the retrieved document,
+// which is stored when its version did not change meanwhile. Patching is
+// retried at most maxPatchAttempts times, after which stores.ErrConflict is
+// returned.
+func (s *Store) PatchObject(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter,
+	patch kolektor.Patch) (*kolektor.Meta, error) {
+
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	if err := stores.CheckPatch(patch); err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", err)
+	}
+
+	where, values, err := whereClause(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed patching object (%w)", err)
+	}
+
+	if p, ok := patch.(kolektor.MergePatch); ok {
+		q := fmt.Sprintf("UPDATE %[1]s SET data = json_patch(data, json(?)), updated = %[2]s, "+
+			"version = version + 1 WHERE id = (SELECT id FROM %[1]s%[3]s ORDER BY id LIMIT 1) RETURNING %[4]s",
+			model.CollectionName(), sqliteTimestamp, where, dmlReturningMeta)
+
+		meta, err := scanMeta(s.db.QueryRowContext(ctx, q, append([]any{string(p)}, values...)...))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, stores.ErrNoObject{Name: model.CollectionName()}
+			}
+			return nil, fmt.Errorf("failed patching object (%w)", translateError(err))
+		}
+		return meta, nil
+	}
+
+	qSelect := fmt.Sprintf("SELECT id, version, data FROM %s%s ORDER BY id LIMIT 1", model.CollectionName(), where)
+	qUpdate := fmt.Sprintf("UPDATE %s SET data = json(?), updated = %s, version = version + 1 "+
+		"WHERE id = ? AND version = ? RETURNING %s", model.CollectionName(), sqliteTimestamp, dmlReturningMeta)
+
+	var id, version int64
+	for attempt := 0; attempt < maxPatchAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed patching object (%w)", err)
+		}
+
+		var data string
+		if err := s.db.QueryRowContext(ctx, qSelect, values...).Scan(&id, &version, &data); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, stores.ErrNoObject{Name: model.CollectionName()}
+			}
+			return nil, fmt.Errorf("failed patching object (%w)", translateError(err))
+		}
+
+		patched, err := stores.ApplyPatch([]byte(data), patch)
+		if err != nil {
+			return nil, stores.ErrPatch{Name: model.CollectionName(), Err: err}
+		}
+
+		meta, err := scanMeta(s.db.QueryRowContext(ctx, qUpdate, string(patched), id, version))
+		switch {
+		case err == sql.ErrNoRows:
+			continue // changed since retrieved; patch again
+		case err != nil:
+			return nil, fmt.Errorf("failed patching object (%w)", translateError(err))
+		}
+		return meta, nil
+	}
+
+	return nil, stores.ErrConflict{Name: model.CollectionName(), ID: id, Version: version}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbsqlite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// fieldExpression returns the SQL expression for field as used in
+// conditions and when sorting. Fields within the JSON document are
+// extracted as SQL values, so that, for example, numbers are compared
+// and sorted numerically.
+func fieldExpression(field string) (string, bool, error) {
+	if stores.IsRawExpression(field) || stores.IsReservedField(field) {
+		return field, false, nil
+	}
+
+	if _, err := stores.FieldPath(field); err != nil {
+		return "", false, err
+	}
+
+	return "data->>'$." + field + "'", true, nil
+}
+
+// sqlValue converts value so that it can be compared with the value of
+// field. SQLite extracts JSON booleans as integers, and timestamps are
+// stored as text.
+func sqlValue(field string, isJSON bool, value any) any {
+	switch v := value.(type) {
+	case bool:
+		if !isJSON {
+			return v
+		}
+		if v {
+			return 1
+		}
+		return 0
+	case time.Time:
+		if field == "created" || field == "updated" || field == "deleted" {
+			return v.UTC().Format(timeLayout)
+		}
+		return stores.JSONValue(v)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return sqlValue(field, isJSON, *v)
+	default:
+		return value
+	}
+}
+
+// whereClause returns the WHERE-clause including its values for filter.
+// An empty string is returned when filter has no conditions.
+func whereClause(filter kolektor.Filter) (string, []any, error) {
+	if len(filter) == 0 {
+		return "", nil, nil
+	}
+
+	var ands []string
+	var values []any
+
+	for _, cond := range filter {
+		expr, isJSON, err := fieldExpression(cond.Field)
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch cond.Operator {
+		case kolektor.OpIsNull, kolektor.OpIsNotNull:
+			ands = append(ands, fmt.Sprintf("%s %s", expr, cond.Operator))
+		case kolektor.OpIn:
+			inValues, err := stores.InValues(cond.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			if len(inValues) == 0 {
+				ands = append(ands, "FALSE")
+				continue
+			}
+			for _, v := range inValues {
+				values = append(values, sqlValue(cond.Field, isJSON, v))
+			}
+			ands = append(ands, fmt.Sprintf("%s IN (%s)",
+				expr, strings.TrimSuffix(strings.Repeat("?, ", len(inValues)), ", ")))
+		default:
+			if !cond.Operator.Valid() {
+				return "", nil, fmt.Errorf("unsupported operator '%s'", cond.Operator)
+			}
+			ands = append(ands, fmt.Sprintf("%s %s ?", expr, cond.Operator))
+			values = append(values, sqlValue(cond.Field, isJSON, cond.Value))
+		}
+	}
+
+	return " WHERE " + strings.Join(ands, " AND "), values, nil
+}
+
+// queryClauses returns the WHERE, ORDER BY, and LIMIT clauses including
+// the values for query.
+func queryClauses(query *kolektor.Query) (string, []any, error) {
+	if query == nil {
+		return "", nil, nil
+	}
+
+	clauses, values, err := whereClause(query.Filter)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if query.After != nil {
+		descending, err := stores.CheckKeyset(query.Order, query.After)
+		if err != nil {
+			return "", nil, err
+		}
+
+		var fields []string
+		for i, o := range query.Order {
+			fields = append(fields, o.Field)
+			values = append(values, sqlValue(o.Field, false, query.After[i]))
+		}
+
+		op := ">"
+		if descending {
+			op = "<"
+		}
+
+		keyset := fmt.Sprintf("(%s) %s (%s)", strings.Join(fields, ", "), op,
+			strings.TrimSuffix(strings.Repeat("?, ", len(fields)), ", "))
+		if clauses == "" {
+			clauses = " WHERE " + keyset
+		} else {
+			clauses += " AND " + keyset
+		}
+	}
+
+	if len(query.Order) > 0 {
+		var orders []string
+		for _, o := range query.Order {
+			expr, _, err := fieldExpression(o.Field)
+			if err != nil {
+				return "", nil, err
+			}
+			if o.Descending {
+				expr += " DESC"
+			}
+			orders = append(orders, expr)
+		}
+		clauses += " ORDER BY " + strings.Join(orders, ", ")
+	}
+
+	switch {
+	case query.Limit > 0:
+		clauses += " LIMIT " + strconv.Itoa(query.Limit)
+	case query.Offset > 0:
+		// SQLite does not support OFFSET without LIMIT
+		clauses += " LIMIT -1"
+	}
+
+	if query.Offset > 0 {
+		clauses += " OFFSET " + strconv.Itoa(query.Offset)
+	}
+
+	return clauses, values, nil
+}
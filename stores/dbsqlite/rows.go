@@ -0,0 +1,81 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// rows wraps around *sql.Rows of which each row is a JSON document.
+type rows struct {
+	rows *sql.Rows
+	data []byte
+	err  error
+}
+
+var _ kolektor.Rows = &rows{}
+
+// QueryObjects retrieves the objects of the model's collection matching
+// query, and returns them as a stream of JSON documents including their
+// metadata. The underlying connection is released when the returned
+// rows are closed, exhausted, or when ctx is done.
+func (s *Store) QueryObjects(ctx context.Context, model kolektor.Modeler, query *kolektor.Query) (kolektor.Rows, error) {
+	clauses, values, err := queryClauses(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed querying objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT %s FROM %s%s", sqliteMergeDataMeta, model.CollectionName(), clauses)
+
+	sqlRows, err := s.db.QueryContext(ctx, q, values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed querying objects (%w)", translateError(err))
+	}
+
+	return &rows{rows: sqlRows}, nil
+}
+
+// Next prepares the next document.
+func (r *rows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+
+	var data string
+	if err := r.rows.Scan(&data); err != nil {
+		r.err = err
+		_ = r.rows.Close()
+		return false
+	}
+	r.data = []byte(data)
+
+	return true
+}
+
+// Document returns the current document.
+func (r *rows) Document() []byte {
+	return r.data
+}
+
+// Err returns the error, if any, that was encountered while iterating.
+func (r *rows) Err() error {
+	if r.err == nil {
+		r.err = r.rows.Err()
+	}
+
+	if r.err != nil {
+		return fmt.Errorf("failed reading objects (%w)", translateError(r.err))
+	}
+	return nil
+}
+
+// Close closes the rows releasing the connection.
+func (r *rows) Close() error {
+	return r.rows.Close()
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package dbsqlite
+
+import (
+	"fmt"
+
+	"github.com/golistic/kolekto/stores"
+)
+
+const dmlReturningMeta = "id, uid, created, updated, version, deleted, schema_version"
+
+// sqliteTimestamp is the SQL expression of the current time, formatted
+// like timeLayout.
+const sqliteTimestamp = "strftime('%Y-%m-%dT%H:%M:%fZ', 'now')"
+
+// timeLayout is how timestamps are stored as text.
+const timeLayout = "2006-01-02T15:04:05.000Z"
+
+const sqliteMetaAsJson = "json_object(" +
+	"'id', id, " +
+	"'uid', uid, " +
+	"'created', created, " +
+	"'updated', updated, " +
+	"'version', version, " +
+	"'deleted', deleted, " +
+	"'schema_version', schema_version)"
+
+const sqliteMergeDataMeta = "json_set(data, '$.Meta', " + sqliteMetaAsJson + ")"
+
+// sqliteUUID is the SQL expression generating a random (version 4) UUID.
+const sqliteUUID = "lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || " +
+	"substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || " +
+	"substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))"
+
+func ddlTable(name string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+uid VARCHAR(%d) NOT NULL DEFAULT '',
+created TEXT NOT NULL DEFAULT (%s),
+updated TEXT DEFAULT NULL,
+version INTEGER NOT NULL DEFAULT 1,
+deleted TEXT DEFAULT NULL,
+schema_version INTEGER NOT NULL DEFAULT 1,
+data JSON
+)`, name, stores.SizeUID, sqliteTimestamp)
+}
+
+// addedColumns are the columns added after tables of collections were
+// first created. They are added to existing tables by migrateTable.
+var addedColumns = []struct {
+	name       string
+	definition string
+}{
+	{name: "version", definition: "INTEGER NOT NULL DEFAULT 1"},
+	{name: "deleted", definition: "TEXT DEFAULT NULL"},
+	{name: "schema_version", definition: "INTEGER NOT NULL DEFAULT 1"},
+}
+
+// ddlUIDIndex returns the DDL of the unique index on the uid column, which
+// is also used by UpsertObject.
+func ddlUIDIndex(name string) string {
+	return fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS uq_%s_uid ON %s (uid)", name, name)
+}
+
+// ddlCreatedIndex returns the DDL of the index on the created and id
+// columns, which is used when paging by creation time.
+func ddlCreatedIndex(name string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS ix_%s_created ON %s (created, id)", name, name)
+}
+
+func ddlTriggers(name string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS tr_%s_uid
+AFTER INSERT ON %s FOR EACH ROW WHEN NEW.uid = ''
+BEGIN
+	UPDATE %s SET uid = %s WHERE id = NEW.id;
+END`, name, name, name, sqliteUUID),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS tr_%s_updated
+AFTER UPDATE ON %s FOR EACH ROW WHEN NEW.updated IS OLD.updated
+BEGIN
+	UPDATE %s SET updated = %s WHERE id = NEW.id;
+END`, name, name, name, sqliteTimestamp),
+	}
+}
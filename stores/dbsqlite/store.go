@@ -0,0 +1,583 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	_ "modernc.org/sqlite"
+)
+
+// Store defines the SQLite backed data store.
+type Store struct {
+	pool *sql.DB
+	// db is used to execute statements which must be part of a
+	// transaction, if any.
+	db     stores.SQLQuerier
+	logger kolektor.StatementLogger
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+var _ kolektor.Storer = &Store{}
+var _ kolektor.PoolStater = &Store{}
+var _ kolektor.StatementLogging = &Store{}
+
+func init() {
+	stores.Register(kolektor.SQLite, New)
+}
+
+// New instantiates a SQLite backed data store. The dsn is the path to the
+// database file, optionally using the file: URI scheme. In-memory databases
+// are only shared using a single connection.
+func New(dsn string) (kolektor.Storer, error) {
+	var err error
+
+	if !strings.Contains(dsn, "busy_timeout") {
+		// wait for locks instead of failing immediately
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_pragma=busy_timeout(5000)"
+	}
+
+	s := &Store{}
+	s.pool, err = sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed checking store connection (%w)", translateError(err))
+	}
+
+	if strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory") {
+		// each connection would otherwise get its own database
+		s.pool.SetMaxOpenConns(1)
+	}
+
+	s.db = s.pool
+
+	if err := s.pool.PingContext(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed checking store connection (%w)", translateError(err))
+	}
+
+	return s, nil
+}
+
+// Connection returns a connection to the store. The caller is responsible
+// for type asserting the result to the appropriated type for this store,
+// namely *sql.Conn.
+func (s *Store) Connection(ctx context.Context) (any, error) {
+	return s.connection(ctx)
+}
+
+// PoolStats returns the statistics of the pool of connections.
+func (s *Store) PoolStats() kolektor.PoolStats {
+	stats := s.pool.Stats()
+	return kolektor.PoolStats{
+		MaxOpen:      stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration,
+	}
+}
+
+// SetStatementLogger logs the executed statements using logger.
+func (s *Store) SetStatementLogger(logger kolektor.StatementLogger) {
+	s.logger = logger
+	s.db = stores.LogSQLQuerier(s.db, logger)
+}
+
+func (s *Store) connection(ctx context.Context) (*sql.Conn, error) {
+	conn, err := s.pool.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting store collection (%w)", translateError(err))
+	}
+	return conn, nil
+}
+
+// mustSQLConn is mainly for testing.
+// Panics on errors.
+func (s *Store) mustSQLConn() *sql.Conn {
+	conn, err := s.connection(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	return conn
+}
+
+// Name returns the name of the data store.
+func (s *Store) Name() string {
+	return "SQLite"
+}
+
+// GetObject retrieves a stored object and stores it in obj.
+func (s *Store) GetObject(ctx context.Context, obj kolektor.Modeler, fieldMap kolektor.FieldMap) error {
+	if len(fieldMap) == 0 {
+		return fmt.Errorf("need at least one field to filter on")
+	}
+
+	where, values, err := whereClause(kolektor.FilterFromFields(fieldMap))
+	if err != nil {
+		return fmt.Errorf("failed getting object (%w)", err)
+	}
+
+	q := fmt.Sprintf("SELECT %s FROM %s%s", sqliteMergeDataMeta, obj.CollectionName(), where)
+
+	var data string
+	if err := s.db.QueryRowContext(ctx, q, values...).Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return stores.ErrNoObject{Name: obj.CollectionName()}
+		}
+		return fmt.Errorf("failed getting object (%w)", translateError(err))
+	}
+
+	if err := json.Unmarshal([]byte(data), obj); err != nil {
+		return fmt.Errorf("failed getting object (%w)", err)
+	}
+
+	return nil
+}
+
+// StoreObject stores obj into the collection of the object's model.
+// Objects which were stored before are only updated when their version
+// did not change, otherwise stores.ErrConflict is returned.
+func (s *Store) StoreObject(ctx context.Context, obj kolektor.Modeler) (*kolektor.Meta, error) {
+	objID := obj.GetID()
+	objUID := obj.GetUID()
+	objVersion := obj.GetVersion()
+
+	if objUID == "" {
+		// triggers are not reflected by RETURNING
+		var err error
+		if objUID, err = stores.NewUID(); err != nil {
+			return nil, fmt.Errorf("failed storing object (%w)", err)
+		}
+	}
+
+	data, err := stores.MarshalObject(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed storing object (%w)", err)
+	}
+
+	var row *sql.Row
+	if objID == 0 {
+		q := fmt.Sprintf("INSERT INTO %s (data, uid, schema_version) VALUES (json(?), ?, ?) RETURNING %s",
+			obj.CollectionName(), dmlReturningMeta)
+		row = s.db.QueryRowContext(ctx, q, string(data), objUID, stores.SchemaVersion(obj))
+	} else {
+		q := fmt.Sprintf("UPDATE %s SET data = json(?), uid = ?, schema_version = ?, updated = %s, "+
+			"version = version + 1 WHERE id = ? AND (? = 0 OR version = ?) RETURNING %s",
+			obj.CollectionName(), sqliteTimestamp, dmlReturningMeta)
+		// only update when nobody else did since the object was retrieved
+		row = s.db.QueryRowContext(ctx, q, string(data), objUID, stores.SchemaVersion(obj),
+			objID, objVersion, objVersion)
+	}
+
+	meta, err := scanMeta(row)
+	if err != nil {
+		if objID != 0 && err == sql.ErrNoRows {
+			return nil, s.updateError(ctx, obj, objID, objVersion)
+		}
+		return nil, fmt.Errorf("failed storing object (%w)", translateError(err))
+	}
+
+	return meta, nil
+}
+
+// updateError returns the error for an update of obj which did not change
+// any row: stores.ErrNoObject when the object does not exist, otherwise
+// stores.ErrConflict since its version changed.
+func (s *Store) updateError(ctx context.Context, obj kolektor.Modeler, id, version int64) error {
+	q := "SELECT version FROM " + obj.CollectionName() + " WHERE id = ?"
+
+	var current int64
+	if err := s.db.QueryRowContext(ctx, q, id).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return stores.ErrNoObject{Name: obj.CollectionName()}
+		}
+		return fmt.Errorf("failed storing object (%w)", translateError(err))
+	}
+
+	return stores.ErrConflict{Name: obj.CollectionName(), ID: id, Version: version}
+}
+
+// StoreObjects stores objs into the model's collection. New objects are
+// inserted using one multi-row INSERT statement, while objects which were
+// stored before are updated one by one. The returned metadata is in the
+// same order as objs. Either all objects are stored, or none, only when
+// done within a transaction.
+func (s *Store) StoreObjects(ctx context.Context, model kolektor.Modeler, objs []kolektor.Modeler) ([]*kolektor.Meta, error) {
+	metas := make([]*kolektor.Meta, len(objs))
+	schemaVersion := stores.SchemaVersion(model)
+
+	var inserted []int
+	var uids []string
+	var values []any
+
+	for i, obj := range objs {
+		if obj.GetID() != 0 {
+			meta, err := s.StoreObject(ctx, obj)
+			if err != nil {
+				return nil, err
+			}
+			metas[i] = meta
+			continue
+		}
+
+		// UIDs are generated so we can match the metadata with the objects
+		uid := obj.GetUID()
+		if uid == "" {
+			var err error
+			if uid, err = stores.NewUID(); err != nil {
+				return nil, fmt.Errorf("failed storing objects (%w)", err)
+			}
+		}
+
+		data, err := stores.MarshalObject(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed storing objects (%w)", err)
+		}
+
+		inserted = append(inserted, i)
+		uids = append(uids, uid)
+		values = append(values, string(data), uid, schemaVersion)
+	}
+
+	if len(inserted) == 0 {
+		return metas, nil
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s (data, uid, schema_version) VALUES %s RETURNING %s", model.CollectionName(),
+		strings.TrimSuffix(strings.Repeat("(json(?), ?, ?), ", len(inserted)), ", "), dmlReturningMeta)
+
+	rows, err := s.db.QueryContext(ctx, q, values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+	}
+	defer func() { _ = rows.Close() }()
+
+	queue := stores.MetaQueue{}
+	for rows.Next() {
+		meta, err := scanMeta(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+		}
+		queue.Push(meta.UID, meta)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed storing objects (%w)", translateError(err))
+	}
+
+	for n, i := range inserted {
+		if metas[i] = queue.Pop(uids[n]); metas[i] == nil {
+			return nil, fmt.Errorf("failed storing objects (no metadata for %s)", uids[n])
+		}
+	}
+
+	return metas, nil
+}
+
+// UpgradeObject replaces the JSON document of the object with the given ID
+// by data, recording the schema version of model. The version of the object
+// is kept. It returns false, leaving the object untouched, when the object
+// does not exist or its version is no longer version.
+func (s *Store) UpgradeObject(ctx context.Context, model kolektor.Modeler, id, version int64, data []byte) (bool, error) {
+	q := fmt.Sprintf("UPDATE %s SET data = json(?), schema_version = ?, updated = %s WHERE id = ? AND version = ?",
+		model.CollectionName(), sqliteTimestamp)
+
+	res, err := s.db.ExecContext(ctx, q, string(data), stores.SchemaVersion(model), id, version)
+	if err != nil {
+		return false, fmt.Errorf("failed upgrading object (%w)", translateError(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed upgrading object (%w)", translateError(err))
+	}
+
+	return n > 0, nil
+}
+
+// DeleteObjects removes the objects of the model's collection matching
+// filter and returns the number of removed objects.
+func (s *Store) DeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	where, values, err := whereClause(filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("DELETE FROM %s%s", model.CollectionName(), where)
+
+	res, err := s.db.ExecContext(ctx, q, values...)
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", translateError(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed deleting objects (%w)", translateError(err))
+	}
+
+	return n, nil
+}
+
+// SoftDeleteObjects sets the deleted timestamp of the objects of the
+// model's collection matching filter which were not yet soft deleted,
+// and returns the number of soft deleted objects.
+func (s *Store) SoftDeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	return s.setDeleted(ctx, model, filter, true)
+}
+
+// RestoreObjects clears the deleted timestamp of the soft deleted objects
+// of the model's collection matching filter, and returns the number of
+// restored objects.
+func (s *Store) RestoreObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	return s.setDeleted(ctx, model, filter, false)
+}
+
+func (s *Store) setDeleted(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter, deleted bool) (int64, error) {
+	if len(filter) == 0 {
+		return 0, fmt.Errorf("need at least one condition to filter on")
+	}
+
+	set := sqliteTimestamp
+	op := kolektor.OpIsNull
+	if !deleted {
+		set = "NULL"
+		op = kolektor.OpIsNotNull
+	}
+
+	where, values, err := whereClause(append(filter[:len(filter):len(filter)],
+		kolektor.Condition{Field: "deleted", Operator: op}))
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", err)
+	}
+
+	q := fmt.Sprintf("UPDATE %s SET deleted = %s, version = version + 1%s", model.CollectionName(), set, where)
+
+	res, err := s.db.ExecContext(ctx, q, values...)
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", translateError(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed updating objects (%w)", translateError(err))
+	}
+
+	return n, nil
+}
+
+// InitCollection initializes the model's collection.
+func (s *Store) InitCollection(ctx context.Context, model kolektor.Modeler) error {
+	conn, err := s.connection(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	db := stores.LogSQLQuerier(conn, s.logger)
+
+	tableName := model.CollectionName()
+
+	// CREATE TABLE
+	if _, err := db.ExecContext(ctx, ddlTable(tableName)); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
+	}
+
+	if err := migrateTable(ctx, db, tableName); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, ddlUIDIndex(tableName)); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
+	}
+
+	if _, err := db.ExecContext(ctx, ddlCreatedIndex(tableName)); err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", translateError(err))
+	}
+
+	// CREATE TRIGGERs
+	for _, tr := range ddlTriggers(tableName) {
+		if _, err := db.ExecContext(ctx, tr); err != nil {
+			return fmt.Errorf("failed initializing collection (%w)", translateError(err))
+		}
+	}
+
+	// INDEXING
+	indexes, err := stores.ModelIndexes(model, kolektor.SQLite)
+	if err != nil {
+		return fmt.Errorf("failed initializing collection (%w)", err)
+	}
+	if err := addIndexes(ctx, db, indexes, tableName); err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+// RemoveCollection removes the model's collection.
+func (s *Store) RemoveCollection(ctx context.Context, model kolektor.Modeler) error {
+	ddl := fmt.Sprintf("DROP TABLE IF EXISTS %s", model.CollectionName())
+
+	if _, err := s.db.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("failed removing collection (%w)", translateError(err))
+	}
+
+	return nil
+}
+
+// ListCollections returns the names of all collections sorted by name,
+// which are the tables having the columns of a collection.
+func (s *Store) ListCollections(ctx context.Context) ([]string, error) {
+	q := "SELECT m.name FROM sqlite_master AS m, pragma_table_info(m.name) AS c" +
+		" WHERE m.type = 'table' AND c.name IN ('id', 'uid', 'data')" +
+		" GROUP BY m.name HAVING COUNT(*) = 3 ORDER BY m.name"
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
+	}
+	defer func() { _ = rows.Close() }()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed listing collections (%w)", translateError(err))
+	}
+
+	return names, nil
+}
+
+// ListIndexes returns the indexes of the model's collection sorted by name.
+// The definition of each index is its SQL; it is empty for indexes SQLite
+// creates itself. No indexes are returned when the collection does not
+// exist.
+func (s *Store) ListIndexes(ctx context.Context, model kolektor.Modeler) ([]kolektor.IndexStatus, error) {
+	q := "SELECT i.name, i.\"unique\", COALESCE(m.sql, '') FROM pragma_index_list(?) AS i" +
+		" LEFT JOIN sqlite_master AS m ON m.type = 'index' AND m.name = i.name ORDER BY i.name"
+
+	rows, err := s.db.QueryContext(ctx, q, model.CollectionName())
+	if err != nil {
+		return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+	}
+	defer func() { _ = rows.Close() }()
+
+	var indexes []kolektor.IndexStatus
+	for rows.Next() {
+		idx := kolektor.IndexStatus{Valid: true}
+		if err := rows.Scan(&idx.Name, &idx.Unique, &idx.Definition); err != nil {
+			return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+		}
+		idx.Managed = reIndexComment.MatchString(idx.Definition)
+		indexes = append(indexes, idx)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed listing indexes (%w)", translateError(err))
+	}
+
+	return indexes, nil
+}
+
+// migrateTable adds the columns which are missing from the table of a
+// collection created by a previous version.
+func migrateTable(ctx context.Context, conn stores.SQLQuerier, tableName string) error {
+	rows, err := conn.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", tableName)
+	if err != nil {
+		return fmt.Errorf("failed migrating %s (%w)", tableName, translateError(err))
+	}
+	defer func() { _ = rows.Close() }()
+
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed migrating %s (%w)", tableName, translateError(err))
+		}
+		have[strings.ToLower(name)] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed migrating %s (%w)", tableName, translateError(err))
+	}
+
+	// SQLite adds one column per statement
+	for _, c := range addedColumns {
+		if have[c.name] {
+			continue
+		}
+		ddl := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, c.name, c.definition)
+		if _, err := conn.ExecContext(ctx, ddl); err != nil {
+			return fmt.Errorf("failed migrating %s (%w)", tableName, translateError(err))
+		}
+	}
+
+	return nil
+}
+
+// scanMeta scans the metadata selected using dmlReturningMeta. SQLite
+// stores timestamps as text, which are parsed as UTC.
+func scanMeta(row scanner) (*kolektor.Meta, error) {
+	meta := &kolektor.Meta{}
+	var created string
+	var updated, deleted sql.NullString
+
+	if err := row.Scan(&meta.ID, &meta.UID, &created, &updated, &meta.Version, &deleted, &meta.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if meta.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+		return nil, err
+	}
+
+	if meta.Updated, err = parseNullTime(updated); err != nil {
+		return nil, err
+	}
+
+	if meta.Deleted, err = parseNullTime(deleted); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// parseNullTime parses the timestamp stored as text, returning nil when
+// it is NULL.
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
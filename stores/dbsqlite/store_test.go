@@ -0,0 +1,324 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/geertjanvdk/xkit/xt"
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+type Book struct {
+	kolektor.Model
+	ISBN13  string   `json:"isbn13"`
+	Title   string   `json:"title"`
+	Authors []string `json:"authors,omitempty"`
+
+	fuIndex          func() map[kolektor.StoreKind][]kolektor.Index
+	fuCollectionName func() string
+}
+
+var _ kolektor.Modeler = &Book{}
+
+func (b Book) CollectionName() string {
+	return b.fuCollectionName()
+}
+
+func (b Book) Indexes(kind kolektor.StoreKind) []kolektor.Index {
+	m := b.fuIndex()
+
+	if res, have := m[kind]; have {
+		return res
+	}
+
+	return nil
+}
+
+func TestStore_InitCollection(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+
+	expIndex1 := []string{"uq_books_isbn13", "((data->>'$.isbn13'))"}
+	expIndex2 := []string{"ix_books_title", "((data->>'$.title'))"}
+
+	t.Run("add indexes", func(t *testing.T) {
+		fuCollectionName := func() string {
+			return "books_239d9k3d"
+		}
+
+		book := &Book{}
+		book.fuCollectionName = fuCollectionName
+
+		book.fuIndex = func() map[kolektor.StoreKind][]kolektor.Index {
+			return map[kolektor.StoreKind][]kolektor.Index{
+				kolektor.SQLite: {
+					{
+						Name:       expIndex1[0],
+						Unique:     true,
+						Expression: expIndex1[1],
+					},
+					{
+						Name:       expIndex2[0],
+						Expression: expIndex2[1],
+					},
+				},
+			}
+		}
+
+		xt.OK(t, store.InitCollection(context.Background(), book))
+		indexes, err := getIndexes(context.Background(), store.mustSQLConn(), book.CollectionName())
+		xt.OK(t, err)
+		xt.Eq(t, 2, len(indexes))
+		exprSum, _ := indexes[expIndex1[0]]
+		xt.Eq(t, md5sum(expIndex1[1]), exprSum)
+		exprSum, _ = indexes[expIndex2[0]]
+		xt.Eq(t, md5sum(expIndex2[1]), exprSum)
+
+		t.Run("remove index", func(t *testing.T) {
+			book := &Book{}
+			book.fuCollectionName = fuCollectionName
+
+			book.fuIndex = func() map[kolektor.StoreKind][]kolektor.Index {
+				return map[kolektor.StoreKind][]kolektor.Index{
+					kolektor.SQLite: {
+						{
+							Name:       expIndex1[0],
+							Unique:     true,
+							Expression: expIndex1[1],
+						},
+					},
+				}
+			}
+
+			xt.OK(t, store.InitCollection(context.Background(), book))
+			indexes, err := getIndexes(context.Background(), store.mustSQLConn(), book.CollectionName())
+			xt.OK(t, err)
+			xt.Eq(t, 1, len(indexes))
+			exprSum, _ := indexes[expIndex1[0]]
+			xt.Eq(t, md5sum(expIndex1[1]), exprSum)
+		})
+
+		t.Run("change index", func(t *testing.T) {
+			book := &Book{}
+			book.fuCollectionName = fuCollectionName
+
+			// change to case-insensitive
+			expIndex1 := []string{"uq_books_isbn13", "((data->>'$.isbn13' COLLATE NOCASE))"}
+			book.fuIndex = func() map[kolektor.StoreKind][]kolektor.Index {
+				return map[kolektor.StoreKind][]kolektor.Index{
+					kolektor.SQLite: {
+						{
+							Name:       expIndex1[0],
+							Unique:     true,
+							Expression: expIndex1[1],
+						},
+					},
+				}
+			}
+
+			xt.OK(t, store.InitCollection(context.Background(), book))
+			indexes, err := getIndexes(context.Background(), store.mustSQLConn(), book.CollectionName())
+			xt.OK(t, err)
+			xt.Eq(t, 1, len(indexes))
+			exprSum, _ := indexes[expIndex1[0]]
+			xt.Eq(t, md5sum(expIndex1[1]), exprSum)
+		})
+	})
+}
+
+func TestStore_GetObject(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+
+	book := &Book{
+		fuCollectionName: func() string { return "books_c8d3k2x1" },
+		fuIndex:          func() map[kolektor.StoreKind][]kolektor.Index { return nil },
+	}
+	xt.OK(t, store.InitCollection(context.Background(), book))
+	book.ISBN13 = "978-0135800911"
+	_, err = store.StoreObject(context.Background(), book)
+	xt.OK(t, err)
+
+	t.Run("cancelled context aborts slow query", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := store.GetObject(ctx, book, kolektor.FieldMap{
+			"(WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT COUNT(*) FROM c)": 0})
+		xt.KO(t, err)
+		xt.Assert(t, time.Since(start) < 4*time.Second, "expected query to be aborted")
+		xt.Eq(t, context.DeadlineExceeded, ctx.Err())
+	})
+
+	t.Run("already cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := store.GetObject(ctx, book, kolektor.FieldMap{"isbn13": book.ISBN13})
+		xt.Assert(t, errors.Is(err, context.Canceled), "expected context.Canceled")
+	})
+}
+
+func TestStore_InitCollection_migrate(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+
+	book := &Book{
+		fuCollectionName: func() string { return "books_m1g7a2e4" },
+		fuIndex:          func() map[kolektor.StoreKind][]kolektor.Index { return nil },
+	}
+
+	// table as created before objects had versions
+	ddl := "CREATE TABLE " + book.CollectionName() + " (id INTEGER PRIMARY KEY AUTOINCREMENT, uid VARCHAR(200) NOT NULL DEFAULT '', created TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')), updated TEXT DEFAULT NULL, data JSON)"
+	_, err = store.mustSQLConn().ExecContext(context.Background(), ddl)
+	xt.OK(t, err)
+
+	xt.OK(t, store.InitCollection(context.Background(), book))
+
+	book.ISBN13 = "978-0201835953"
+	meta, err := store.StoreObject(context.Background(), book)
+	xt.OK(t, err)
+	xt.Eq(t, int64(1), meta.Version)
+}
+
+type taggedBook struct {
+	kolektor.Model
+	ISBN13    string `json:"isbn13" kolekto:"index,unique"`
+	Title     string `json:"title" kolekto:"index"`
+	Publisher struct {
+		Name string `json:"name" kolekto:"index"`
+	} `json:"publisher"`
+}
+
+func (b taggedBook) CollectionName() string {
+	return "books_t4g9e1d5"
+}
+
+func (b taggedBook) Indexes(kolektor.StoreKind) []kolektor.Index {
+	return []kolektor.Index{
+		{Name: "ix_books_t4g9e1d5_title", Expression: "((lower(data->>'$.title')))"},
+	}
+}
+
+func TestStore_InitCollection_tagged(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+	ctx := context.Background()
+
+	book := &taggedBook{}
+	xt.OK(t, store.RemoveCollection(ctx, book))
+	xt.OK(t, store.InitCollection(ctx, book))
+
+	indexes, err := getIndexes(ctx, store.mustSQLConn(), book.CollectionName())
+	xt.OK(t, err)
+	xt.Eq(t, map[string]string{
+		"uq_books_t4g9e1d5_isbn13":         md5sum("((data->>'$.isbn13'))"),
+		"ix_books_t4g9e1d5_publisher_name": md5sum("((data->>'$.publisher.name'))"),
+		"ix_books_t4g9e1d5_title":          md5sum("((lower(data->>'$.title')))"),
+	}, indexes)
+
+	book.ISBN13 = "978-1491903995"
+	_, err = store.StoreObject(ctx, book)
+	xt.OK(t, err)
+	_, err = store.StoreObject(ctx, &taggedBook{ISBN13: book.ISBN13})
+	xt.KO(t, err, "expected unique index to be enforced")
+}
+
+type portableBook struct {
+	kolektor.Model
+	Publisher string `json:"publisher"`
+	Year      int    `json:"year"`
+}
+
+func (b portableBook) CollectionName() string {
+	return "books_p0r7a8b1"
+}
+
+func (b portableBook) Indexes(kolektor.StoreKind) []kolektor.Index {
+	return []kolektor.Index{
+		{
+			Name:   "uq_books_p0r7a8b1_publisher_year",
+			Unique: true,
+			Fields: []kolektor.IndexField{
+				{Path: "publisher"},
+				{Path: "year", Type: kolektor.IndexInt, Descending: true},
+			},
+		},
+	}
+}
+
+func TestStore_InitCollection_portable(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+	ctx := context.Background()
+
+	book := &portableBook{}
+	xt.OK(t, store.RemoveCollection(ctx, book))
+	xt.OK(t, store.InitCollection(ctx, book))
+
+	indexes, err := getIndexes(ctx, store.mustSQLConn(), book.CollectionName())
+	xt.OK(t, err)
+	xt.Eq(t, map[string]string{
+		"uq_books_p0r7a8b1_publisher_year": md5sum("((data->>'$.publisher'), (data->>'$.year') DESC)"),
+	}, indexes)
+
+	_, err = store.StoreObject(ctx, &portableBook{Publisher: "O'Reilly", Year: 2015})
+	xt.OK(t, err)
+	_, err = store.StoreObject(ctx, &portableBook{Publisher: "O'Reilly", Year: 2016})
+	xt.OK(t, err)
+	_, err = store.StoreObject(ctx, &portableBook{Publisher: "O'Reilly", Year: 2015})
+	xt.KO(t, err, "expected unique index to be enforced")
+}
+
+func TestStore_PatchObject(t *testing.T) {
+	s, err := New(testDSN)
+	xt.OK(t, err)
+	store := s.(*Store)
+	ctx := context.Background()
+
+	book := &Book{
+		fuCollectionName: func() string { return "books_p5a2t8c3" },
+		fuIndex:          func() map[kolektor.StoreKind][]kolektor.Index { return nil },
+	}
+	xt.OK(t, store.RemoveCollection(ctx, book))
+	xt.OK(t, store.InitCollection(ctx, book))
+	book.ISBN13 = "978-0321125217"
+	meta, err := store.StoreObject(ctx, book)
+	xt.OK(t, err)
+
+	filter := kolektor.Filter{{Field: "uid", Operator: kolektor.OpEqual, Value: meta.UID}}
+	patch := kolektor.JSONPatch{{Op: kolektor.PatchReplace, Path: "/title", Value: "DDD"}}
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := store.PatchObject(ctx, book, filter, patch)
+		xt.Assert(t, errors.Is(err, context.Canceled), "expected context.Canceled")
+	})
+
+	t.Run("retries are bounded", func(t *testing.T) {
+		// updates are skipped, as if the object was changed concurrently
+		ddl := "CREATE TRIGGER tr_books_p5a2t8c3_skip BEFORE UPDATE ON " + book.CollectionName() +
+			" BEGIN SELECT RAISE(IGNORE); END"
+		_, err := store.mustSQLConn().ExecContext(ctx, ddl)
+		xt.OK(t, err)
+
+		_, err = store.PatchObject(ctx, book, filter, patch)
+		xt.Assert(t, errors.As(err, &stores.ErrConflict{}), "expected stores.ErrConflict")
+		xt.Assert(t, errors.Is(err, kolektor.ErrConflict), "expected kolektor.ErrConflict")
+	})
+}
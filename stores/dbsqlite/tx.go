@@ -0,0 +1,99 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// txStore is a Store bound to a transaction.
+type txStore struct {
+	*Store
+	conn     *sql.Conn
+	tx       *sql.Tx
+	readOnly bool
+}
+
+var _ kolektor.TxStorer = &txStore{}
+
+// BeginTx starts a transaction and returns a store which executes all
+// statements within it. SQLite transactions are always serializable;
+// read-only transactions are enforced using the query_only pragma.
+func (s *Store) BeginTx(ctx context.Context, opts *sql.TxOptions) (kolektor.TxStorer, error) {
+	conn, err := s.pool.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed starting transaction (%w)", translateError(err))
+	}
+
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed starting transaction (%w)", translateError(err))
+	}
+
+	ts := &txStore{
+		Store: &Store{pool: s.pool, db: stores.LogSQLQuerier(tx, s.logger), logger: s.logger},
+		conn:  conn,
+		tx:    tx,
+	}
+
+	if opts != nil && opts.ReadOnly {
+		ts.readOnly = true
+		if _, err := ts.db.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			return nil, errors.Join(fmt.Errorf("failed starting transaction (%w)", translateError(err)), ts.Rollback(ctx))
+		}
+	}
+
+	return ts, nil
+}
+
+// BeginTx returns an error since nested transactions are not supported.
+func (s *txStore) BeginTx(context.Context, *sql.TxOptions) (kolektor.TxStorer, error) {
+	return nil, fmt.Errorf("nested transactions are not supported")
+}
+
+// Connection returns the transaction of this store. The caller is
+// responsible for type asserting the result to *sql.Tx.
+func (s *txStore) Connection(context.Context) (any, error) {
+	return s.tx, nil
+}
+
+// Commit commits the transaction.
+func (s *txStore) Commit(ctx context.Context) error {
+	if err := s.tx.Commit(); err != nil {
+		_ = s.release(ctx)
+		return fmt.Errorf("failed committing transaction (%w)", translateError(err))
+	}
+	return s.release(ctx)
+}
+
+// Rollback rolls back the transaction.
+func (s *txStore) Rollback(ctx context.Context) error {
+	if err := s.tx.Rollback(); err != nil {
+		_ = s.release(ctx)
+		return fmt.Errorf("failed rolling back transaction (%w)", translateError(err))
+	}
+	return s.release(ctx)
+}
+
+// release resets the query_only pragma, when it was set, and returns the
+// connection to the pool.
+func (s *txStore) release(ctx context.Context) error {
+	defer func() { _ = s.conn.Close() }()
+
+	if s.readOnly {
+		if _, err := s.conn.ExecContext(context.WithoutCancel(ctx), "PRAGMA query_only = OFF"); err != nil {
+			return fmt.Errorf("failed resetting transaction (%w)", translateError(err))
+		}
+	}
+
+	return nil
+}
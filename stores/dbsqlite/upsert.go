@@ -0,0 +1,56 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+//go:build !nosqlite
+
+package dbsqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+)
+
+// UpsertObject inserts obj, or updates the object which has the same
+// values for keyFields, and returns the resulting metadata and whether obj
+// was inserted. The keyFields identify a unique index of the model (see
+// stores.UniqueIndex), or the uid column when empty. Updated objects are
+// restored when they were soft deleted.
+func (s *Store) UpsertObject(ctx context.Context, obj kolektor.Modeler, keyFields []string) (*kolektor.Meta, bool, error) {
+	idx, err := stores.UniqueIndex(obj, kolektor.SQLite, keyFields)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	target, err := stores.ConflictTarget(idx, indexFieldExpression)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	objUID := obj.GetUID()
+	if objUID == "" {
+		// triggers are not reflected by RETURNING
+		if objUID, err = stores.NewUID(); err != nil {
+			return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+		}
+	}
+
+	data, err := stores.MarshalObject(obj)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", err)
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s (data, uid, schema_version) VALUES (json(?), ?, ?) "+
+		"ON CONFLICT %s DO UPDATE SET data = excluded.data, schema_version = excluded.schema_version, "+
+		"deleted = NULL, updated = %s, version = version + 1 RETURNING %s",
+		obj.CollectionName(), target, sqliteTimestamp, dmlReturningMeta)
+
+	meta, err := scanMeta(s.db.QueryRowContext(ctx, q, string(data), objUID, stores.SchemaVersion(obj)))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed upserting object (%w)", translateError(err))
+	}
+
+	// updates always increment the version
+	return meta, meta.Version == 1, nil
+}
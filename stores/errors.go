@@ -2,8 +2,19 @@
 
 package stores
 
-import "fmt"
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
 
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// ErrNoObject is returned when an object is not available in the collection.
+// It matches kolektor.ErrNotFound using errors.Is.
 type ErrNoObject struct {
 	Name string
 }
@@ -11,3 +22,82 @@ type ErrNoObject struct {
 func (e ErrNoObject) Error() string {
 	return fmt.Sprintf("%s object not available", e.Name)
 }
+
+func (e ErrNoObject) Is(target error) bool {
+	return target == kolektor.ErrNotFound
+}
+
+// ErrConflict is returned when an object could not be stored because it was
+// changed by another writer since it was retrieved. It matches
+// kolektor.ErrConflict using errors.Is.
+type ErrConflict struct {
+	Name    string
+	ID      int64
+	Version int64
+}
+
+func (e ErrConflict) Error() string {
+	return fmt.Sprintf("%s object %d was changed since version %d", e.Name, e.ID, e.Version)
+}
+
+func (e ErrConflict) Is(target error) bool {
+	return target == kolektor.ErrConflict
+}
+
+// ErrInvalid is returned when an object could not be stored because it
+// failed validation.
+type ErrInvalid struct {
+	Name string
+	Err  error
+}
+
+func (e ErrInvalid) Error() string {
+	return fmt.Sprintf("%s object is invalid (%s)", e.Name, e.Err)
+}
+
+func (e ErrInvalid) Unwrap() error {
+	return e.Err
+}
+
+// ErrPatch is returned when a patch could not be applied to an object, for
+// example, because a test operation failed or a path does not exist.
+type ErrPatch struct {
+	Name string
+	Err  error
+}
+
+func (e ErrPatch) Error() string {
+	return fmt.Sprintf("failed patching %s object (%s)", e.Name, e.Err)
+}
+
+func (e ErrPatch) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError returns err as *kolektor.StoreError when it was caused by
+// a timeout or by the data store being unavailable, which is detected the
+// same way for all kinds of data stores. Otherwise, including when err was
+// already classified, err is returned as is.
+func ClassifyError(err error) error {
+	var storeErr *kolektor.StoreError
+	if err == nil || errors.As(err, &storeErr) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &kolektor.StoreError{Kind: kolektor.ErrTimeout, Err: err}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return &kolektor.StoreError{Kind: kolektor.ErrStoreUnavailable, Err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return &kolektor.StoreError{Kind: kolektor.ErrTimeout, Err: err}
+		}
+		return &kolektor.StoreError{Kind: kolektor.ErrStoreUnavailable, Err: err}
+	}
+
+	return err
+}
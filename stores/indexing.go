@@ -0,0 +1,131 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package stores
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/xstrings"
+)
+
+// ModelIndexes returns the indexes of model for data stores of kind: the
+// indexes declared using struct tags, followed by the indexes returned by
+// kolektor.Indexer. An index returned by kolektor.Indexer replaces the
+// tagged index with the same name.
+func ModelIndexes(model kolektor.Modeler, kind kolektor.StoreKind) ([]kolektor.Index, error) {
+	tagged, err := kolektor.TaggedIndexes(model)
+	if err != nil {
+		return nil, err
+	}
+
+	var explicit []kolektor.Index
+	if idxer, ok := model.(kolektor.Indexer); ok {
+		explicit = idxer.Indexes(kind)
+	}
+
+	names := map[string]bool{}
+	for _, idx := range explicit {
+		names[idx.Name] = true
+	}
+
+	var indexes []kolektor.Index
+	for _, idx := range tagged {
+		if !names[idx.Name] {
+			indexes = append(indexes, idx.Index())
+		}
+	}
+
+	return append(indexes, explicit...), nil
+}
+
+// IndexExpression returns the SQL expression of idx: its Expression when
+// set, otherwise the list of key parts of its fields, each using the
+// expression returned by fieldExpression, for example:
+//
+//	((data->>'name'), ((data->>'year')::bigint) DESC)
+func IndexExpression(idx kolektor.Index, fieldExpression func(field kolektor.IndexField) string) (string, error) {
+	if idx.Expression != "" {
+		return idx.Expression, nil
+	}
+
+	if len(idx.Fields) == 0 {
+		return "", fmt.Errorf("index %s needs an expression or fields", idx.Name)
+	}
+
+	parts := make([]string, len(idx.Fields))
+	for i, field := range idx.Fields {
+		if _, err := FieldPath(field.Path); err != nil {
+			return "", fmt.Errorf("index %s: %w", idx.Name, err)
+		}
+		if field.Type < kolektor.IndexString || field.Type > kolektor.IndexTimestamp {
+			return "", fmt.Errorf("index %s: unsupported type %s", idx.Name, field.Type)
+		}
+
+		parts[i] = "(" + fieldExpression(field) + ")"
+		if field.Descending {
+			parts[i] += " DESC"
+		}
+	}
+
+	return "(" + strings.Join(parts, ", ") + ")", nil
+}
+
+// UniqueIndex returns the unique index of model for data stores of kind
+// identified by keyFields: either the paths of the fields of the index, in
+// any order, or its name. Nil is returned when keyFields is empty or is
+// "uid", which identifies each object of a collection.
+func UniqueIndex(model kolektor.Modeler, kind kolektor.StoreKind, keyFields []string) (*kolektor.Index, error) {
+	if len(keyFields) == 0 || (len(keyFields) == 1 && keyFields[0] == "uid") {
+		return nil, nil
+	}
+
+	indexes, err := ModelIndexes(model, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, idx := range indexes {
+		if !idx.Unique {
+			continue
+		}
+		if len(keyFields) == 1 && keyFields[0] == idx.Name {
+			return &idx, nil
+		}
+		if idx.Expression != "" || len(idx.Fields) != len(keyFields) {
+			continue
+		}
+
+		match := true
+		for _, field := range idx.Fields {
+			if xstrings.Search(keyFields, field.Path) == -1 {
+				match = false
+				break
+			}
+		}
+		if match {
+			return &idx, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no unique index on %s", strings.Join(keyFields, ", "))
+}
+
+// ConflictTarget returns the expression of the unique index idx as used
+// with INSERT ... ON CONFLICT, which are the expressions of its fields
+// without sort order. The uid column is returned when idx is nil.
+func ConflictTarget(idx *kolektor.Index, fieldExpression func(field kolektor.IndexField) string) (string, error) {
+	if idx == nil {
+		return "(uid)", nil
+	}
+
+	target := *idx
+	target.Fields = make([]kolektor.IndexField, len(idx.Fields))
+	for i, field := range idx.Fields {
+		field.Descending = false
+		target.Fields[i] = field
+	}
+
+	return IndexExpression(target, fieldExpression)
+}
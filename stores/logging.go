@@ -0,0 +1,73 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package stores
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// SQLQuerier is implemented by *sql.DB, *sql.Conn, and *sql.Tx.
+type SQLQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// LogSQLQuerier returns a SQLQuerier executing the statements using q, and
+// logging each of them using logger. When q was returned by LogSQLQuerier
+// before, the querier it wraps around is used instead. When logger is nil,
+// statements are not logged and the (unwrapped) q is returned.
+func LogSQLQuerier(q SQLQuerier, logger kolektor.StatementLogger) SQLQuerier {
+	if lq, ok := q.(*logSQLQuerier); ok {
+		q = lq.SQLQuerier
+	}
+
+	if logger == nil {
+		return q
+	}
+
+	return &logSQLQuerier{SQLQuerier: q, logger: logger}
+}
+
+// logSQLQuerier wraps around a SQLQuerier and logs the statements it
+// executes.
+type logSQLQuerier struct {
+	SQLQuerier
+	logger kolektor.StatementLogger
+}
+
+func (q *logSQLQuerier) log(ctx context.Context, query string, args []any, start time.Time, err error) {
+	q.logger.LogStatement(ctx, kolektor.Statement{
+		SQL:      query,
+		Args:     args,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+func (q *logSQLQuerier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := q.SQLQuerier.ExecContext(ctx, query, args...)
+	q.log(ctx, query, args, start, err)
+	return res, err
+}
+
+// QueryContext logs the statement once its first result is available; the
+// time spent iterating the rows is not included.
+func (q *logSQLQuerier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := q.SQLQuerier.QueryContext(ctx, query, args...)
+	q.log(ctx, query, args, start, err)
+	return rows, err
+}
+
+func (q *logSQLQuerier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := q.SQLQuerier.QueryRowContext(ctx, query, args...)
+	q.log(ctx, query, args, start, row.Err())
+	return row
+}
@@ -0,0 +1,447 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package stores
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// ErrPatchFailed is the reason of ErrPatch when a data store applying a
+// patch within a single statement cannot tell which operation failed.
+var ErrPatchFailed = fmt.Errorf("test failed or path not available")
+
+// CheckPatch checks whether patch can be applied to JSON documents. A merge
+// patch must be a JSON object, and the paths of the operations of a JSON
+// patch must point within the document.
+func CheckPatch(patch kolektor.Patch) error {
+	switch p := patch.(type) {
+	case kolektor.MergePatch:
+		if _, err := MergePatchMembers(p); err != nil {
+			return err
+		}
+	case kolektor.JSONPatch:
+		if len(p) == 0 {
+			return fmt.Errorf("JSON patch has no operations")
+		}
+		for i, op := range p {
+			if err := checkOperation(op); err != nil {
+				return fmt.Errorf("invalid operation %d of JSON patch (%w)", i, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported patch type %T", patch)
+	}
+
+	return nil
+}
+
+func checkOperation(op kolektor.PatchOperation) error {
+	if !op.Op.Valid() {
+		return fmt.Errorf("unsupported operation '%s'", op.Op)
+	}
+
+	path, err := PointerTokens(op.Path)
+	if err != nil {
+		return err
+	}
+
+	last := path[len(path)-1]
+	if last == "-" && op.Op != kolektor.PatchAdd {
+		return fmt.Errorf("'-' can only be used to add to an array")
+	}
+	for _, t := range path[:len(path)-1] {
+		if t == "-" {
+			return fmt.Errorf("'-' can only be used as last token of path")
+		}
+	}
+
+	if op.Op == kolektor.PatchMove || op.Op == kolektor.PatchCopy {
+		from, err := PointerTokens(op.From)
+		if err != nil {
+			return fmt.Errorf("invalid from (%w)", err)
+		}
+		for _, t := range from {
+			if t == "-" {
+				return fmt.Errorf("'-' cannot be used within from")
+			}
+		}
+		if op.Op == kolektor.PatchMove && strings.HasPrefix(op.Path+"/", op.From+"/") {
+			return fmt.Errorf("cannot move %s into itself", op.From)
+		}
+	}
+
+	if _, err := json.Marshal(op.Value); err != nil {
+		return fmt.Errorf("invalid value (%w)", err)
+	}
+
+	return nil
+}
+
+// PointerTokens splits pointer, a JSON Pointer as defined by RFC 6901, into
+// its unescaped reference tokens. The pointer must reference a value within
+// the document, not the document itself.
+func PointerTokens(pointer string) ([]string, error) {
+	if pointer == "" || pointer == "/" {
+		return nil, fmt.Errorf("pointer must reference a value within the document")
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer '%s'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// IsArrayIndex returns whether token is an index of an array element, which
+// is a number without leading zeros. Data stores applying patches using SQL
+// interpret such tokens as array indexes, even when the value is an object.
+func IsArrayIndex(token string) bool {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MergePatchMembers returns the members of the JSON object patch. Values
+// are decoded preserving numbers as json.Number.
+func MergePatchMembers(patch kolektor.MergePatch) (map[string]any, error) {
+	v, err := decodeJSON(patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge patch (%w)", err)
+	}
+
+	members, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("merge patch must be a JSON object")
+	}
+	return members, nil
+}
+
+// ApplyPatch applies patch to the JSON document doc and returns the patched
+// document. The patch must have been checked using CheckPatch.
+func ApplyPatch(doc []byte, patch kolektor.Patch) ([]byte, error) {
+	target, err := decodeJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p := patch.(type) {
+	case kolektor.MergePatch:
+		members, err := MergePatchMembers(p)
+		if err != nil {
+			return nil, err
+		}
+		target = mergePatch(target, members)
+	case kolektor.JSONPatch:
+		for i, op := range p {
+			if target, err = applyOperation(target, op); err != nil {
+				return nil, fmt.Errorf("operation %d (%s %s) failed (%w)", i, op.Op, op.Path, err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported patch type %T", patch)
+	}
+
+	return json.Marshal(target)
+}
+
+// decodeJSON decodes data keeping numbers as json.Number, so that they are
+// encoded again without loss of precision.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func mergePatch(target any, patch any) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	obj, ok := target.(map[string]any)
+	if !ok {
+		obj = map[string]any{}
+	}
+
+	for k, v := range members {
+		if v == nil {
+			delete(obj, k)
+			continue
+		}
+		obj[k] = mergePatch(obj[k], v)
+	}
+
+	return obj
+}
+
+func applyOperation(doc any, op kolektor.PatchOperation) (any, error) {
+	path, err := PointerTokens(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case kolektor.PatchAdd, kolektor.PatchReplace, kolektor.PatchTest:
+		b, err := json.Marshal(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeJSON(b)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case kolektor.PatchAdd:
+			return addValue(doc, path, value, false)
+		case kolektor.PatchReplace:
+			return addValue(doc, path, value, true)
+		default:
+			current, err := getValue(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !jsonEqual(current, value) {
+				return nil, fmt.Errorf("value is different")
+			}
+			return doc, nil
+		}
+	case kolektor.PatchRemove:
+		doc, _, err = removeValue(doc, path)
+		return doc, err
+	case kolektor.PatchMove, kolektor.PatchCopy:
+		from, err := PointerTokens(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value any
+		if op.Op == kolektor.PatchMove {
+			doc, value, err = removeValue(doc, from)
+		} else {
+			value, err = getValue(doc, from)
+			if err == nil {
+				value = copyValue(value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value, false)
+	default:
+		return nil, fmt.Errorf("unsupported operation '%s'", op.Op)
+	}
+}
+
+func getValue(doc any, path []string) (any, error) {
+	v := doc
+	for _, t := range path {
+		switch n := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = n[t]; !ok {
+				return nil, fmt.Errorf("member %s not available", t)
+			}
+		case []any:
+			i, err := arrayIndex(t, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			v = n[i]
+		default:
+			return nil, fmt.Errorf("%s is not within an object or array", t)
+		}
+	}
+	return v, nil
+}
+
+// addValue adds value at path within node, returning the changed node. When
+// replace is true, the value at path must exist and is replaced.
+func addValue(node any, path []string, value any, replace bool) (any, error) {
+	t := path[0]
+
+	switch n := node.(type) {
+	case map[string]any:
+		if len(path) > 1 {
+			child, ok := n[t]
+			if !ok {
+				return nil, fmt.Errorf("member %s not available", t)
+			}
+			c, err := addValue(child, path[1:], value, replace)
+			if err != nil {
+				return nil, err
+			}
+			n[t] = c
+			return n, nil
+		}
+		if _, ok := n[t]; replace && !ok {
+			return nil, fmt.Errorf("member %s not available", t)
+		}
+		n[t] = value
+		return n, nil
+	case []any:
+		if len(path) > 1 || replace {
+			i, err := arrayIndex(t, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			if len(path) == 1 {
+				n[i] = value
+				return n, nil
+			}
+			c, err := addValue(n[i], path[1:], value, replace)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = c
+			return n, nil
+		}
+		if t == "-" {
+			return append(n, value), nil
+		}
+		i, err := arrayIndex(t, len(n))
+		if err != nil {
+			return nil, err
+		}
+		n = append(n, nil)
+		copy(n[i+1:], n[i:])
+		n[i] = value
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%s is not within an object or array", t)
+	}
+}
+
+// removeValue removes the value at path within node, returning the changed
+// node and the removed value.
+func removeValue(node any, path []string) (any, any, error) {
+	t := path[0]
+
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[t]
+		if !ok {
+			return nil, nil, fmt.Errorf("member %s not available", t)
+		}
+		if len(path) == 1 {
+			delete(n, t)
+			return n, child, nil
+		}
+		c, removed, err := removeValue(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[t] = c
+		return n, removed, nil
+	case []any:
+		i, err := arrayIndex(t, len(n)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := n[i]
+			return append(n[:i], n[i+1:]...), removed, nil
+		}
+		c, removed, err := removeValue(n[i], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[i] = c
+		return n, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("%s is not within an object or array", t)
+	}
+}
+
+// arrayIndex returns token as index of an array element, which can be at
+// most max.
+func arrayIndex(token string, max int) (int, error) {
+	if !IsArrayIndex(token) {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i > max {
+		return 0, fmt.Errorf("array index %s out of bounds", token)
+	}
+	return i, nil
+}
+
+func copyValue(v any) any {
+	switch n := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(n))
+		for k, e := range n {
+			c[k] = copyValue(e)
+		}
+		return c
+	case []any:
+		c := make([]any, len(n))
+		for i, e := range n {
+			c[i] = copyValue(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// jsonEqual returns whether the decoded JSON values a and b are equal, with
+// numbers being equal when their values are.
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, e := range x {
+			f, ok := y[k]
+			if !ok || !jsonEqual(e, f) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
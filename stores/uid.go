@@ -0,0 +1,22 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package stores
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// NewUID returns a random (version 4) UUID, which is the same as data stores
+// generate by default.
+func NewUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed generating UID (%w)", err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant RFC 4122
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
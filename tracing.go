@@ -0,0 +1,284 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/golistic/kolekto/kolektor"
+)
+
+// SetTracer traces all operations of the data store used by the session
+// using tracer, including those of collections and transactions which were
+// already retrieved. Tracing is disabled when tracer is nil.
+func (ses *Session) SetTracer(tracer kolektor.Tracer) {
+	store := untraced(ses.store)
+
+	switch {
+	case tracer == nil:
+		ses.store = store
+	case ses.inTx:
+		tx := store.(kolektor.TxStorer)
+		ses.store = &tracedTxStore{tracedStore: tracedStore{Storer: tx, tracer: tracer}, tx: tx}
+	default:
+		ses.store = &tracedStore{Storer: store, tracer: tracer}
+	}
+}
+
+// untraced returns the data store wrapped by store when it is traced, and
+// store itself otherwise.
+func untraced(store kolektor.Storer) kolektor.Storer {
+	switch s := store.(type) {
+	case *tracedStore:
+		return s.Storer
+	case *tracedTxStore:
+		return s.tx
+	default:
+		return store
+	}
+}
+
+// tracedStore wraps around a data store and traces each of its operations.
+type tracedStore struct {
+	kolektor.Storer
+	tracer kolektor.Tracer
+}
+
+var _ kolektor.Storer = &tracedStore{}
+
+// start starts tracing the operation called name on the collection of model,
+// which is nil when the operation is not done on a collection.
+func (s *tracedStore) start(ctx context.Context, name string, model kolektor.Modeler) (context.Context, kolektor.Span) {
+	op := kolektor.Operation{Name: name, Store: s.Storer.Name()}
+	if model != nil {
+		op.Collection = model.CollectionName()
+	}
+
+	return s.tracer.Start(ctx, op)
+}
+
+// endSpan ends span recording rows as the number of objects when err is nil.
+func endSpan(span kolektor.Span, rows int64, err error) {
+	if err == nil {
+		span.SetRows(rows)
+	}
+	span.End(err)
+}
+
+func (s *tracedStore) GetObject(ctx context.Context, obj kolektor.Modeler, fields kolektor.FieldMap) error {
+	ctx, span := s.start(ctx, "GetObject", obj)
+	err := s.Storer.GetObject(ctx, obj, fields)
+	endSpan(span, 1, err)
+	return err
+}
+
+// QueryObjects traces the query until the returned rows are closed. The
+// number of rows is the number of documents iterated.
+func (s *tracedStore) QueryObjects(ctx context.Context, model kolektor.Modeler, query *kolektor.Query) (kolektor.Rows, error) {
+	ctx, span := s.start(ctx, "QueryObjects", model)
+	rows, err := s.Storer.QueryObjects(ctx, model, query)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (s *tracedStore) CountObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	ctx, span := s.start(ctx, "CountObjects", model)
+	n, err := s.Storer.CountObjects(ctx, model, filter)
+	span.End(err)
+	return n, err
+}
+
+func (s *tracedStore) ObjectsExist(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (bool, error) {
+	ctx, span := s.start(ctx, "ObjectsExist", model)
+	exist, err := s.Storer.ObjectsExist(ctx, model, filter)
+	span.End(err)
+	return exist, err
+}
+
+func (s *tracedStore) AggregateObjects(ctx context.Context, model kolektor.Modeler, agg kolektor.Aggregation) ([]kolektor.AggregateResult, error) {
+	ctx, span := s.start(ctx, "AggregateObjects", model)
+	results, err := s.Storer.AggregateObjects(ctx, model, agg)
+	endSpan(span, int64(len(results)), err)
+	return results, err
+}
+
+func (s *tracedStore) StoreObject(ctx context.Context, obj kolektor.Modeler) (*kolektor.Meta, error) {
+	ctx, span := s.start(ctx, "StoreObject", obj)
+	meta, err := s.Storer.StoreObject(ctx, obj)
+	endSpan(span, 1, err)
+	return meta, err
+}
+
+func (s *tracedStore) StoreObjects(ctx context.Context, model kolektor.Modeler, objs []kolektor.Modeler) ([]*kolektor.Meta, error) {
+	ctx, span := s.start(ctx, "StoreObjects", model)
+	metas, err := s.Storer.StoreObjects(ctx, model, objs)
+	endSpan(span, int64(len(metas)), err)
+	return metas, err
+}
+
+func (s *tracedStore) UpgradeObject(ctx context.Context, model kolektor.Modeler, id, version int64,
+	data []byte) (bool, error) {
+	ctx, span := s.start(ctx, "UpgradeObject", model)
+	upgraded, err := s.Storer.UpgradeObject(ctx, model, id, version, data)
+	var n int64
+	if upgraded {
+		n = 1
+	}
+	endSpan(span, n, err)
+	return upgraded, err
+}
+
+func (s *tracedStore) UpsertObject(ctx context.Context, obj kolektor.Modeler, keyFields []string) (*kolektor.Meta, bool, error) {
+	ctx, span := s.start(ctx, "UpsertObject", obj)
+	meta, inserted, err := s.Storer.UpsertObject(ctx, obj, keyFields)
+	endSpan(span, 1, err)
+	return meta, inserted, err
+}
+
+func (s *tracedStore) ImportObject(ctx context.Context, model kolektor.Modeler, meta kolektor.Meta,
+	data []byte, onConflict kolektor.ImportConflict) (bool, error) {
+	ctx, span := s.start(ctx, "ImportObject", model)
+	stored, err := s.Storer.ImportObject(ctx, model, meta, data, onConflict)
+	var n int64
+	if stored {
+		n = 1
+	}
+	endSpan(span, n, err)
+	return stored, err
+}
+
+func (s *tracedStore) PatchObject(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter, patch kolektor.Patch) (*kolektor.Meta, error) {
+	ctx, span := s.start(ctx, "PatchObject", model)
+	meta, err := s.Storer.PatchObject(ctx, model, filter, patch)
+	endSpan(span, 1, err)
+	return meta, err
+}
+
+func (s *tracedStore) DeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	ctx, span := s.start(ctx, "DeleteObjects", model)
+	n, err := s.Storer.DeleteObjects(ctx, model, filter)
+	endSpan(span, n, err)
+	return n, err
+}
+
+func (s *tracedStore) SoftDeleteObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	ctx, span := s.start(ctx, "SoftDeleteObjects", model)
+	n, err := s.Storer.SoftDeleteObjects(ctx, model, filter)
+	endSpan(span, n, err)
+	return n, err
+}
+
+func (s *tracedStore) RestoreObjects(ctx context.Context, model kolektor.Modeler, filter kolektor.Filter) (int64, error) {
+	ctx, span := s.start(ctx, "RestoreObjects", model)
+	n, err := s.Storer.RestoreObjects(ctx, model, filter)
+	endSpan(span, n, err)
+	return n, err
+}
+
+func (s *tracedStore) RemoveCollection(ctx context.Context, model kolektor.Modeler) error {
+	ctx, span := s.start(ctx, "RemoveCollection", model)
+	err := s.Storer.RemoveCollection(ctx, model)
+	span.End(err)
+	return err
+}
+
+func (s *tracedStore) ListCollections(ctx context.Context) ([]string, error) {
+	ctx, span := s.start(ctx, "ListCollections", nil)
+	names, err := s.Storer.ListCollections(ctx)
+	endSpan(span, int64(len(names)), err)
+	return names, err
+}
+
+func (s *tracedStore) ListIndexes(ctx context.Context, model kolektor.Modeler) ([]kolektor.IndexStatus, error) {
+	ctx, span := s.start(ctx, "ListIndexes", model)
+	indexes, err := s.Storer.ListIndexes(ctx, model)
+	endSpan(span, int64(len(indexes)), err)
+	return indexes, err
+}
+
+// InitCollection traces initializing the collection. The context passed to
+// the data store carries the tracer, so that it traces reconciling the
+// indexes as "ReconcileIndexes", a child of the span of InitCollection.
+func (s *tracedStore) InitCollection(ctx context.Context, model kolektor.Modeler) error {
+	ctx, span := s.start(ctx, "InitCollection", model)
+	err := s.Storer.InitCollection(kolektor.ContextWithTracer(ctx, s.tracer), model)
+	span.End(err)
+	return err
+}
+
+func (s *tracedStore) Connection(ctx context.Context) (any, error) {
+	ctx, span := s.start(ctx, "Connection", nil)
+	conn, err := s.Storer.Connection(ctx)
+	span.End(err)
+	return conn, err
+}
+
+// BeginTx traces starting the transaction. The returned store traces the
+// operations done within the transaction.
+func (s *tracedStore) BeginTx(ctx context.Context, opts *sql.TxOptions) (kolektor.TxStorer, error) {
+	ctx, span := s.start(ctx, "BeginTx", nil)
+	tx, err := s.Storer.BeginTx(ctx, opts)
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedTxStore{tracedStore: tracedStore{Storer: tx, tracer: s.tracer}, tx: tx}, nil
+}
+
+// tracedTxStore wraps around a data store bound to a transaction and traces
+// each of its operations.
+type tracedTxStore struct {
+	tracedStore
+	tx kolektor.TxStorer
+}
+
+var _ kolektor.TxStorer = &tracedTxStore{}
+
+func (s *tracedTxStore) Commit(ctx context.Context) error {
+	ctx, span := s.start(ctx, "Commit", nil)
+	err := s.tx.Commit(ctx)
+	span.End(err)
+	return err
+}
+
+func (s *tracedTxStore) Rollback(ctx context.Context) error {
+	ctx, span := s.start(ctx, "Rollback", nil)
+	err := s.tx.Rollback(ctx)
+	span.End(err)
+	return err
+}
+
+// tracedRows counts the documents iterated, and ends the span of the query
+// when closed.
+type tracedRows struct {
+	kolektor.Rows
+	span  kolektor.Span
+	n     int64
+	ended bool
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		r.n++
+		return true
+	}
+	return false
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.ended {
+		r.ended = true
+		spanErr := r.Rows.Err()
+		if spanErr == nil {
+			spanErr = err
+		}
+		endSpan(r.span, r.n, spanErr)
+	}
+	return err
+}
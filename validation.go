@@ -0,0 +1,89 @@
+// Copyright (c) 2022, Geert JM Vanderkelen
+
+package kolekto
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golistic/kolekto/kolektor"
+	"github.com/golistic/kolekto/stores"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// compileSchema compiles the JSON Schema of model, if any. Schemas without
+// $schema keyword are interpreted as draft 4, which is what MySQL supports.
+// Schemas must be self-contained: remote references are not loaded.
+func compileSchema(model kolektor.Modeler) (*jsonschema.Schema, error) {
+	schemer, ok := model.(kolektor.JSONSchemer)
+	if !ok {
+		return nil, nil
+	}
+
+	url := "kolekto://" + model.CollectionName() + ".json"
+
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft4
+	c.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("loading %s not supported", s)
+	}
+
+	if err := c.AddResource(url, strings.NewReader(schemer.JSONSchema())); err != nil {
+		return nil, fmt.Errorf("failed compiling JSON schema of %s (%w)", model.CollectionName(), err)
+	}
+
+	schema, err := c.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed compiling JSON schema of %s (%w)", model.CollectionName(), err)
+	}
+
+	return schema, nil
+}
+
+// validate checks obj using its Validate method, if any, and the JSON Schema
+// of the collection, if any. Error stores.ErrInvalid is returned when obj is
+// not valid.
+func (coll *Collection) validate(obj kolektor.Modeler) error {
+	if v, ok := obj.(kolektor.Validator); ok {
+		if err := v.Validate(); err != nil {
+			return stores.ErrInvalid{Name: coll.model.CollectionName(), Err: err}
+		}
+	}
+
+	if coll.schema == nil {
+		return nil
+	}
+
+	doc, err := document(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := coll.schema.Validate(doc); err != nil {
+		return stores.ErrInvalid{Name: coll.model.CollectionName(), Err: err}
+	}
+
+	return nil
+}
+
+// document returns the JSON document of obj as it is stored, thus without
+// metadata, decoded into generic values.
+func document(obj kolektor.Modeler) (any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding object (%w)", err)
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed encoding object (%w)", err)
+	}
+
+	if m, ok := doc.(map[string]any); ok {
+		delete(m, "Meta")
+	}
+
+	return doc, nil
+}